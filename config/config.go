@@ -15,6 +15,7 @@
 package config
 
 import (
+	"bytes"
 	"fmt"
 	"reflect"
 	"regexp"
@@ -44,6 +45,9 @@ type CloudConfig struct {
 	Hostname          string     `yaml:"hostname"`
 	Users             []User     `yaml:"users"`
 	ManageEtcHosts    EtcHosts   `yaml:"manage_etc_hosts"`
+	ResolvConf        ResolvConf `yaml:"resolv_conf"`
+	VendorData        VendorData `yaml:"vendor_data"`
+	Network           Network    `yaml:"network"`
 }
 
 type CoreOS struct {
@@ -70,11 +74,14 @@ func IsCloudConfig(userdata string) bool {
 // string of YAML), returning any error encountered. It will ignore unknown
 // fields but log encountering them.
 func NewCloudConfig(contents string) (*CloudConfig, error) {
-	//	yaml.UnmarshalMappingKeyTransform = func(nameIn string) (nameOut string) {
-	//		return strings.Replace(nameIn, "-", "_", -1)
-	//	}
+	data := []byte(contents)
+	if doc, err := parseDocument(contents); err == nil {
+		if out, err := marshalDocument(doc); err == nil {
+			data = out
+		}
+	}
 	var cfg CloudConfig
-	err := yaml.Unmarshal([]byte(contents), &cfg)
+	err := yaml.Unmarshal(data, &cfg)
 	return &cfg, err
 }
 
@@ -90,6 +97,210 @@ func (cc CloudConfig) String() string {
 	return stringified
 }
 
+// Merge returns the cloud-config of base with every option given in override
+// replacing the same option of base. Nested options are merged option by
+// option, while lists and the network config are replaced as a whole. As
+// options are merged by their presence in the documents rather than by their
+// value, override can also set them back to false or empty.
+func Merge(base, override string) (*CloudConfig, error) {
+	b, err := parseDocument(base)
+	if err != nil {
+		return nil, err
+	}
+	o, err := parseDocument(override)
+	if err != nil {
+		return nil, err
+	}
+
+	merged := mergeMaps(b, o)
+	if network, ok := o["network"]; ok {
+		// Network configs of different versions cannot be mixed.
+		merged["network"] = network
+	}
+	out, err := marshalDocument(merged)
+	if err != nil {
+		return nil, err
+	}
+	var cfg CloudConfig
+	err = yaml.Unmarshal(out, &cfg)
+	return &cfg, err
+}
+
+func mergeMaps(base, override map[interface{}]interface{}) map[interface{}]interface{} {
+	out := make(map[interface{}]interface{}, len(base)+len(override))
+	for k, v := range base {
+		out[k] = v
+	}
+	for k, v := range override {
+		bm, isMap := out[k].(map[interface{}]interface{})
+		if om, ok := v.(map[interface{}]interface{}); ok && isMap {
+			out[k] = mergeMaps(bm, om)
+		} else {
+			out[k] = v
+		}
+	}
+	return out
+}
+
+// plainScalar is the text of a scalar which YAML resolves to something other
+// than a string, e.g. "off" or "0744". Its text, rather than its value, is
+// kept, as the option it is decoded into may be a string.
+type plainScalar string
+
+// yamlNode is a YAML value decoded into maps, lists, strings and
+// plainScalars.
+type yamlNode struct {
+	value interface{}
+}
+
+func (n *yamlNode) UnmarshalYAML(unmarshal func(interface{}) error) error {
+	var v interface{}
+	if err := unmarshal(&v); err != nil {
+		return err
+	}
+	// The decoder cannot hand quoted empty strings to a yamlNode, so they
+	// fail to decode and are taken from v instead.
+	switch v := v.(type) {
+	case map[interface{}]interface{}:
+		var m map[interface{}]*yamlNode
+		if err := unmarshal(&m); err != nil {
+			if _, ok := err.(*yaml.TypeError); !ok {
+				return err
+			}
+		}
+		out := make(map[interface{}]interface{}, len(v))
+		for k, e := range v {
+			if node := m[k]; node != nil {
+				out[k] = node.value
+			} else {
+				out[k] = e
+			}
+		}
+		n.value = out
+	case []interface{}:
+		var l []*yamlNode
+		if err := unmarshal(&l); err != nil {
+			if _, ok := err.(*yaml.TypeError); !ok {
+				return err
+			}
+		}
+		var nodes []*yamlNode
+		for _, node := range l {
+			if node == nil || node.value != "" {
+				nodes = append(nodes, node)
+			}
+		}
+		out := make([]interface{}, len(v))
+		for i, e := range v {
+			if e == "" || len(nodes) == 0 {
+				out[i] = e
+				continue
+			}
+			if nodes[0] != nil {
+				out[i] = nodes[0].value
+			}
+			nodes = nodes[1:]
+		}
+		n.value = out
+	case nil, string:
+		n.value = v
+	default:
+		var text string
+		if err := unmarshal(&text); err != nil {
+			return err
+		}
+		n.value = plainScalar(text)
+	}
+	return nil
+}
+
+// parseDocument decodes a cloud-config into a map, with the hyphens of its
+// keys replaced by underscores.
+func parseDocument(contents string) (map[interface{}]interface{}, error) {
+	var m map[interface{}]interface{}
+	if err := yaml.Unmarshal([]byte(contents), &m); err != nil {
+		return nil, err
+	}
+	var doc yamlNode
+	if err := yaml.Unmarshal([]byte(contents), &doc); err != nil {
+		return nil, err
+	}
+	m, _ = doc.value.(map[interface{}]interface{})
+	return normalizeKeys(m), nil
+}
+
+// normalizeKeys returns the given map with the hyphens of its keys, and those
+// of the maps nested in it, replaced by underscores. The network config keeps
+// its keys, as version 2 configs spell them with hyphens.
+func normalizeKeys(m map[interface{}]interface{}) map[interface{}]interface{} {
+	out := make(map[interface{}]interface{}, len(m))
+	for k, v := range m {
+		if name, ok := k.(string); ok {
+			if name == "network" {
+				out[k] = v
+				continue
+			}
+			k = strings.Replace(name, "-", "_", -1)
+		}
+		out[k] = normalizeValue(v)
+	}
+	return out
+}
+
+func normalizeValue(v interface{}) interface{} {
+	switch v := v.(type) {
+	case map[interface{}]interface{}:
+		return normalizeKeys(v)
+	case []interface{}:
+		out := make([]interface{}, len(v))
+		for i, e := range v {
+			out[i] = normalizeValue(e)
+		}
+		return out
+	default:
+		return v
+	}
+}
+
+// marshalDocument encodes a map decoded by parseDocument, writing its
+// plainScalars as they were given.
+func marshalDocument(m map[interface{}]interface{}) ([]byte, error) {
+	scalars := make(map[string]plainScalar)
+	out, err := yaml.Marshal(placeholders(m, scalars))
+	if err != nil {
+		return nil, err
+	}
+	for placeholder, text := range scalars {
+		out = bytes.Replace(out, []byte(placeholder), []byte(text), 1)
+	}
+	return out, nil
+}
+
+// placeholders returns the given value with every plainScalar replaced by a
+// unique plain string, which is recorded in scalars.
+func placeholders(v interface{}, scalars map[string]plainScalar) interface{} {
+	switch v := v.(type) {
+	case map[interface{}]interface{}:
+		out := make(map[interface{}]interface{}, len(v))
+		for k, e := range v {
+			out[k] = placeholders(e, scalars)
+		}
+		return out
+	case []interface{}:
+		out := make([]interface{}, len(v))
+		for i, e := range v {
+			out[i] = placeholders(e, scalars)
+		}
+		return out
+	case plainScalar:
+		placeholder := fmt.Sprintf("cloudconfig%dscalar", len(scalars))
+		scalars[placeholder] = v
+		return placeholder
+	default:
+		return v
+	}
+}
+
 // IsZero returns whether or not the parameter is the zero value for its type.
 // If the parameter is a struct, only the exported fields are considered.
 func IsZero(c interface{}) bool {
@@ -95,6 +95,80 @@ func TestIsZero(t *testing.T) {
 	}
 }
 
+func TestMerge(t *testing.T) {
+	tests := []struct {
+		base     string
+		override string
+
+		out CloudConfig
+	}{
+		{},
+		{
+			base: "hostname: vendor\nssh_authorized_keys: [a]",
+			out:  CloudConfig{Hostname: "vendor", SSHAuthorizedKeys: []string{"a"}},
+		},
+		{
+			base:     "hostname: vendor\nssh_authorized_keys: [a]",
+			override: "hostname: user",
+			out:      CloudConfig{Hostname: "user", SSHAuthorizedKeys: []string{"a"}},
+		},
+		{
+			base:     "ssh_authorized_keys: [a, b]",
+			override: "ssh_authorized_keys: [c]",
+			out:      CloudConfig{SSHAuthorizedKeys: []string{"c"}},
+		},
+		{
+			base:     "coreos:\n  update:\n    reboot_strategy: \"off\"\n    group: alpha",
+			override: "coreos:\n  update:\n    group: stable",
+			out:      CloudConfig{CoreOS: CoreOS{Update: Update{RebootStrategy: "off", Group: "stable"}}},
+		},
+		{
+			base:     "coreos:\n  update:\n    reboot-strategy: off\nwrite-files:\n  - path: vendor\n    permissions: 0600",
+			override: "coreos:\n  update:\n    reboot_strategy: etcd-lock",
+			out: CloudConfig{
+				CoreOS:     CoreOS{Update: Update{RebootStrategy: "etcd-lock"}},
+				WriteFiles: []File{{Path: "vendor", RawFilePermissions: "0600"}},
+			},
+		},
+		{
+			base:     "ssh_pwauth: true\ndisable_root: true\nhostname: vendor",
+			override: "ssh_pwauth: false\nhostname: \"\"",
+			out:      CloudConfig{DisableRoot: true},
+		},
+		{
+			base:     "network:\n  version: 1\n  config:\n    - type: physical\n      name: eth0",
+			override: "network:\n  version: 2\n  ethernets:\n    eth1: {}",
+			out:      CloudConfig{Network: Network{Version: 2, Ethernets: map[string]NetworkDevice{"eth1": {}}}},
+		},
+	}
+
+	for i, tt := range tests {
+		out, err := Merge(tt.base, tt.override)
+		if err != nil {
+			t.Errorf("bad error (#%d): want %v, got %v", i, nil, err)
+			continue
+		}
+		if !reflect.DeepEqual(tt.out, *out) {
+			t.Errorf("bad result (#%d): want %#v, got %#v", i, tt.out, *out)
+		}
+	}
+}
+
+func TestVendorDataIsEnabled(t *testing.T) {
+	for _, tt := range []struct {
+		enabled string
+		want    bool
+	}{
+		{"", true},
+		{"true", true},
+		{"false", false},
+	} {
+		if got := (VendorData{Enabled: tt.enabled}).IsEnabled(); got != tt.want {
+			t.Errorf("bad result (%q): want %t, got %t", tt.enabled, tt.want, got)
+		}
+	}
+}
+
 func TestAssertStructValid(t *testing.T) {
 	tests := []struct {
 		c interface{}
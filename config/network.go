@@ -0,0 +1,119 @@
+// Copyright 2015 CoreOS, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package config
+
+// Network is the declarative network configuration of the machine, in either
+// version 1 of the cloud-init network config or version 2 (netplan).
+type Network struct {
+	Version int `yaml:"version" json:"version"`
+
+	// Config lists the devices, routes and nameservers of a version 1
+	// config.
+	Config []NetworkConfigEntry `yaml:"config" json:"config,omitempty"`
+
+	// Version 2 configs describe devices by their ID, which is also the name
+	// of every device but ethernets matched by other properties.
+	Ethernets map[string]NetworkDevice `yaml:"ethernets" json:"ethernets,omitempty"`
+	Bonds     map[string]NetworkDevice `yaml:"bonds" json:"bonds,omitempty"`
+	Bridges   map[string]NetworkDevice `yaml:"bridges" json:"bridges,omitempty"`
+	VLANs     map[string]NetworkDevice `yaml:"vlans" json:"vlans,omitempty"`
+}
+
+// NetworkConfigEntry is an entry of a version 1 network config. Its type is
+// one of physical, bond, bridge, vlan, nameserver or route.
+type NetworkConfigEntry struct {
+	Type             string            `yaml:"type" json:"type"`
+	Name             string            `yaml:"name" json:"name,omitempty"`
+	MACAddress       string            `yaml:"mac_address" json:"mac_address,omitempty"`
+	Subnets          []NetworkSubnet   `yaml:"subnets" json:"subnets,omitempty"`
+	BondInterfaces   []string          `yaml:"bond_interfaces" json:"bond_interfaces,omitempty"`
+	BridgeInterfaces []string          `yaml:"bridge_interfaces" json:"bridge_interfaces,omitempty"`
+	Params           map[string]string `yaml:"params" json:"params,omitempty"`
+	VLANLink         string            `yaml:"vlan_link" json:"vlan_link,omitempty"`
+	VLANID           int               `yaml:"vlan_id" json:"vlan_id,omitempty"`
+	Address          []string          `yaml:"address" json:"address,omitempty"`
+	Destination      string            `yaml:"destination" json:"destination,omitempty"`
+	Gateway          string            `yaml:"gateway" json:"gateway,omitempty"`
+	Metric           int               `yaml:"metric" json:"metric,omitempty"`
+	MTU              int               `yaml:"mtu" json:"mtu,omitempty"`
+	Search           []string          `yaml:"search" json:"search,omitempty"`
+}
+
+// NetworkSubnet is the addressing of a device in a version 1 network config.
+type NetworkSubnet struct {
+	Type           string               `yaml:"type" json:"type"`
+	Address        string               `yaml:"address" json:"address,omitempty"`
+	Netmask        string               `yaml:"netmask" json:"netmask,omitempty"`
+	Gateway        string               `yaml:"gateway" json:"gateway,omitempty"`
+	DNSNameservers []string             `yaml:"dns_nameservers" json:"dns_nameservers,omitempty"`
+	DNSSearch      []string             `yaml:"dns_search" json:"dns_search,omitempty"`
+	Routes         []NetworkSubnetRoute `yaml:"routes" json:"routes,omitempty"`
+}
+
+// NetworkSubnetRoute is a route of a subnet in a version 1 network config.
+type NetworkSubnetRoute struct {
+	Network string `yaml:"network" json:"network"`
+	Netmask string `yaml:"netmask" json:"netmask,omitempty"`
+	Gateway string `yaml:"gateway" json:"gateway,omitempty"`
+	Metric  int    `yaml:"metric" json:"metric,omitempty"`
+}
+
+// NetworkDevice is a device of a version 2 network config. Interfaces lists
+// the members of bonds and bridges, while ID and Link describe VLANs.
+type NetworkDevice struct {
+	Match          NetworkMatch         `yaml:"match" json:"match,omitempty"`
+	SetName        string               `yaml:"set-name" json:"set-name,omitempty"`
+	MTU            int                  `yaml:"mtu" json:"mtu,omitempty"`
+	DHCP4          bool                 `yaml:"dhcp4" json:"dhcp4,omitempty"`
+	DHCP6          bool                 `yaml:"dhcp6" json:"dhcp6,omitempty"`
+	DHCP4Overrides NetworkDHCPOverrides `yaml:"dhcp4-overrides" json:"dhcp4-overrides,omitempty"`
+	DHCP6Overrides NetworkDHCPOverrides `yaml:"dhcp6-overrides" json:"dhcp6-overrides,omitempty"`
+	Addresses      []string             `yaml:"addresses" json:"addresses,omitempty"`
+	Gateway4       string               `yaml:"gateway4" json:"gateway4,omitempty"`
+	Gateway6       string               `yaml:"gateway6" json:"gateway6,omitempty"`
+	Nameservers    NetworkNameservers   `yaml:"nameservers" json:"nameservers,omitempty"`
+	Routes         []NetworkRoute       `yaml:"routes" json:"routes,omitempty"`
+	Interfaces     []string             `yaml:"interfaces" json:"interfaces,omitempty"`
+	Parameters     map[string]string    `yaml:"parameters" json:"parameters,omitempty"`
+	ID             int                  `yaml:"id" json:"id,omitempty"`
+	Link           string               `yaml:"link" json:"link,omitempty"`
+}
+
+// NetworkMatch selects the physical device a version 2 ethernet applies to.
+type NetworkMatch struct {
+	Name       string `yaml:"name" json:"name,omitempty"`
+	MACAddress string `yaml:"macaddress" json:"macaddress,omitempty"`
+}
+
+type NetworkNameservers struct {
+	Addresses []string `yaml:"addresses" json:"addresses,omitempty"`
+	Search    []string `yaml:"search" json:"search,omitempty"`
+}
+
+// NetworkDHCPOverrides tunes how a version 2 device uses what it learns
+// through DHCP. UseDNS and UseHostname are either "true" or "false".
+type NetworkDHCPOverrides struct {
+	UseDNS      string `yaml:"use-dns" json:"use-dns,omitempty" valid:"^(true|false)$"`
+	UseHostname string `yaml:"use-hostname" json:"use-hostname,omitempty" valid:"^(true|false)$"`
+	RouteMetric int    `yaml:"route-metric" json:"route-metric,omitempty"`
+}
+
+// NetworkRoute is a route of a device in a version 2 network config.
+type NetworkRoute struct {
+	To     string `yaml:"to" json:"to"`
+	Via    string `yaml:"via" json:"via,omitempty"`
+	Metric int    `yaml:"metric" json:"metric,omitempty"`
+	OnLink bool   `yaml:"on-link" json:"on-link,omitempty"`
+}
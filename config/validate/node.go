@@ -94,14 +94,13 @@ func toNode(v interface{}, c context, n *node) {
 		}
 	case reflect.Map:
 		// Walk over each key in the map and create a node for it.
-		v := v.(map[interface{}]interface{})
-		for k, cv := range v {
-			cn := node{name: fmt.Sprintf("%s", k)}
+		for _, k := range vv.MapKeys() {
+			cn := node{name: fmt.Sprintf("%s", k.Interface())}
 			c, ok := findKey(cn.name, c)
 			if ok {
 				cn.line = c.lineNumber
 			}
-			toNode(cv, c, &cn)
+			toNode(vv.MapIndex(k).Interface(), c, &cn)
 			n.children = append(n.children, cn)
 		}
 	case reflect.Slice:
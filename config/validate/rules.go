@@ -22,6 +22,9 @@ import (
 	"strings"
 
 	"github.com/coreos/coreos-cloudinit/config"
+	"github.com/coreos/coreos-cloudinit/network"
+
+	yaml "gopkg.in/yaml.v2"
 )
 
 type rule func(config node, report *Report)
@@ -30,6 +33,7 @@ type rule func(config node, report *Report)
 var Rules []rule = []rule{
 	checkDiscoveryUrl,
 	checkEncoding,
+	checkNetwork,
 	checkStructure,
 	checkValidity,
 	checkWriteFiles,
@@ -64,6 +68,36 @@ func checkEncoding(cfg node, report *Report) {
 	}
 }
 
+// checkNetwork converts the network config and reports the conflicts in it,
+// which would otherwise only be found once it is applied. Configs of the
+// wrong structure are left to checkStructure.
+func checkNetwork(cfg node, report *Report) {
+	c := cfg.Child("network")
+	if !c.IsValid() {
+		return
+	}
+
+	var netconf config.Network
+	if out, err := yaml.Marshal(c.Interface()); err != nil || yaml.Unmarshal(out, &netconf) != nil {
+		return
+	}
+	if netconf.Version == 0 {
+		return
+	}
+	interfaces, err := network.ProcessNetworkConfig(netconf)
+	if err != nil {
+		report.Error(c.line, err.Error())
+		return
+	}
+	for _, conflict := range network.Validate(interfaces) {
+		if conflict.Fatal {
+			report.Error(c.line, conflict.Message)
+		} else {
+			report.Warning(c.line, conflict.Message)
+		}
+	}
+}
+
 // checkStructure compares the provided config to the empty config.CloudConfig
 // structure. Each node is checked to make sure that it exists in the known
 // structure and that its type is compatible.
@@ -90,7 +124,7 @@ func checkNodeStructure(n, g node, r *Report) {
 				r.Warning(cn.line, fmt.Sprintf("unrecognized key %q", cn.name))
 			}
 		}
-	case reflect.Slice:
+	case reflect.Slice, reflect.Map:
 		for _, cn := range n.children {
 			var cg node
 			c := g.Type().Elem()
@@ -110,7 +144,7 @@ func isCompatible(n, g reflect.Kind) bool {
 	switch g {
 	case reflect.String:
 		return n == reflect.String || n == reflect.Int || n == reflect.Float64 || n == reflect.Bool
-	case reflect.Struct:
+	case reflect.Struct, reflect.Map:
 		return n == reflect.Struct || n == reflect.Map
 	case reflect.Float64:
 		return n == reflect.Float64 || n == reflect.Int
@@ -139,7 +173,7 @@ func checkNodeValidity(n, g node, r *Report) {
 				checkNodeValidity(cn, cg, r)
 			}
 		}
-	case reflect.Slice:
+	case reflect.Slice, reflect.Map:
 		for _, cn := range n.children {
 			var cg node
 			c := g.Type().Elem()
@@ -98,6 +98,47 @@ func TestCheckEncoding(t *testing.T) {
 	}
 }
 
+func TestCheckNetwork(t *testing.T) {
+	tests := []struct {
+		config string
+
+		entries []Entry
+	}{
+		{},
+		{
+			config: "network:\n  ethernets:\n    eth0:\n      dhcp4: true",
+		},
+		{
+			config: "network:\n  version: 2\n  ethernets:\n    eth0:\n      addresses: [10.0.0.2/24]",
+		},
+		{
+			config:  "network:\n  version: 2\n  ethernets:\n    eth0:\n      addresses: [10.0.0.2]",
+			entries: []Entry{{entryError, "could not parse \"10.0.0.2\" as address of \"eth0\": bad netmask \"\"", 1}},
+		},
+		{
+			config: "network:\n  version: 2\n  ethernets:\n    eth0:\n      addresses: [10.0.0.2/24]\n    eth1:\n      addresses: [10.0.0.2/24]\n  vlans:\n    vlan10:\n      id: 10\n      link: eth0\n      addresses: [10.0.0.3/16]",
+			entries: []Entry{
+				{entryError, "address 10.0.0.2 is configured on both \"eth0\" and \"eth1\"", 1},
+				{entryWarning, "subnet of 10.0.0.3/16 on \"vlan10\" overlaps subnet of 10.0.0.2/24 on \"eth0\"", 1},
+				{entryWarning, "subnet of 10.0.0.3/16 on \"vlan10\" overlaps subnet of 10.0.0.2/24 on \"eth1\"", 1},
+			},
+		},
+	}
+
+	for i, tt := range tests {
+		r := Report{}
+		n, err := parseCloudConfig([]byte(tt.config), &r)
+		if err != nil {
+			panic(err)
+		}
+		checkNetwork(n, &r)
+
+		if e := r.Entries(); !reflect.DeepEqual(tt.entries, e) {
+			t.Errorf("bad report (%d, %q): want %#v, got %#v", i, tt.config, tt.entries, e)
+		}
+	}
+}
+
 func TestCheckStructure(t *testing.T) {
 	tests := []struct {
 		config string
@@ -128,6 +169,19 @@ func TestCheckStructure(t *testing.T) {
 			entries: []Entry{{entryWarning, "deprecated key \"proxy\" (etcd2 options no longer work for etcd)", 3}},
 		},
 
+		// Test for keys within maps
+		{
+			config: "network:\n  ethernets:\n    eth0:\n      dhcp4: true",
+		},
+		{
+			config:  "network:\n  ethernets:\n    eth0:\n      bad: true",
+			entries: []Entry{{entryWarning, "unrecognized key \"bad\"", 4}},
+		},
+		{
+			config:  "network:\n  bonds:\n    bond0:\n      interfaces: eth0",
+			entries: []Entry{{entryWarning, "incorrect type for \"interfaces\" (want []string)", 4}},
+		},
+
 		// Test for error on list of nodes
 		{
 			config: "coreos:\n  units:\n    - hello\n    - goodbye",
@@ -307,6 +361,11 @@ func TestCheckValidity(t *testing.T) {
 			entries: []Entry{{entryError, "invalid value lol", 3}},
 		},
 
+		// map
+		{
+			config: "network:\n  vlans:\n    vlan10:\n      id: 10\n      link: eth0",
+		},
+
 		// struct
 		{
 			config: "coreos:\n  update:\n    reboot_strategy: off",
@@ -0,0 +1,26 @@
+// Copyright 2015 CoreOS, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package config
+
+// VendorData controls how the vendor-data published by the datasource is
+// treated. Vendor-data is applied unless user-data explicitly disables it.
+type VendorData struct {
+	Enabled string `yaml:"enabled" valid:"^(true|false)$"`
+}
+
+// IsEnabled returns whether or not vendor-data should be applied.
+func (vd VendorData) IsEnabled() bool {
+	return vd.Enabled != "false"
+}
@@ -17,28 +17,40 @@ package main
 import (
 	"bytes"
 	"compress/gzip"
+	"encoding/json"
 	"flag"
 	"fmt"
 	"io/ioutil"
 	"log"
 	"os"
+	"path"
 	"runtime"
-	"sync"
+	"sort"
+	"strings"
 	"time"
 
 	"github.com/coreos/coreos-cloudinit/config"
 	"github.com/coreos/coreos-cloudinit/config/validate"
 	"github.com/coreos/coreos-cloudinit/datasource"
+	"github.com/coreos/coreos-cloudinit/datasource/azure"
+	"github.com/coreos/coreos-cloudinit/datasource/cache"
 	"github.com/coreos/coreos-cloudinit/datasource/configdrive"
+	"github.com/coreos/coreos-cloudinit/datasource/detect"
 	"github.com/coreos/coreos-cloudinit/datasource/file"
-	"github.com/coreos/coreos-cloudinit/datasource/metadata/openstack"
+	"github.com/coreos/coreos-cloudinit/datasource/instancedata"
+	"github.com/coreos/coreos-cloudinit/datasource/metadata/cloudsigma"
+	"github.com/coreos/coreos-cloudinit/datasource/metadata/cloudstack"
 	"github.com/coreos/coreos-cloudinit/datasource/metadata/digitalocean"
 	"github.com/coreos/coreos-cloudinit/datasource/metadata/ec2"
+	"github.com/coreos/coreos-cloudinit/datasource/metadata/hetzner"
+	"github.com/coreos/coreos-cloudinit/datasource/metadata/openstack"
 	"github.com/coreos/coreos-cloudinit/datasource/metadata/packet"
+	"github.com/coreos/coreos-cloudinit/datasource/metadata/scaleway"
+	"github.com/coreos/coreos-cloudinit/datasource/metadata/vultr"
 	"github.com/coreos/coreos-cloudinit/datasource/proc_cmdline"
 	"github.com/coreos/coreos-cloudinit/datasource/url"
 
-	//	"github.com/coreos/coreos-cloudinit/datasource/vmware"
+	"github.com/coreos/coreos-cloudinit/datasource/vmware"
 	"github.com/coreos/coreos-cloudinit/datasource/waagent"
 	"github.com/coreos/coreos-cloudinit/initialize"
 	"github.com/coreos/coreos-cloudinit/network"
@@ -50,33 +62,70 @@ var (
 	datasourceInterval    = 100 * time.Millisecond
 	datasourceMaxInterval = 30 * time.Second
 	datasourceTimeout     = 5 * time.Minute
+	datasourceGrace       = 2 * time.Second
 )
 
+// defaultDatasourcePriority lists datasource types from most to least
+// preferred. Local sources win over network services since they can't be
+// spoofed by other tenants and don't depend on the network being up.
+var defaultDatasourcePriority = []string{
+	"local-file",
+	"cloud-drive",
+	"waagent",
+	"azure",
+	"vmware",
+	"server-context",
+	"proc-cmdline",
+	"url",
+	"openstack-metadata-service",
+	"ec2-metadata-service",
+	"digitalocean-metadata-service",
+	"packet-metadata-service",
+	"hetzner-metadata-service",
+	"vultr-metadata-service",
+	"scaleway-metadata-service",
+	"cloudstack-metadata-service",
+}
+
 var (
 	flags = struct {
 		printVersion  bool
 		ignoreFailure bool
 		sources       struct {
-			file               string
-			configDrive        string
-			waagent            string
-			metadataService    bool
-			ec2MetadataService string
-			openstackMetadataService string
-			//			cloudSigmaMetadataService   bool
+			file                        string
+			configDrive                 string
+			configDriveDevice           bool
+			waagent                     string
+			azure                       string
+			azureIMDS                   string
+			azureWireServer             string
+			metadataService             bool
+			ec2MetadataService          string
+			openstackMetadataService    string
+			cloudSigmaMetadataService   bool
 			digitalOceanMetadataService string
 			packetMetadataService       string
+			hetznerMetadataService      string
+			vultrMetadataService        string
+			scalewayMetadataService     string
+			cloudStackMetadataService   bool
 			url                         string
 			procCmdLine                 bool
-			//			vmware                      bool
+			vmware                      bool
 		}
-		convertNetconf string
-		workspace      string
-		sshKeyName     string
-		oem            string
-		validate       bool
-		timeout        string
-		dstimeout      string
+		autoDetect      bool
+		forceRefresh    bool
+		convertNetconf  string
+		networkRenderer string
+		workspace       string
+		sshKeyName      string
+		oem             string
+		validate        bool
+		timeout         string
+		dstimeout       string
+		priority        string
+		priorityFile    string
+		grace           string
 	}{}
 	version = "was not built properly"
 )
@@ -86,22 +135,36 @@ func init() {
 	flag.BoolVar(&flags.ignoreFailure, "ignore-failure", false, "Exits with 0 status in the event of malformed input from user-data")
 	flag.StringVar(&flags.sources.file, "from-file", "", "Read user-data from provided file")
 	flag.StringVar(&flags.sources.configDrive, "from-configdrive", "", "Read data from provided cloud-drive directory")
+	flag.BoolVar(&flags.sources.configDriveDevice, "from-configdrive-device", false, fmt.Sprintf("Find the block device labelled %s and read data from it, mounting it read-only while it is read", configdrive.Label))
 	flag.StringVar(&flags.sources.waagent, "from-waagent", "", "Read data from provided waagent directory")
+	flag.StringVar(&flags.sources.azure, "from-azure", "", "Read data from the Azure provisioning media mounted at the provided directory, the Azure instance metadata service and wire server")
+	flag.StringVar(&flags.sources.azureIMDS, "azure-imds-url", azure.DefaultIMDSAddress, "Address of the Azure instance metadata service")
+	flag.StringVar(&flags.sources.azureWireServer, "azure-wireserver-url", azure.DefaultWireServerAddress, "Address of the Azure wire server")
 	flag.StringVar(&flags.sources.ec2MetadataService, "from-ec2-metadata", "", "Download EC2 data from the provided url")
-	//	flag.BoolVar(&flags.sources.cloudSigmaMetadataService, "from-cloudsigma-metadata", false, "Download data from CloudSigma server context")
+	flag.BoolVar(&flags.sources.cloudSigmaMetadataService, "from-cloudsigma-metadata", false, fmt.Sprintf("Read data from the CloudSigma server context on %s", cloudsigma.DefaultDevice))
 	flag.StringVar(&flags.sources.digitalOceanMetadataService, "from-digitalocean-metadata", "", "Download DigitalOcean data from the provided url")
 	flag.StringVar(&flags.sources.openstackMetadataService, "from-openstack-metadata", "", "Download OpenStack data from the provided url")
 	flag.StringVar(&flags.sources.packetMetadataService, "from-packet-metadata", "", "Download Packet data from metadata service")
+	flag.StringVar(&flags.sources.hetznerMetadataService, "from-hetzner-metadata", "", "Download Hetzner Cloud data from the provided url")
+	flag.StringVar(&flags.sources.vultrMetadataService, "from-vultr-metadata", "", "Download Vultr data from the provided url")
+	flag.StringVar(&flags.sources.scalewayMetadataService, "from-scaleway-metadata", "", "Download Scaleway data from the provided url, connecting from a privileged port")
+	flag.BoolVar(&flags.sources.cloudStackMetadataService, "from-cloudstack-metadata", false, "Download CloudStack data from the virtual router found in the DHCP leases")
 	flag.StringVar(&flags.sources.url, "from-url", "", "Download user-data from provided url")
-	flag.BoolVar(&flags.sources.procCmdLine, "from-proc-cmdline", false, fmt.Sprintf("Parse %s for '%s=<url>', using the cloud-config served by an HTTP GET to <url>", proc_cmdline.ProcCmdlineLocation, proc_cmdline.ProcCmdlineCloudConfigFlag))
-	//	flag.BoolVar(&flags.sources.vmware, "from-vmware-guestinfo", false, "Read data from VMware guestinfo")
+	flag.BoolVar(&flags.sources.procCmdLine, "from-proc-cmdline", false, fmt.Sprintf("Parse %s for '%s=<url>', using the cloud-config served by an HTTP GET to <url>, or for an inline base64 encoded '%s=<data>', and use its dracut ip= network config", proc_cmdline.ProcCmdlineLocation, proc_cmdline.ProcCmdlineCloudConfigFlag, proc_cmdline.ProcCmdlineCloudConfigDataFlag))
+	flag.BoolVar(&flags.sources.vmware, "from-vmware-guestinfo", false, "Read data from VMware guestinfo")
 	flag.StringVar(&flags.oem, "oem", "", "Use the settings specific to the provided OEM")
+	flag.BoolVar(&flags.autoDetect, "auto-detect", false, "Detect the platform from DMI data, block device labels and hypervisor hints and use its datasources. Implied when no other datasource is provided")
 	flag.StringVar(&flags.convertNetconf, "convert-netconf", "", "Read the network config provided in cloud-drive and translate it from the specified format into networkd unit files")
+	flag.StringVar(&flags.networkRenderer, "network-renderer", "", fmt.Sprintf("Apply the network config with the provided renderer, one of %q, %q or %q (default: whichever of NetworkManager and ifupdown is enabled, %q otherwise)", initialize.NetworkRendererNetworkd, initialize.NetworkRendererNetworkManager, initialize.NetworkRendererIfupdown, initialize.NetworkRendererNetworkd))
 	flag.StringVar(&flags.workspace, "workspace", "/var/lib/cloudinit", "Base directory where cloudinit should use to store data")
+	flag.BoolVar(&flags.forceRefresh, "force-refresh", false, "Do not fall back to the datasource data cached in the workspace when no datasource is available")
 	flag.StringVar(&flags.sshKeyName, "ssh-key-name", initialize.DefaultSSHKeyName, "Add SSH keys to the system with the given name")
 	flag.BoolVar(&flags.validate, "validate", false, "[EXPERIMENTAL] Validate the user-data but do not apply it to the system")
 	flag.StringVar(&flags.timeout, "timeout", "60s", "Timeout to wait for all datasource metadata")
 	flag.StringVar(&flags.dstimeout, "dstimeout", "10s", "Timeout to wait for single datasource metadata")
+	flag.StringVar(&flags.priority, "datasource-priority", "", fmt.Sprintf("Comma-separated list of datasource types, most preferred first (default %q)", strings.Join(defaultDatasourcePriority, ",")))
+	flag.StringVar(&flags.priorityFile, "datasource-priority-file", "", "Read the datasource priority list from the provided file, one type per line")
+	flag.StringVar(&flags.grace, "datasource-grace", "2s", "Time to wait for a more preferred datasource once a less preferred one is available")
 }
 
 type oemConfig map[string]string
@@ -121,18 +184,48 @@ var (
 			"convert-netconf":  "debian",
 		},
 		"azure": oemConfig{
+			"from-azure":      "/media/azure",
+			"convert-netconf": "azure",
+		},
+		"waagent": oemConfig{
 			"from-waagent": "/var/lib/waagent",
 		},
-		//		"cloudsigma": oemConfig{
-		//			"from-cloudsigma-metadata": "true",
-		//		},
+		"cloudsigma": oemConfig{
+			"from-cloudsigma-metadata": "true",
+		},
 		"packet": oemConfig{
 			"from-packet-metadata": "https://metadata.packet.net/",
 		},
-		//		"vmware": oemConfig{
-		//			"from-vmware-guestinfo": "true",
-		//			"convert-netconf":       "vmware",
-		//		},
+		"hetzner": oemConfig{
+			"from-hetzner-metadata": hetzner.DefaultAddress,
+			"convert-netconf":       "hetzner",
+		},
+		"vultr": oemConfig{
+			"from-vultr-metadata": vultr.DefaultAddress,
+			"convert-netconf":     "vultr",
+		},
+		"scaleway": oemConfig{
+			"from-scaleway-metadata": scaleway.DefaultAddress,
+			"convert-netconf":        "scaleway",
+		},
+		"cloudstack": oemConfig{
+			"from-cloudstack-metadata": "true",
+		},
+		"vmware": oemConfig{
+			"from-vmware-guestinfo": "true",
+			"convert-netconf":       "vmware",
+		},
+	}
+
+	// detectedConfigs holds the settings for platforms reported by the
+	// detector which have no OEM of their own.
+	detectedConfigs = map[string]oemConfig{
+		detect.ConfigDrive: oemConfig{
+			"from-configdrive-device": "true",
+		},
+		detect.OpenStack: oemConfig{
+			"from-openstack-metadata": openstack.DefaultAddress,
+		},
 	}
 )
 
@@ -146,6 +239,10 @@ func main() {
 		runtime.GOMAXPROCS(1)
 	}
 
+	if len(os.Args) > 1 && os.Args[1] == "query" {
+		os.Exit(query(os.Args[2:]))
+	}
+
 	flag.Parse()
 
 	if c, ok := oemConfigs[flags.oem]; ok {
@@ -166,6 +263,17 @@ func main() {
 		os.Exit(0)
 	}
 
+	if flags.oem == "" {
+		if platforms, err := proc_cmdline.Datasources(proc_cmdline.ProcCmdlineLocation); err == nil && len(platforms) > 0 {
+			log.Printf("Using the datasources of %q selected on the kernel command line\n", platforms)
+			applyPlatformConfigs(platforms)
+		}
+	}
+
+	if flags.autoDetect || len(getDatasources()) == 0 {
+		autoDetect(detect.NewDetector(detect.DefaultRoot))
+	}
+
 	datasourceTimeout, err = time.ParseDuration(flags.timeout)
 	if err != nil {
 		fmt.Printf("Invalid value to --timeout: %q\n", err)
@@ -176,30 +284,74 @@ func main() {
 		fmt.Printf("Invalid value to --dstimeout: %q\n", err)
 		os.Exit(1)
 	}
+	datasourceGrace, err = time.ParseDuration(flags.grace)
+	if err != nil {
+		fmt.Printf("Invalid value to --datasource-grace: %q\n", err)
+		os.Exit(1)
+	}
+	priority, err := getDatasourcePriority()
+	if err != nil {
+		fmt.Printf("Invalid value to --datasource-priority-file: %q\n", err)
+		os.Exit(1)
+	}
 
 	switch flags.convertNetconf {
 	case "":
 	case "debian":
 	case "digitalocean":
 	case "packet":
-		//	case "vmware":
+	case "vmware":
+	case "hetzner":
+	case "vultr":
+	case "scaleway":
+	case "azure":
+	case "cmdline":
 	default:
-		fmt.Printf("Invalid option to -convert-netconf: '%s'. Supported options: 'debian, digitalocean, packet, vmware'\n", flags.convertNetconf)
+		fmt.Printf("Invalid option to -convert-netconf: '%s'. Supported options: 'debian, digitalocean, packet, vmware, hetzner, vultr, scaleway, azure, cmdline'\n", flags.convertNetconf)
+		os.Exit(2)
+	}
+
+	switch flags.networkRenderer {
+	case "":
+	case initialize.NetworkRendererNetworkd:
+	case initialize.NetworkRendererNetworkManager:
+	case initialize.NetworkRendererIfupdown:
+	default:
+		fmt.Printf("Invalid option to -network-renderer: '%s'. Supported options: '%s, %s, %s'\n", flags.networkRenderer, initialize.NetworkRendererNetworkd, initialize.NetworkRendererNetworkManager, initialize.NetworkRendererIfupdown)
 		os.Exit(2)
 	}
 
 	dss := getDatasources()
 	if len(dss) == 0 {
-		fmt.Println("Provide at least one of --from-file, --from-configdrive, --from-openstack-metadata, --from-ec2-metadata, --from-cloudsigma-metadata, --from-packet-metadata, --from-digitalocean-metadata, --from-vmware-guestinfo, --from-waagent, --from-url or --from-proc-cmdline")
+		fmt.Println("Provide at least one of --from-file, --from-configdrive, --from-configdrive-device, --from-openstack-metadata, --from-ec2-metadata, --from-cloudsigma-metadata, --from-packet-metadata, --from-digitalocean-metadata, --from-hetzner-metadata, --from-vultr-metadata, --from-scaleway-metadata, --from-cloudstack-metadata, --from-vmware-guestinfo, --from-waagent, --from-azure, --from-url or --from-proc-cmdline, or run on a platform which can be detected")
 		os.Exit(2)
 	}
-  fmt.Printf("%#+v\n", dss)
-	ds := selectDatasource(dss)
+	fmt.Printf("%#+v\n", dss)
+	cacheDir := path.Join(flags.workspace, "datasource")
+	detector := detect.NewDetector(detect.DefaultRoot)
+	systemUUID := detector.SystemUUID()
+
+	ds := selectDatasource(sortDatasources(dss, priority))
+	cached := false
+	if ds == nil && !flags.forceRefresh {
+		if c := cache.NewDatasource(cacheDir, systemUUID); c.IsAvailable() {
+			log.Println("No datasources available in time, using the data cached by a previous boot")
+			ds = c
+			cached = true
+		}
+	}
 	if ds == nil {
 		log.Println("No datasources available in time")
 		os.Exit(1)
 	}
 
+	// The datasource may mount its media to read the data, which has to be
+	// unmounted on every exit.
+	exit := func(code int) {
+		unmount(ds)
+		os.Exit(code)
+	}
+
 	log.Printf("Fetching user-data from datasource of type %q\n", ds.Type())
 	userdataBytes, err := ds.FetchUserdata()
 	if err != nil {
@@ -219,24 +371,56 @@ func main() {
 			ret = 1
 		}
 		if flags.validate {
-			os.Exit(ret)
+			exit(ret)
 		}
 	} else {
 		log.Printf("Failed while validating user_data (%q)\n", err)
 		if flags.validate {
-			os.Exit(1)
+			exit(1)
 		}
 	}
 
+	log.Printf("Fetching vendor-data from datasource of type %q\n", ds.Type())
+	vendordataBytes, err := ds.FetchVendordata()
+	if err != nil {
+		log.Printf("Failed fetching vendor-data from datasource: %v. Continuing...\n", err)
+	}
+	vendordataBytes, err = decompressIfGzip(vendordataBytes)
+	if err != nil {
+		log.Printf("Failed decompressing vendor-data from datasource: %v. Continuing...\n", err)
+	}
+
 	log.Printf("Fetching meta-data from datasource of type %q\n", ds.Type())
 	metadata, err := ds.FetchMetadata()
 	if err != nil {
 		log.Printf("Failed fetching meta-data from datasource: %v\n", err)
-		os.Exit(1)
+		exit(1)
+	}
+
+	if !cached && !failure {
+		switch err := cache.Save(cacheDir, systemUUID, ds, userdataBytes, vendordataBytes, metadata); err {
+		case nil:
+		case cache.ErrNoInstanceID:
+			log.Printf("Not caching datasource data: %v\n", err)
+		default:
+			log.Printf("Failed caching datasource data in %q: %v\n", cacheDir, err)
+		}
+	}
+
+	platform := instancedata.Platform(ds.Type())
+	if platform == "" {
+		if platforms := detector.Detect(); len(platforms) > 0 {
+			platform = platforms[0]
+		}
+	}
+	data := instancedata.New(platform, ds.Type(), metadata)
+	if err := instancedata.Write(instancedata.DefaultDir, data, userdataBytes, vendordataBytes); err != nil {
+		log.Printf("Failed writing instance-data to %q: %v\n", instancedata.DefaultDir, err)
 	}
 
 	// Apply environment to user-data
 	env := initialize.NewEnvironment("/", ds.ConfigRoot(), flags.workspace, flags.sshKeyName, metadata)
+	env.SetNetworkRenderer(flags.networkRenderer)
 	userdata := env.Apply(string(userdataBytes))
 
 	var ccu *config.CloudConfig
@@ -244,7 +428,7 @@ func main() {
 	switch ud, err := initialize.ParseUserData(userdata); err {
 	case initialize.ErrIgnitionConfig:
 		fmt.Printf("Detected an Ignition config. Exiting...")
-		os.Exit(0)
+		exit(0)
 	case nil:
 		switch t := ud.(type) {
 		case *config.CloudConfig:
@@ -257,47 +441,126 @@ func main() {
 		failure = true
 	}
 
-	log.Println("Merging cloud-config from meta-data and user-data")
-	cc := mergeConfigs(ccu, metadata)
-
-	var ifaces []network.InterfaceGenerator
-	if flags.convertNetconf != "" {
-		var err error
-		switch flags.convertNetconf {
-		case "debian":
-			ifaces, err = network.ProcessDebianNetconf(metadata.NetworkConfig.([]byte))
-		case "digitalocean":
-			ifaces, err = network.ProcessDigitalOceanNetconf(metadata.NetworkConfig.(digitalocean.Metadata))
-		case "packet":
-			ifaces, err = network.ProcessPacketNetconf(metadata.NetworkConfig.(packet.NetworkData))
-			//		case "vmware":
-			//			ifaces, err = network.ProcessVMwareNetconf(metadata.NetworkConfig.(map[string]string))
+	var ccv *config.CloudConfig
+	var vendordata string
+	var vendorScript *config.Script
+	if ccu != nil && !ccu.VendorData.IsEnabled() {
+		log.Println("Vendor-data disabled by user-data")
+	} else {
+		vendordata = env.Apply(string(vendordataBytes))
+		switch vd, err := initialize.ParseUserData(vendordata); err {
+		case initialize.ErrIgnitionConfig:
+			log.Println("Detected an Ignition config in vendor-data. Ignoring...")
+		case nil:
+			switch t := vd.(type) {
+			case *config.CloudConfig:
+				ccv = t
+			case *config.Script:
+				vendorScript = t
+			}
 		default:
-			err = fmt.Errorf("Unsupported network config format %q", flags.convertNetconf)
+			log.Printf("Failed to parse vendor-data: %v. Continuing...\n", err)
 		}
-		if err != nil {
-			log.Printf("Failed to generate interfaces: %v\n", err)
-			os.Exit(1)
+	}
+
+	log.Println("Merging cloud-config from meta-data, vendor-data and user-data")
+	cc := mergeConfigs(mergeVendorConfig(ccv, ccu, vendordata, userdata), metadata)
+
+	// The network config of the kernel command line is always converted, as
+	// it is explicitly given for the machine.
+	if _, ok := metadata.NetworkConfig.(proc_cmdline.NetworkConfig); ok && flags.convertNetconf == "" {
+		flags.convertNetconf = "cmdline"
+	}
+
+	// A network config given in the cloud-config takes precedence over the
+	// one of the datasource, which is used as is when it is already in the
+	// cloud-init format.
+	var ifaces []network.InterfaceGenerator
+	netconf, isNetworkConfig := metadata.NetworkConfig.(config.Network)
+	switch {
+	case cc.Network.Version != 0:
+		log.Println("Using the network config of the cloud-config")
+		ifaces, err = network.ProcessNetworkConfig(cc.Network)
+	case isNetworkConfig:
+		ifaces, err = network.ProcessNetworkConfig(netconf)
+	case flags.convertNetconf == "debian":
+		ifaces, err = network.ProcessDebianNetconf(metadata.NetworkConfig.([]byte))
+	case flags.convertNetconf == "digitalocean":
+		ifaces, err = network.ProcessDigitalOceanNetconf(metadata.NetworkConfig.(digitalocean.Metadata))
+	case flags.convertNetconf == "packet":
+		ifaces, err = network.ProcessPacketNetconf(metadata.NetworkConfig.(packet.NetworkData))
+	case flags.convertNetconf == "vmware":
+		ifaces, err = network.ProcessVMwareNetconf(metadata.NetworkConfig.(map[string]string))
+	case flags.convertNetconf == "hetzner":
+		ifaces, err = network.ProcessHetznerNetconf(metadata.NetworkConfig.(hetzner.NetworkConfig))
+	case flags.convertNetconf == "vultr":
+		ifaces, err = network.ProcessVultrNetconf(metadata.NetworkConfig.(vultr.Metadata))
+	case flags.convertNetconf == "scaleway":
+		ifaces, err = network.ProcessScalewayNetconf(metadata.NetworkConfig.(scaleway.Metadata))
+	case flags.convertNetconf == "azure":
+		// The instance metadata service may not have been reachable.
+		if azureNetconf, ok := metadata.NetworkConfig.(azure.Network); ok {
+			ifaces, err = network.ProcessAzureNetconf(azureNetconf)
 		}
+	case flags.convertNetconf == "cmdline":
+		ifaces, err = network.ProcessCmdlineNetconf(metadata.NetworkConfig.(proc_cmdline.NetworkConfig))
+	case flags.convertNetconf != "":
+		err = fmt.Errorf("Unsupported network config format %q", flags.convertNetconf)
+	}
+	if err != nil {
+		log.Printf("Failed to generate interfaces: %v\n", err)
+		exit(1)
 	}
 
 	if err = initialize.Apply(cc, ifaces, env); err != nil {
 		log.Printf("Failed to apply cloud-config: %v\n", err)
-		os.Exit(1)
+		exit(1)
+	}
+
+	if p, ok := ds.(datasource.PasswordProvider); ok {
+		if err = setDefaultUserPassword(p, cc.SystemInfo.DefaultUser.Name); err != nil {
+			log.Printf("Failed setting the default user's password: %v\n", err)
+			failure = true
+		}
+	}
+
+	if r, ok := ds.(datasource.ReadyReporter); ok {
+		if err = r.ReportReady(); err != nil {
+			log.Printf("Failed reporting ready to the platform: %v\n", err)
+			failure = true
+		}
+	}
+
+	if vendorScript != nil {
+		if err = runScript(*vendorScript, env); err != nil {
+			log.Printf("Failed to run vendor-data script: %v. Continuing...\n", err)
+			failure = true
+		}
 	}
 
 	if script != nil {
 		if err = runScript(*script, env); err != nil {
 			log.Printf("Failed to run script: %v\n", err)
-			os.Exit(1)
+			exit(1)
 		}
 	}
 
+	unmount(ds)
+
 	if failure && !flags.ignoreFailure {
 		os.Exit(1)
 	}
 }
 
+// unmount unmounts the media the datasource mounted to read its data, if any.
+func unmount(ds datasource.Datasource) {
+	if u, ok := ds.(datasource.Unmounter); ok {
+		if err := u.Unmount(); err != nil {
+			log.Printf("Failed unmounting the datasource: %v\n", err)
+		}
+	}
+}
+
 // mergeConfigs merges certain options from md (meta-data from the datasource)
 // onto cc (a CloudConfig derived from user-data), if they are not already set
 // on cc (i.e. user-data always takes precedence)
@@ -319,6 +582,68 @@ func mergeConfigs(cc *config.CloudConfig, md datasource.Metadata) (out config.Cl
 	return
 }
 
+// setDefaultUserPassword sets the password handed out by the datasource as the
+// password of the default user.
+func setDefaultUserPassword(p datasource.PasswordProvider, user string) error {
+	password, err := p.FetchPassword()
+	if err != nil || password == "" {
+		return err
+	}
+	if user == "" {
+		log.Println("Ignoring the password from the datasource since no default user is configured")
+		return nil
+	}
+	log.Printf("Setting '%s' user's password\n", user)
+	return system.SetUserPlainPassword(user, password)
+}
+
+// mergeVendorConfig merges ccu (a CloudConfig derived from user-data) onto
+// ccv (a CloudConfig derived from vendor-data). Any option given in
+// user-data, whose document is needed to tell which options it gives,
+// overrides the same option from vendor-data.
+func mergeVendorConfig(ccv, ccu *config.CloudConfig, vendordata, userdata string) *config.CloudConfig {
+	switch {
+	case ccv == nil:
+		return ccu
+	case ccu == nil:
+		return ccv
+	}
+	out, err := config.Merge(vendordata, userdata)
+	if err != nil {
+		log.Printf("Failed merging vendor-data and user-data: %v. Ignoring vendor-data...\n", err)
+		return ccu
+	}
+	return out
+}
+
+// autoDetect applies the settings of every platform reported by the detector.
+// Settings which were already provided are left untouched, so explicit flags
+// and earlier (more specific) platforms take precedence.
+func autoDetect(d *detect.Detector) {
+	log.Println("Detecting platform")
+	applyPlatformConfigs(d.Detect())
+}
+
+// applyPlatformConfigs applies the settings of platforms, most specific
+// first, leaving settings which were already provided untouched.
+func applyPlatformConfigs(platforms []string) {
+	for _, platform := range platforms {
+		c, ok := detectedConfigs[platform]
+		if !ok {
+			c, ok = oemConfigs[platform]
+		}
+		if !ok {
+			log.Printf("No datasource available for platform %q\n", platform)
+			continue
+		}
+		for k, v := range c {
+			if f := flag.Lookup(k); f != nil && f.Value.String() == f.DefValue {
+				flag.Set(k, v)
+			}
+		}
+	}
+}
+
 // getDatasources creates a slice of possible Datasources for cloudinit based
 // on the different source command-line flags.
 func getDatasources() []datasource.Datasource {
@@ -332,58 +657,146 @@ func getDatasources() []datasource.Datasource {
 	if flags.sources.configDrive != "" {
 		dss = append(dss, configdrive.NewDatasource(flags.sources.configDrive))
 	}
+	if flags.sources.configDriveDevice {
+		dss = append(dss, configdrive.NewMountedDatasource())
+	}
 	if flags.sources.metadataService {
 		dss = append(dss, ec2.NewDatasource(ec2.DefaultAddress))
 	}
 	if flags.sources.openstackMetadataService != "" {
-	  dss = append(dss, openstack.NewDatasource(flags.sources.openstackMetadataService))
-  }
+		dss = append(dss, openstack.NewDatasource(flags.sources.openstackMetadataService))
+	}
 	if flags.sources.ec2MetadataService != "" {
 		dss = append(dss, ec2.NewDatasource(flags.sources.ec2MetadataService))
 	}
-	//	if flags.sources.cloudSigmaMetadataService {
-	//		dss = append(dss, cloudsigma.NewServerContextService())
-	//	}
+	if flags.sources.cloudSigmaMetadataService {
+		dss = append(dss, cloudsigma.NewServerContextService(cloudsigma.DefaultDevice))
+	}
 	if flags.sources.digitalOceanMetadataService != "" {
 		dss = append(dss, digitalocean.NewDatasource(flags.sources.digitalOceanMetadataService))
 	}
 	if flags.sources.waagent != "" {
 		dss = append(dss, waagent.NewDatasource(flags.sources.waagent))
 	}
+	if flags.sources.azure != "" {
+		dss = append(dss, azure.NewDatasource(flags.sources.azure, flags.sources.azureIMDS, flags.sources.azureWireServer))
+	}
 	if flags.sources.packetMetadataService != "" {
 		dss = append(dss, packet.NewDatasource(flags.sources.packetMetadataService))
 	}
+	if flags.sources.hetznerMetadataService != "" {
+		dss = append(dss, hetzner.NewDatasource(flags.sources.hetznerMetadataService))
+	}
+	if flags.sources.vultrMetadataService != "" {
+		dss = append(dss, vultr.NewDatasource(flags.sources.vultrMetadataService))
+	}
+	if flags.sources.scalewayMetadataService != "" {
+		dss = append(dss, scaleway.NewDatasource(flags.sources.scalewayMetadataService))
+	}
+	if flags.sources.cloudStackMetadataService {
+		dss = append(dss, cloudstack.NewDatasource(cloudstack.DefaultLeaseFiles))
+	}
 	if flags.sources.procCmdLine {
 		dss = append(dss, proc_cmdline.NewDatasource())
 	}
-	//	if flags.sources.vmware {
-	//		dss = append(dss, vmware.NewDatasource())
-	//	}
+	if flags.sources.vmware {
+		dss = append(dss, vmware.NewDatasource())
+	}
 	return dss
 }
 
+// getDatasourcePriority returns the datasource priority list given on the
+// command line or in the priority file, or the default list if neither is
+// provided.
+func getDatasourcePriority() ([]string, error) {
+	var priority []string
+	switch {
+	case flags.priority != "":
+		priority = strings.Split(flags.priority, ",")
+	case flags.priorityFile != "":
+		data, err := ioutil.ReadFile(flags.priorityFile)
+		if err != nil {
+			return nil, err
+		}
+		priority = strings.Split(string(data), "\n")
+	default:
+		return defaultDatasourcePriority, nil
+	}
+
+	types := make([]string, 0, len(priority))
+	for _, t := range priority {
+		if t = strings.TrimSpace(t); t != "" && !strings.HasPrefix(t, "#") {
+			types = append(types, t)
+		}
+	}
+	return types, nil
+}
+
+type byPriority struct {
+	sources []datasource.Datasource
+	rank    map[string]int
+}
+
+func (p byPriority) Len() int      { return len(p.sources) }
+func (p byPriority) Swap(i, j int) { p.sources[i], p.sources[j] = p.sources[j], p.sources[i] }
+func (p byPriority) Less(i, j int) bool {
+	return p.rankOf(p.sources[i]) < p.rankOf(p.sources[j])
+}
+
+func (p byPriority) rankOf(s datasource.Datasource) int {
+	if r, ok := p.rank[s.Type()]; ok {
+		return r
+	}
+	return len(p.rank)
+}
+
+// sortDatasources orders sources according to priority, a list of datasource
+// types from most to least preferred. Sources of types missing from the list
+// keep their relative order and come last.
+func sortDatasources(sources []datasource.Datasource, priority []string) []datasource.Datasource {
+	p := byPriority{
+		sources: append([]datasource.Datasource{}, sources...),
+		rank:    make(map[string]int, len(priority)),
+	}
+	for i, t := range priority {
+		if _, ok := p.rank[t]; !ok {
+			p.rank[t] = i
+		}
+	}
+	sort.Stable(p)
+	return p.sources
+}
+
 // selectDatasource attempts to choose a valid Datasource to use based on its
-// current availability. The first Datasource to report to be available is
-// returned. Datasources will be retried if possible if they are not
-// immediately available. If all Datasources are permanently unavailable or
+// current availability and its position in sources, which is ordered from
+// most to least preferred. All Datasources are probed concurrently and
+// retried if possible if they are not immediately available. An available
+// Datasource is returned as soon as every more preferred one has been found
+// permanently unavailable; otherwise the most preferred Datasource available
+// once datasourceGrace has passed since the first one became available is
+// returned. If all Datasources are permanently unavailable or
 // datasourceTimeout is reached before one becomes available, nil is returned.
 func selectDatasource(sources []datasource.Datasource) datasource.Datasource {
-	ds := make(chan datasource.Datasource)
+	type probe struct {
+		index     int
+		available bool
+	}
+	// Each prober sends at most one result, so they never block once
+	// selection is over.
+	results := make(chan probe, len(sources))
 	stop := make(chan struct{})
-	var wg sync.WaitGroup
-
-	for _, s := range sources {
-		wg.Add(1)
-		go func(s datasource.Datasource) {
-			defer wg.Done()
+	defer close(stop)
 
+	for i, s := range sources {
+		go func(i int, s datasource.Datasource) {
 			duration := datasourceInterval
 			for {
 				log.Printf("Checking availability of %q\n", s.Type())
 				if s.IsAvailable() {
-					ds <- s
+					results <- probe{i, true}
 					return
 				} else if !s.AvailabilityChanges() {
+					results <- probe{i, false}
 					return
 				}
 				select {
@@ -393,24 +806,76 @@ func selectDatasource(sources []datasource.Datasource) datasource.Datasource {
 					duration = pkg.ExpBackoff(duration, datasourceMaxInterval)
 				}
 			}
-		}(s)
+		}(i, s)
+	}
+
+	const (
+		pending = iota
+		available
+		unavailable
+	)
+	state := make([]int, len(sources))
+	best := func() (int, int) {
+		for i, st := range state {
+			switch st {
+			case available:
+				return i, -1
+			case pending:
+				for j := i + 1; j < len(state); j++ {
+					if state[j] == available {
+						return j, i
+					}
+				}
+				return -1, i
+			}
+		}
+		return -1, -1
 	}
 
-	done := make(chan struct{})
-	go func() {
-		wg.Wait()
-		close(done)
-	}()
+	selected := func(b int, reason string) datasource.Datasource {
+		log.Printf("Selected datasource %q: %s\n", sources[b].Type(), reason)
+		for i, st := range state {
+			if i != b && st == available {
+				log.Printf("Rejected datasource %q: less preferred than %q\n", sources[i].Type(), sources[b].Type())
+			}
+		}
+		return sources[b]
+	}
+
+	var grace <-chan time.Time
+	timeout := time.After(datasourceTimeout)
+	for {
+		b, waiting := best()
+		switch {
+		case b >= 0 && waiting < 0:
+			return selected(b, "no more preferred datasource is pending")
+		case b < 0 && waiting < 0:
+			log.Println("No datasource is available")
+			return nil
+		}
 
-	var s datasource.Datasource
-	select {
-	case s = <-ds:
-	case <-done:
-	case <-time.After(datasourceTimeout):
+		select {
+		case r := <-results:
+			if r.available {
+				state[r.index] = available
+				log.Printf("Datasource %q is available\n", sources[r.index].Type())
+				if grace == nil {
+					grace = time.After(datasourceGrace)
+				}
+			} else {
+				state[r.index] = unavailable
+				log.Printf("Rejected datasource %q: permanently unavailable\n", sources[r.index].Type())
+			}
+		case <-grace:
+			return selected(b, fmt.Sprintf("grace period expired while waiting for %q", sources[waiting].Type()))
+		case <-timeout:
+			if b < 0 {
+				log.Println("Timed out waiting for a datasource")
+				return nil
+			}
+			return selected(b, fmt.Sprintf("timed out while waiting for %q", sources[waiting].Type()))
+		}
 	}
-
-	close(stop)
-	return s
 }
 
 // TODO(jonboulle): this should probably be refactored and moved into a different module
@@ -429,6 +894,45 @@ func runScript(script config.Script, env *initialize.Environment) error {
 	return err
 }
 
+// query implements the query subcommand, printing the value found at the
+// given path of the instance-data document written by an earlier run.
+func query(args []string) int {
+	fs := flag.NewFlagSet("query", flag.ExitOnError)
+	dir := fs.String("dir", instancedata.DefaultDir, "Directory containing the instance-data documents")
+	fs.Usage = func() {
+		fmt.Fprintf(os.Stderr, "Usage: %s query [-dir <dir>] [<path>]\n", os.Args[0])
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+	if fs.NArg() > 1 {
+		fs.Usage()
+		return 2
+	}
+
+	doc, err := instancedata.Read(*dir)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Failed reading instance-data: %v\n", err)
+		return 1
+	}
+	value, err := instancedata.Lookup(doc, fs.Arg(0))
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%v\n", err)
+		return 1
+	}
+
+	if s, ok := value.(string); ok {
+		fmt.Println(s)
+		return 0
+	}
+	out, err := json.MarshalIndent(value, "", "  ")
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%v\n", err)
+		return 1
+	}
+	fmt.Println(string(out))
+	return 0
+}
+
 const gzipMagicBytes = "\x1f\x8b"
 
 func decompressIfGzip(userdataBytes []byte) ([]byte, error) {
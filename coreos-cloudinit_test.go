@@ -20,6 +20,7 @@ import (
 	"errors"
 	"reflect"
 	"testing"
+	"time"
 
 	"github.com/coreos/coreos-cloudinit/config"
 	"github.com/coreos/coreos-cloudinit/datasource"
@@ -91,6 +92,48 @@ func TestMergeConfigs(t *testing.T) {
 	}
 }
 
+func TestMergeVendorConfig(t *testing.T) {
+	tests := []struct {
+		ccv        *config.CloudConfig
+		ccu        *config.CloudConfig
+		vendordata string
+		userdata   string
+
+		out *config.CloudConfig
+	}{
+		{},
+		{
+			ccv: &config.CloudConfig{Hostname: "vendor"},
+			out: &config.CloudConfig{Hostname: "vendor"},
+		},
+		{
+			ccu: &config.CloudConfig{Hostname: "user"},
+			out: &config.CloudConfig{Hostname: "user"},
+		},
+		{
+			ccv:        &config.CloudConfig{Hostname: "vendor", SSHAuthorizedKeys: []string{"abc"}},
+			ccu:        &config.CloudConfig{Hostname: "user"},
+			vendordata: "#cloud-config\nhostname: vendor\nssh_authorized_keys: [abc]",
+			userdata:   "#cloud-config\nhostname: user",
+			out:        &config.CloudConfig{Hostname: "user", SSHAuthorizedKeys: []string{"abc"}},
+		},
+		{
+			ccv:        &config.CloudConfig{SSHPasswdAuth: true},
+			ccu:        &config.CloudConfig{},
+			vendordata: "#cloud-config\nssh_pwauth: true",
+			userdata:   "#cloud-config\nssh_pwauth: false",
+			out:        &config.CloudConfig{},
+		},
+	}
+
+	for i, tt := range tests {
+		out := mergeVendorConfig(tt.ccv, tt.ccu, tt.vendordata, tt.userdata)
+		if !reflect.DeepEqual(tt.out, out) {
+			t.Errorf("bad config (%d): want %#v, got %#v", i, tt.out, out)
+		}
+	}
+}
+
 func mustDecode(in string) []byte {
 	out, err := base64.StdEncoding.DecodeString(in)
 	if err != nil {
@@ -145,3 +188,119 @@ func TestDecompressIfGzip(t *testing.T) {
 	}
 
 }
+
+type fakeDatasource struct {
+	kind      string
+	available bool
+	changes   bool
+	delay     time.Duration
+}
+
+func (f fakeDatasource) IsAvailable() bool {
+	time.Sleep(f.delay)
+	return f.available
+}
+func (f fakeDatasource) AvailabilityChanges() bool { return f.changes }
+func (f fakeDatasource) ConfigRoot() string        { return "" }
+func (f fakeDatasource) FetchMetadata() (datasource.Metadata, error) {
+	return datasource.Metadata{}, nil
+}
+func (f fakeDatasource) FetchUserdata() ([]byte, error)   { return nil, nil }
+func (f fakeDatasource) FetchVendordata() ([]byte, error) { return nil, nil }
+func (f fakeDatasource) Type() string                     { return f.kind }
+
+func TestSortDatasources(t *testing.T) {
+	a := fakeDatasource{kind: "a"}
+	b := fakeDatasource{kind: "b"}
+	c := fakeDatasource{kind: "c"}
+	d := fakeDatasource{kind: "d"}
+
+	tests := []struct {
+		sources  []datasource.Datasource
+		priority []string
+
+		out []datasource.Datasource
+	}{
+		{
+			sources:  []datasource.Datasource{a, b, c},
+			priority: nil,
+			out:      []datasource.Datasource{a, b, c},
+		},
+		{
+			sources:  []datasource.Datasource{a, b, c},
+			priority: []string{"c", "a"},
+			out:      []datasource.Datasource{c, a, b},
+		},
+		{
+			sources:  []datasource.Datasource{d, a, c, b},
+			priority: []string{"b", "a", "b"},
+			out:      []datasource.Datasource{b, a, d, c},
+		},
+	}
+
+	for i, tt := range tests {
+		if out := sortDatasources(tt.sources, tt.priority); !reflect.DeepEqual(tt.out, out) {
+			t.Errorf("bad order (%d): want %v, got %v", i, tt.out, out)
+		}
+	}
+}
+
+func TestSelectDatasource(t *testing.T) {
+	defer func(grace, timeout time.Duration) {
+		datasourceGrace, datasourceTimeout = grace, timeout
+	}(datasourceGrace, datasourceTimeout)
+	datasourceGrace = 200 * time.Millisecond
+	datasourceTimeout = time.Second
+
+	tests := []struct {
+		sources []datasource.Datasource
+
+		out string
+	}{
+		{
+			sources: nil,
+			out:     "",
+		},
+		{
+			// Permanently unavailable sources are skipped
+			sources: []datasource.Datasource{
+				fakeDatasource{kind: "a"},
+				fakeDatasource{kind: "b", available: true},
+			},
+			out: "b",
+		},
+		{
+			// A preferred source which shows up within the grace period wins
+			sources: []datasource.Datasource{
+				fakeDatasource{kind: "a", available: true, delay: 50 * time.Millisecond},
+				fakeDatasource{kind: "b", available: true},
+			},
+			out: "a",
+		},
+		{
+			// A preferred source which is too slow loses
+			sources: []datasource.Datasource{
+				fakeDatasource{kind: "a", available: true, delay: 500 * time.Millisecond},
+				fakeDatasource{kind: "b", available: true},
+			},
+			out: "b",
+		},
+		{
+			// Nothing shows up before the timeout
+			sources: []datasource.Datasource{
+				fakeDatasource{kind: "a", changes: true},
+			},
+			out: "",
+		},
+	}
+
+	for i, tt := range tests {
+		var out string
+		if ds := selectDatasource(tt.sources); ds != nil {
+			out = ds.Type()
+		}
+		if out != tt.out {
+			t.Errorf("bad datasource (%d): want %q, got %q", i, tt.out, out)
+		}
+	}
+}
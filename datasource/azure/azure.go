@@ -0,0 +1,292 @@
+// Copyright 2015 CoreOS, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package azure
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"net"
+	"net/http"
+	"os"
+	"path"
+	"strings"
+
+	"github.com/coreos/coreos-cloudinit/datasource"
+	"github.com/coreos/coreos-cloudinit/pkg"
+)
+
+const (
+	DefaultIMDSAddress       = "http://169.254.169.254/"
+	DefaultWireServerAddress = "http://168.63.129.16/"
+
+	ovfEnvFile    = "ovf-env.xml"
+	imdsPath      = "metadata/instance?api-version=2019-06-01"
+	goalStatePath = "machine/?comp=goalstate"
+	healthPath    = "machine/?comp=health"
+
+	// wireProtocolVersion is the version of the wire server protocol spoken
+	// when reporting ready.
+	wireProtocolVersion = "2012-11-30"
+)
+
+// ovfEnv is the part of the OVF environment on the provisioning media which
+// is used by the datasource.
+type ovfEnv struct {
+	Provisioning struct {
+		HostName   string `xml:"HostName"`
+		UserName   string `xml:"UserName"`
+		CustomData string `xml:"CustomData"`
+		PublicKeys []struct {
+			Fingerprint string `xml:"Fingerprint"`
+			Path        string `xml:"Path"`
+			Value       string `xml:"Value"`
+		} `xml:"SSH>PublicKeys>PublicKey"`
+	} `xml:"ProvisioningSection>LinuxProvisioningConfigurationSet"`
+}
+
+type IPAddress struct {
+	PrivateIPAddress string `json:"privateIpAddress"`
+	PublicIPAddress  string `json:"publicIpAddress"`
+}
+
+type Subnet struct {
+	Address string `json:"address"`
+	Prefix  string `json:"prefix"`
+}
+
+type Interface struct {
+	IPv4 struct {
+		IPAddress []IPAddress `json:"ipAddress"`
+		Subnet    []Subnet    `json:"subnet"`
+	} `json:"ipv4"`
+	IPv6 struct {
+		IPAddress []IPAddress `json:"ipAddress"`
+	} `json:"ipv6"`
+	MacAddress string `json:"macAddress"`
+}
+
+// Network is the network configuration reported by the instance metadata
+// service. It is provided as the NetworkConfig of the metadata.
+type Network struct {
+	Interfaces []Interface `json:"interface"`
+}
+
+type instanceMetadata struct {
+	Compute struct {
+		Name         string `json:"name"`
+		VMID         string `json:"vmId"`
+		Location     string `json:"location"`
+		Zone         string `json:"zone"`
+		ComputerName string `json:"computerName"`
+		PublicKeys   []struct {
+			KeyData string `json:"keyData"`
+			Path    string `json:"path"`
+		} `json:"publicKeys"`
+	} `json:"compute"`
+	Network Network `json:"network"`
+}
+
+type goalState struct {
+	Incarnation string `xml:"Incarnation"`
+	Container   struct {
+		ContainerID   string `xml:"ContainerId"`
+		RoleInstances []struct {
+			InstanceID string `xml:"InstanceId"`
+		} `xml:"RoleInstanceList>RoleInstance"`
+	} `xml:"Container"`
+}
+
+type health struct {
+	XMLName              xml.Name `xml:"Health"`
+	GoalStateIncarnation string   `xml:"GoalStateIncarnation"`
+	ContainerID          string   `xml:"Container>ContainerId"`
+	Roles                []role   `xml:"Container>RoleInstanceList>Role"`
+}
+
+type role struct {
+	InstanceID string `xml:"InstanceId"`
+	State      string `xml:"Health>State"`
+}
+
+type azure struct {
+	root       string
+	imds       string
+	wireServer string
+	client     *pkg.HttpClient
+	readFile   func(filename string) ([]byte, error)
+}
+
+// NewDatasource returns a datasource reading the OVF environment from the
+// provisioning media mounted at root, and querying the instance metadata
+// service and wire server at the given addresses.
+func NewDatasource(root, imds, wireServer string) *azure {
+	if !strings.HasSuffix(imds, "/") {
+		imds += "/"
+	}
+	if !strings.HasSuffix(wireServer, "/") {
+		wireServer += "/"
+	}
+	return &azure{root, imds, wireServer, pkg.NewHttpClient(), ioutil.ReadFile}
+}
+
+func (a *azure) IsAvailable() bool {
+	_, err := os.Stat(path.Join(a.root, ovfEnvFile))
+	return !os.IsNotExist(err)
+}
+
+func (a *azure) AvailabilityChanges() bool {
+	return true
+}
+
+func (a *azure) ConfigRoot() string {
+	return a.root
+}
+
+func (a *azure) FetchMetadata() (metadata datasource.Metadata, err error) {
+	var env *ovfEnv
+	if env, err = a.ovfEnv(); err != nil {
+		return
+	}
+
+	metadata.Hostname = env.Provisioning.HostName
+	metadata.SSHPublicKeys = map[string]string{}
+	for i, key := range env.Provisioning.PublicKeys {
+		if key.Value != "" {
+			metadata.SSHPublicKeys[fmt.Sprintf("ovf-%d", i)] = strings.TrimSpace(key.Value)
+		}
+	}
+
+	var data []byte
+	if data, err = a.get(a.imds+imdsPath, "Metadata", "true"); err != nil {
+		switch err.(type) {
+		case pkg.ErrNetwork, pkg.ErrNotFound:
+			log.Printf("Failed querying the instance metadata service: %v. Continuing...\n", err)
+			err = nil
+		}
+		return
+	}
+
+	var m instanceMetadata
+	if err = json.Unmarshal(data, &m); err != nil {
+		return
+	}
+
+	metadata.InstanceID = m.Compute.VMID
+	metadata.Region = m.Compute.Location
+	metadata.AvailabilityZone = m.Compute.Zone
+	if m.Compute.ComputerName != "" {
+		metadata.Hostname = m.Compute.ComputerName
+	} else if metadata.Hostname == "" {
+		metadata.Hostname = m.Compute.Name
+	}
+	for i, key := range m.Compute.PublicKeys {
+		metadata.SSHPublicKeys[fmt.Sprintf("imds-%d", i)] = strings.TrimSpace(key.KeyData)
+	}
+	if len(m.Network.Interfaces) > 0 {
+		iface := m.Network.Interfaces[0]
+		if len(iface.IPv4.IPAddress) > 0 {
+			metadata.PrivateIPv4 = net.ParseIP(iface.IPv4.IPAddress[0].PrivateIPAddress)
+			metadata.PublicIPv4 = net.ParseIP(iface.IPv4.IPAddress[0].PublicIPAddress)
+		}
+		if len(iface.IPv6.IPAddress) > 0 {
+			metadata.PrivateIPv6 = net.ParseIP(iface.IPv6.IPAddress[0].PrivateIPAddress)
+			metadata.PublicIPv6 = net.ParseIP(iface.IPv6.IPAddress[0].PublicIPAddress)
+		}
+	}
+	metadata.NetworkConfig = m.Network
+	metadata.Raw = data
+
+	return
+}
+
+func (a *azure) FetchUserdata() ([]byte, error) {
+	env, err := a.ovfEnv()
+	if err != nil {
+		return nil, err
+	}
+	return base64.StdEncoding.DecodeString(strings.TrimSpace(env.Provisioning.CustomData))
+}
+
+func (a *azure) FetchVendordata() ([]byte, error) {
+	return nil, nil
+}
+
+func (a *azure) Type() string {
+	return "azure"
+}
+
+// ReportReady tells the wire server that the machine has been provisioned,
+// by reporting the Ready state for every role instance of the current goal
+// state.
+func (a *azure) ReportReady() error {
+	data, err := a.get(a.wireServer+goalStatePath, "x-ms-version", wireProtocolVersion)
+	if err != nil {
+		return err
+	}
+
+	var gs goalState
+	if err := xml.Unmarshal(data, &gs); err != nil {
+		return err
+	}
+
+	h := health{
+		GoalStateIncarnation: gs.Incarnation,
+		ContainerID:          gs.Container.ContainerID,
+	}
+	for _, r := range gs.Container.RoleInstances {
+		h.Roles = append(h.Roles, role{InstanceID: r.InstanceID, State: "Ready"})
+	}
+	body, err := xml.MarshalIndent(h, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	log.Printf("Reporting ready to %s\n", a.wireServer)
+	req, err := http.NewRequest("POST", a.wireServer+healthPath, bytes.NewReader(append([]byte(xml.Header), body...)))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("x-ms-version", wireProtocolVersion)
+	req.Header.Set("x-ms-agent-name", "coreos-cloudinit")
+	req.Header.Set("Content-Type", "text/xml; charset=utf-8")
+	_, err = a.client.Do(req)
+	return err
+}
+
+func (a *azure) ovfEnv() (*ovfEnv, error) {
+	data, err := a.readFile(path.Join(a.root, ovfEnvFile))
+	if err != nil {
+		return nil, err
+	}
+	var env ovfEnv
+	if err := xml.Unmarshal(data, &env); err != nil {
+		return nil, err
+	}
+	return &env, nil
+}
+
+func (a *azure) get(url, header, value string) ([]byte, error) {
+	req, err := http.NewRequest("GET", url, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set(header, value)
+	return a.client.Do(req)
+}
@@ -0,0 +1,205 @@
+// Copyright 2015 CoreOS, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package azure
+
+import (
+	"encoding/xml"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path"
+	"reflect"
+	"testing"
+
+	"github.com/coreos/coreos-cloudinit/datasource"
+)
+
+const testOvfEnv = `<?xml version="1.0" encoding="utf-8"?>
+<Environment xmlns="http://schemas.dmtf.org/ovf/environment/1" xmlns:wa="http://schemas.microsoft.com/windowsazure">
+  <wa:ProvisioningSection>
+    <wa:Version>1.0</wa:Version>
+    <LinuxProvisioningConfigurationSet xmlns="http://schemas.microsoft.com/windowsazure">
+      <ConfigurationSetType>LinuxProvisioningConfiguration</ConfigurationSetType>
+      <HostName>ovf-host</HostName>
+      <UserName>core</UserName>
+      <SSH>
+        <PublicKeys>
+          <PublicKey>
+            <Fingerprint>EB0C0AB4B2D5FC35F2F0658D19F44C8283E2DD62</Fingerprint>
+            <Path>/home/core/.ssh/authorized_keys</Path>
+            <Value>ssh-rsa AAAA ovf</Value>
+          </PublicKey>
+        </PublicKeys>
+      </SSH>
+      <CustomData>I2Nsb3VkLWNvbmZpZw==</CustomData>
+    </LinuxProvisioningConfigurationSet>
+  </wa:ProvisioningSection>
+</Environment>`
+
+const testInstanceMetadata = `{
+  "compute": {
+    "name": "vm",
+    "vmId": "02aab8a4-74ef-476e-8182-f6d2ba4166a6",
+    "location": "westeurope",
+    "zone": "1",
+    "computerName": "imds-host",
+    "publicKeys": [{"keyData": "ssh-rsa AAAA imds", "path": "/home/core/.ssh/authorized_keys"}]
+  },
+  "network": {
+    "interface": [{
+      "ipv4": {
+        "ipAddress": [{"privateIpAddress": "10.0.0.4", "publicIpAddress": "52.1.2.3"}],
+        "subnet": [{"address": "10.0.0.0", "prefix": "24"}]
+      },
+      "ipv6": {"ipAddress": []},
+      "macAddress": "000D3AF806EC"
+    }]
+  }
+}`
+
+const testGoalState = `<?xml version="1.0" encoding="utf-8"?>
+<GoalState xmlns:xsi="http://www.w3.org/2001/XMLSchema-instance">
+  <Version>2012-11-30</Version>
+  <Incarnation>1</Incarnation>
+  <Machine><ExpectedState>Started</ExpectedState></Machine>
+  <Container>
+    <ContainerId>c6d5526c-5ac2-4200-b6e2-56f2b70c5ab2</ContainerId>
+    <RoleInstanceList>
+      <RoleInstance>
+        <InstanceId>d0a1a2f4a1e54b7c8d0a5c1a0e0b2b4a.vm</InstanceId>
+      </RoleInstance>
+    </RoleInstanceList>
+  </Container>
+</GoalState>`
+
+func newTestDatasource(t *testing.T, imds, wireServer string) (*azure, func()) {
+	dir, err := ioutil.TempDir(os.TempDir(), "coreos-cloudinit-")
+	if err != nil {
+		t.Fatalf("bad tempdir: %v", err)
+	}
+	if err := ioutil.WriteFile(path.Join(dir, ovfEnvFile), []byte(testOvfEnv), 0644); err != nil {
+		t.Fatalf("bad ovf-env.xml: %v", err)
+	}
+	return NewDatasource(dir, imds, wireServer), func() { os.RemoveAll(dir) }
+}
+
+func TestFetchMetadata(t *testing.T) {
+	imds := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Metadata") != "true" || r.URL.Path != "/metadata/instance" {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		w.Write([]byte(testInstanceMetadata))
+	}))
+	defer imds.Close()
+
+	a, cleanup := newTestDatasource(t, imds.URL, "")
+	defer cleanup()
+
+	if !a.IsAvailable() {
+		t.Fatalf("bad availability: want true, got false")
+	}
+
+	metadata, err := a.FetchMetadata()
+	if err != nil {
+		t.Fatalf("bad error: want %v, got %v", nil, err)
+	}
+	network := Network{Interfaces: []Interface{{MacAddress: "000D3AF806EC"}}}
+	network.Interfaces[0].IPv4.IPAddress = []IPAddress{{PrivateIPAddress: "10.0.0.4", PublicIPAddress: "52.1.2.3"}}
+	network.Interfaces[0].IPv4.Subnet = []Subnet{{Address: "10.0.0.0", Prefix: "24"}}
+	network.Interfaces[0].IPv6.IPAddress = []IPAddress{}
+	expect := datasource.Metadata{
+		InstanceID:       "02aab8a4-74ef-476e-8182-f6d2ba4166a6",
+		Region:           "westeurope",
+		AvailabilityZone: "1",
+		Hostname:         "imds-host",
+		PrivateIPv4:      net.ParseIP("10.0.0.4"),
+		PublicIPv4:       net.ParseIP("52.1.2.3"),
+		SSHPublicKeys: map[string]string{
+			"ovf-0":  "ssh-rsa AAAA ovf",
+			"imds-0": "ssh-rsa AAAA imds",
+		},
+		NetworkConfig: network,
+		Raw:           []byte(testInstanceMetadata),
+	}
+	if !reflect.DeepEqual(expect, metadata) {
+		t.Errorf("bad metadata: want %#v, got %#v", expect, metadata)
+	}
+
+	userdata, err := a.FetchUserdata()
+	if err != nil || string(userdata) != "#cloud-config" {
+		t.Errorf("bad userdata: want %q, got %q (%v)", "#cloud-config", userdata, err)
+	}
+}
+
+func TestFetchMetadataWithoutIMDS(t *testing.T) {
+	imds := httptest.NewServer(http.NotFoundHandler())
+	defer imds.Close()
+
+	a, cleanup := newTestDatasource(t, imds.URL, "")
+	defer cleanup()
+
+	metadata, err := a.FetchMetadata()
+	if err != nil {
+		t.Fatalf("bad error: want %v, got %v", nil, err)
+	}
+	expect := datasource.Metadata{
+		Hostname:      "ovf-host",
+		SSHPublicKeys: map[string]string{"ovf-0": "ssh-rsa AAAA ovf"},
+	}
+	if !reflect.DeepEqual(expect, metadata) {
+		t.Errorf("bad metadata: want %#v, got %#v", expect, metadata)
+	}
+}
+
+func TestReportReady(t *testing.T) {
+	var report health
+	wireServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("x-ms-version") != wireProtocolVersion {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		switch r.URL.Query().Get("comp") {
+		case "goalstate":
+			w.Write([]byte(testGoalState))
+		case "health":
+			body, _ := ioutil.ReadAll(r.Body)
+			if err := xml.Unmarshal(body, &report); err != nil {
+				w.WriteHeader(http.StatusBadRequest)
+			}
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer wireServer.Close()
+
+	a, cleanup := newTestDatasource(t, "", wireServer.URL)
+	defer cleanup()
+
+	if err := a.ReportReady(); err != nil {
+		t.Fatalf("bad error: want %v, got %v", nil, err)
+	}
+	expect := health{
+		XMLName:              xml.Name{Local: "Health"},
+		GoalStateIncarnation: "1",
+		ContainerID:          "c6d5526c-5ac2-4200-b6e2-56f2b70c5ab2",
+		Roles:                []role{{InstanceID: "d0a1a2f4a1e54b7c8d0a5c1a0e0b2b4a.vm", State: "Ready"}},
+	}
+	if !reflect.DeepEqual(expect, report) {
+		t.Errorf("bad report: want %#v, got %#v", expect, report)
+	}
+}
@@ -0,0 +1,249 @@
+// Copyright 2015 CoreOS, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cache
+
+import (
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"log"
+	"os"
+	"path"
+
+	cloudconfig "github.com/coreos/coreos-cloudinit/config"
+	"github.com/coreos/coreos-cloudinit/datasource"
+	"github.com/coreos/coreos-cloudinit/datasource/azure"
+	"github.com/coreos/coreos-cloudinit/datasource/metadata/digitalocean"
+	"github.com/coreos/coreos-cloudinit/datasource/metadata/hetzner"
+	"github.com/coreos/coreos-cloudinit/datasource/metadata/packet"
+	"github.com/coreos/coreos-cloudinit/datasource/metadata/scaleway"
+	"github.com/coreos/coreos-cloudinit/datasource/metadata/vultr"
+	"github.com/coreos/coreos-cloudinit/datasource/proc_cmdline"
+)
+
+const (
+	userdataFile   = "user-data"
+	vendordataFile = "vendor-data"
+	metadataFile   = "meta-data.json"
+)
+
+// ErrNoInstanceID is returned by Save when the meta-data has no instance ID
+// to key the cache on.
+var ErrNoInstanceID = errors.New("meta-data has no instance ID")
+
+// entry is the on-disk form of the meta-data cached for a datasource.
+// NetworkConfig shadows the field of the same name in datasource.Metadata so
+// that it can be decoded according to the type of the original datasource.
+type entry struct {
+	Type       string `json:"type"`
+	ConfigRoot string `json:"config_root"`
+	SystemUUID string `json:"system_uuid"`
+	Metadata   struct {
+		datasource.Metadata
+		NetworkConfig json.RawMessage
+	} `json:"metadata"`
+}
+
+type cache struct {
+	dir        string
+	systemUUID string
+	readFile   func(filename string) ([]byte, error)
+}
+
+// NewDatasource returns a Datasource serving the data cached in dir by an
+// earlier boot for the instance recorded in the cache. If both the cache and
+// the machine have a system UUID, it is only available if the two match.
+func NewDatasource(dir, systemUUID string) *cache {
+	return &cache{dir, systemUUID, ioutil.ReadFile}
+}
+
+func (c *cache) IsAvailable() bool {
+	e, err := c.entry()
+	if err != nil {
+		return false
+	}
+	if e.Metadata.InstanceID == "" {
+		return false
+	}
+	if c.systemUUID != "" && e.SystemUUID != "" && e.SystemUUID != c.systemUUID {
+		log.Printf("Ignoring cached data: cached for system %q, running on %q\n", e.SystemUUID, c.systemUUID)
+		return false
+	}
+	log.Printf("Found data cached from %q for instance %q\n", e.Type, e.Metadata.InstanceID)
+	return true
+}
+
+func (c *cache) AvailabilityChanges() bool {
+	return false
+}
+
+func (c *cache) ConfigRoot() string {
+	e, err := c.entry()
+	if err != nil {
+		return ""
+	}
+	return e.ConfigRoot
+}
+
+func (c *cache) FetchMetadata() (metadata datasource.Metadata, err error) {
+	var e *entry
+	if e, err = c.entry(); err != nil {
+		return
+	}
+
+	metadata = e.Metadata.Metadata
+	metadata.NetworkConfig, err = decodeNetworkConfig(e.Type, e.Metadata.NetworkConfig)
+	return
+}
+
+func (c *cache) FetchUserdata() ([]byte, error) {
+	return c.readFile(path.Join(c.dir, userdataFile))
+}
+
+func (c *cache) FetchVendordata() ([]byte, error) {
+	data, err := c.readFile(path.Join(c.dir, vendordataFile))
+	if os.IsNotExist(err) {
+		err = nil
+	}
+	return data, err
+}
+
+func (c *cache) Type() string {
+	return "cache"
+}
+
+func (c *cache) entry() (*entry, error) {
+	data, err := c.readFile(path.Join(c.dir, metadataFile))
+	if err != nil {
+		return nil, err
+	}
+	var e entry
+	if err = json.Unmarshal(data, &e); err != nil {
+		return nil, err
+	}
+	return &e, nil
+}
+
+// Save caches the data fetched from ds in dir, keyed on the instance ID of
+// the meta-data and tagged with the system UUID of the machine, if known. The
+// data cached for any other instance is replaced, and if the meta-data has no
+// instance ID the cache is cleared and ErrNoInstanceID is returned. The
+// meta-data is written last so that an interrupted save never leaves a cache
+// which appears to be valid.
+func Save(dir, systemUUID string, ds datasource.Datasource, userdata, vendordata []byte, metadata datasource.Metadata) error {
+	old, err := (&cache{dir: dir, readFile: ioutil.ReadFile}).entry()
+	if err == nil && old.Metadata.InstanceID != metadata.InstanceID {
+		log.Printf("Instance ID changed from %q to %q, invalidating cached data\n", old.Metadata.InstanceID, metadata.InstanceID)
+	}
+	if err := os.Remove(path.Join(dir, metadataFile)); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	if metadata.InstanceID == "" {
+		return ErrNoInstanceID
+	}
+
+	var e entry
+	e.Type = ds.Type()
+	e.ConfigRoot = ds.ConfigRoot()
+	e.SystemUUID = systemUUID
+	e.Metadata.Metadata = metadata
+
+	if e.Metadata.NetworkConfig, err = json.Marshal(metadata.NetworkConfig); err != nil {
+		return err
+	}
+	data, err := json.Marshal(e)
+	if err != nil {
+		return err
+	}
+
+	if err = os.MkdirAll(dir, 0700); err != nil {
+		return err
+	}
+	if err = ioutil.WriteFile(path.Join(dir, userdataFile), userdata, 0600); err != nil {
+		return err
+	}
+	if err = ioutil.WriteFile(path.Join(dir, vendordataFile), vendordata, 0600); err != nil {
+		return err
+	}
+	return ioutil.WriteFile(path.Join(dir, metadataFile), data, 0600)
+}
+
+// decodeNetworkConfig restores the network config cached for a datasource of
+// the given type to the type that datasource originally provided.
+func decodeNetworkConfig(kind string, data json.RawMessage) (interface{}, error) {
+	var config interface{}
+	switch kind {
+	case "cloud-drive", "openstack-metadata-service":
+		config = &[]byte{}
+	case "azure":
+		config = &azure.Network{}
+	case "digitalocean-metadata-service":
+		config = &digitalocean.Metadata{}
+	case "packet-metadata-service":
+		config = &packet.NetworkData{}
+	case "hetzner-metadata-service":
+		config = &hetzner.NetworkConfig{}
+	case "vultr-metadata-service":
+		config = &vultr.Metadata{}
+	case "scaleway-metadata-service":
+		config = &scaleway.Metadata{}
+	case "vmware":
+		// VMware provides either a cloud-init network config or the
+		// variables describing the interfaces.
+		var netconf struct {
+			Version int `json:"version"`
+		}
+		if json.Unmarshal(data, &netconf) == nil && netconf.Version != 0 {
+			config = &cloudconfig.Network{}
+		} else {
+			config = &map[string]string{}
+		}
+	case "proc-cmdline":
+		config = &proc_cmdline.NetworkConfig{}
+	default:
+		return nil, nil
+	}
+
+	if len(data) == 0 || string(data) == "null" {
+		return nil, nil
+	}
+	if err := json.Unmarshal(data, config); err != nil {
+		return nil, err
+	}
+
+	switch c := config.(type) {
+	case *[]byte:
+		return *c, nil
+	case *azure.Network:
+		return *c, nil
+	case *digitalocean.Metadata:
+		return *c, nil
+	case *packet.NetworkData:
+		return *c, nil
+	case *hetzner.NetworkConfig:
+		return *c, nil
+	case *vultr.Metadata:
+		return *c, nil
+	case *scaleway.Metadata:
+		return *c, nil
+	case *map[string]string:
+		return *c, nil
+	case *cloudconfig.Network:
+		return *c, nil
+	case *proc_cmdline.NetworkConfig:
+		return *c, nil
+	}
+	return nil, nil
+}
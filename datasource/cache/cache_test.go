@@ -0,0 +1,204 @@
+// Copyright 2015 CoreOS, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cache
+
+import (
+	"io/ioutil"
+	"os"
+	"reflect"
+	"testing"
+
+	"github.com/coreos/coreos-cloudinit/config"
+	"github.com/coreos/coreos-cloudinit/datasource"
+	"github.com/coreos/coreos-cloudinit/datasource/metadata/digitalocean"
+)
+
+type fakeDatasource struct {
+	kind       string
+	configRoot string
+}
+
+func (f fakeDatasource) IsAvailable() bool         { return true }
+func (f fakeDatasource) AvailabilityChanges() bool { return false }
+func (f fakeDatasource) ConfigRoot() string        { return f.configRoot }
+func (f fakeDatasource) FetchMetadata() (datasource.Metadata, error) {
+	return datasource.Metadata{}, nil
+}
+func (f fakeDatasource) FetchUserdata() ([]byte, error)   { return nil, nil }
+func (f fakeDatasource) FetchVendordata() ([]byte, error) { return nil, nil }
+func (f fakeDatasource) Type() string                     { return f.kind }
+
+func TestCache(t *testing.T) {
+	for i, tt := range []struct {
+		ds         fakeDatasource
+		userdata   []byte
+		vendordata []byte
+		metadata   datasource.Metadata
+		savedUUID  string
+		systemUUID string
+		err        error
+		available  bool
+	}{
+		{
+			ds:         fakeDatasource{kind: "cloud-drive", configRoot: "/media/configdrive/openstack"},
+			userdata:   []byte("#cloud-config"),
+			vendordata: []byte("#cloud-config\nhostname: vendor"),
+			metadata: datasource.Metadata{
+				InstanceID:    "83679162-1378-4288-a2d4-70e13ec132aa",
+				Hostname:      "test",
+				SSHPublicKeys: map[string]string{"mykey": "ssh-rsa AAAA"},
+				NetworkConfig: []byte(`{"links":[]}`),
+			},
+			savedUUID:  "4c4c4544-0043",
+			systemUUID: "4c4c4544-0043",
+			available:  true,
+		},
+		{
+			ds:       fakeDatasource{kind: "digitalocean-metadata-service"},
+			userdata: []byte("#cloud-config"),
+			metadata: datasource.Metadata{
+				InstanceID:    "1",
+				NetworkConfig: digitalocean.Metadata{DropletID: 1, Hostname: "droplet"},
+			},
+			savedUUID:  "4c4c4544-0043",
+			systemUUID: "4c4c4544-0043",
+			available:  true,
+		},
+		{
+			ds: fakeDatasource{kind: "vmware"},
+			metadata: datasource.Metadata{
+				InstanceID:    "vm-1",
+				NetworkConfig: map[string]string{"interface.0.name": "eth0", "interface.0.dhcp": "yes"},
+			},
+			savedUUID:  "4c4c4544-0043",
+			systemUUID: "4c4c4544-0043",
+			available:  true,
+		},
+		{
+			ds: fakeDatasource{kind: "vmware"},
+			metadata: datasource.Metadata{
+				InstanceID: "vm-2",
+				NetworkConfig: config.Network{
+					Version:   2,
+					Ethernets: map[string]config.NetworkDevice{"eth0": {DHCP4: true}},
+				},
+			},
+			savedUUID:  "4c4c4544-0043",
+			systemUUID: "4c4c4544-0043",
+			available:  true,
+		},
+		{
+			ds:         fakeDatasource{kind: "ec2-metadata-service"},
+			metadata:   datasource.Metadata{InstanceID: "i-12345678"},
+			systemUUID: "4c4c4544-0043",
+			available:  true,
+		},
+		{
+			ds:         fakeDatasource{kind: "ec2-metadata-service"},
+			metadata:   datasource.Metadata{InstanceID: "i-12345678"},
+			savedUUID:  "4c4c4544-0043",
+			systemUUID: "",
+			available:  true,
+		},
+		{
+			ds:         fakeDatasource{kind: "ec2-metadata-service"},
+			metadata:   datasource.Metadata{InstanceID: "i-12345678"},
+			savedUUID:  "4c4c4544-0043",
+			systemUUID: "4c4c4544-0044",
+		},
+		{
+			ds:         fakeDatasource{kind: "ec2-metadata-service"},
+			savedUUID:  "4c4c4544-0043",
+			systemUUID: "4c4c4544-0043",
+			err:        ErrNoInstanceID,
+		},
+	} {
+		dir, err := ioutil.TempDir(os.TempDir(), "coreos-cloudinit-")
+		if err != nil {
+			t.Fatalf("bad tempdir (%d): %v", i, err)
+		}
+		defer os.RemoveAll(dir)
+
+		if err := Save(dir, tt.savedUUID, tt.ds, tt.userdata, tt.vendordata, tt.metadata); err != tt.err {
+			t.Fatalf("bad error (%d): want %v, got %v", i, tt.err, err)
+		}
+
+		c := NewDatasource(dir, tt.systemUUID)
+		if available := c.IsAvailable(); available != tt.available {
+			t.Errorf("bad availability (%d): want %t, got %t", i, tt.available, available)
+		}
+		if !tt.available {
+			continue
+		}
+		if root := c.ConfigRoot(); root != tt.ds.configRoot {
+			t.Errorf("bad config root (%d): want %q, got %q", i, tt.ds.configRoot, root)
+		}
+		if userdata, err := c.FetchUserdata(); err != nil || string(userdata) != string(tt.userdata) {
+			t.Errorf("bad user-data (%d): want %q, got %q (%v)", i, tt.userdata, userdata, err)
+		}
+		if vendordata, err := c.FetchVendordata(); err != nil || string(vendordata) != string(tt.vendordata) {
+			t.Errorf("bad vendor-data (%d): want %q, got %q (%v)", i, tt.vendordata, vendordata, err)
+		}
+		metadata, err := c.FetchMetadata()
+		if err != nil {
+			t.Errorf("bad error (%d): want %v, got %v", i, nil, err)
+		}
+		if !reflect.DeepEqual(tt.metadata, metadata) {
+			t.Errorf("bad metadata (%d): want %#v, got %#v", i, tt.metadata, metadata)
+		}
+	}
+}
+
+func TestCacheMissing(t *testing.T) {
+	dir, err := ioutil.TempDir(os.TempDir(), "coreos-cloudinit-")
+	if err != nil {
+		t.Fatalf("bad tempdir: %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	if NewDatasource(dir, "4c4c4544-0043").IsAvailable() {
+		t.Errorf("bad availability: want false, got true")
+	}
+}
+
+func TestCacheInvalidate(t *testing.T) {
+	dir, err := ioutil.TempDir(os.TempDir(), "coreos-cloudinit-")
+	if err != nil {
+		t.Fatalf("bad tempdir: %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	ds := fakeDatasource{kind: "ec2-metadata-service"}
+	if err := Save(dir, "", ds, []byte("#cloud-config"), nil, datasource.Metadata{InstanceID: "i-1"}); err != nil {
+		t.Fatalf("bad error: want %v, got %v", nil, err)
+	}
+	if err := Save(dir, "", ds, []byte("#!/bin/sh"), nil, datasource.Metadata{InstanceID: "i-2"}); err != nil {
+		t.Fatalf("bad error: want %v, got %v", nil, err)
+	}
+	c := NewDatasource(dir, "")
+	if metadata, err := c.FetchMetadata(); err != nil || metadata.InstanceID != "i-2" {
+		t.Errorf("bad instance ID: want %q, got %q (%v)", "i-2", metadata.InstanceID, err)
+	}
+	if userdata, err := c.FetchUserdata(); err != nil || string(userdata) != "#!/bin/sh" {
+		t.Errorf("bad user-data: want %q, got %q (%v)", "#!/bin/sh", userdata, err)
+	}
+
+	if err := Save(dir, "", ds, nil, nil, datasource.Metadata{}); err != ErrNoInstanceID {
+		t.Fatalf("bad error: want %v, got %v", ErrNoInstanceID, err)
+	}
+	if c.IsAvailable() {
+		t.Errorf("bad availability: want false, got true")
+	}
+}
@@ -18,16 +18,50 @@ import (
 	"encoding/json"
 	"io/ioutil"
 	"log"
+	"net"
 	"os"
 	"path"
 
 	"github.com/coreos/coreos-cloudinit/datasource"
+	"github.com/coreos/coreos-cloudinit/datasource/metadata/openstack"
 )
 
 const (
-	openstackApiVersion = "latest"
+	latestVersion = "latest"
 )
 
+// openstackVersions are the dated versions of the OpenStack data understood by
+// the datasource, newest first. They are only used when the drive has no
+// latest version.
+var openstackVersions = []string{
+	"2018-08-27",
+	"2017-02-22",
+	"2016-10-06",
+	"2016-06-30",
+	"2015-10-15",
+	"2013-10-17",
+	"2013-04-04",
+	"2012-08-10",
+}
+
+// ec2Versions are the dated versions of the EC2 data understood by the
+// datasource, newest first.
+var ec2Versions = []string{
+	"2009-04-04",
+}
+
+// ec2Metadata is the subset of the EC2 meta-data tree used by the datasource.
+type ec2Metadata struct {
+	InstanceID string `json:"instance-id"`
+	Hostname   string `json:"local-hostname"`
+	LocalIPv4  string `json:"local-ipv4"`
+	PublicIPv4 string `json:"public-ipv4"`
+	Placement  struct {
+		AvailabilityZone string `json:"availability-zone"`
+	} `json:"placement"`
+	BlockDeviceMapping map[string]string `json:"block-device-mapping"`
+}
+
 type configDrive struct {
 	root     string
 	readFile func(filename string) ([]byte, error)
@@ -53,33 +87,86 @@ func (cd *configDrive) ConfigRoot() string {
 func (cd *configDrive) FetchMetadata() (metadata datasource.Metadata, err error) {
 	var data []byte
 	var m struct {
+		UUID                string            `json:"uuid"`
 		SSHAuthorizedKeyMap map[string]string `json:"public_keys"`
 		Hostname            string            `json:"hostname"`
+		AvailabilityZone    string            `json:"availability_zone"`
 		NetworkConfig       struct {
 			ContentPath string `json:"content_path"`
 		} `json:"network_config"`
 	}
 
-	if data, err = cd.tryReadFile(path.Join(cd.openstackVersionRoot(), "meta_data.json")); err != nil || len(data) == 0 {
+	if data, err = cd.tryReadFile(path.Join(cd.openstackVersionRoot(), "meta_data.json")); err != nil {
 		return
 	}
-	if err = json.Unmarshal([]byte(data), &m); err != nil {
-		return
+	if len(data) > 0 {
+		if err = json.Unmarshal([]byte(data), &m); err != nil {
+			return
+		}
+
+		metadata.InstanceID = m.UUID
+		metadata.Raw = data
+		metadata.SSHPublicKeys = m.SSHAuthorizedKeyMap
+		metadata.Hostname = m.Hostname
+		metadata.AvailabilityZone = m.AvailabilityZone
+		if m.NetworkConfig.ContentPath != "" {
+			if metadata.NetworkConfig, err = cd.tryReadFile(path.Join(cd.openstackRoot(), m.NetworkConfig.ContentPath)); err != nil {
+				return
+			}
+		}
 	}
 
-	metadata.SSHPublicKeys = m.SSHAuthorizedKeyMap
-	metadata.Hostname = m.Hostname
-	if m.NetworkConfig.ContentPath != "" {
-		metadata.NetworkConfig, err = cd.tryReadFile(path.Join(cd.openstackRoot(), m.NetworkConfig.ContentPath))
+	err = cd.fetchEC2Metadata(&metadata)
+	return
+}
+
+// fetchEC2Metadata fills in the values of metadata which are missing from the
+// OpenStack data with those of the EC2 meta-data tree, if the drive has one.
+func (cd *configDrive) fetchEC2Metadata(metadata *datasource.Metadata) error {
+	var m ec2Metadata
+
+	root := path.Join(cd.root, "ec2")
+	data, err := cd.tryReadFile(path.Join(root, cd.selectVersion(root, ec2Versions), "meta-data.json"))
+	if err != nil || len(data) == 0 {
+		return err
+	}
+	if err = json.Unmarshal(data, &m); err != nil {
+		return err
 	}
 
-	return
+	if metadata.InstanceID == "" {
+		metadata.InstanceID = m.InstanceID
+	}
+	if metadata.Hostname == "" {
+		metadata.Hostname = m.Hostname
+	}
+	if metadata.AvailabilityZone == "" {
+		metadata.AvailabilityZone = m.Placement.AvailabilityZone
+	}
+	metadata.PrivateIPv4 = net.ParseIP(m.LocalIPv4)
+	metadata.PublicIPv4 = net.ParseIP(m.PublicIPv4)
+	metadata.BlockDeviceMappings = m.BlockDeviceMapping
+	if metadata.Raw == nil {
+		metadata.Raw = data
+	}
+	return nil
 }
 
 func (cd *configDrive) FetchUserdata() ([]byte, error) {
 	return cd.tryReadFile(path.Join(cd.openstackVersionRoot(), "user_data"))
 }
 
+// FetchVendordata returns the cloud-init payload of vendor_data.json, falling
+// back to vendor_data2.json.
+func (cd *configDrive) FetchVendordata() ([]byte, error) {
+	return openstack.ReadVendordata(func(dynamic bool) ([]byte, error) {
+		if dynamic {
+			return cd.tryReadFile(path.Join(cd.openstackVersionRoot(), "vendor_data2.json"))
+		}
+		return cd.tryReadFile(path.Join(cd.openstackVersionRoot(), "vendor_data.json"))
+	})
+}
+
 func (cd *configDrive) Type() string {
 	return "cloud-drive"
 }
@@ -89,7 +176,30 @@ func (cd *configDrive) openstackRoot() string {
 }
 
 func (cd *configDrive) openstackVersionRoot() string {
-	return path.Join(cd.openstackRoot(), openstackApiVersion)
+	return path.Join(cd.openstackRoot(), cd.selectVersion(cd.openstackRoot(), openstackVersions))
+}
+
+// selectVersion returns the latest version if the drive has it under root,
+// or else the newest of versions present on the drive. It falls back to the
+// latest version if none of them is present.
+func (cd *configDrive) selectVersion(root string, versions []string) string {
+	if cd.exists(path.Join(root, latestVersion)) {
+		return latestVersion
+	}
+	for _, version := range versions {
+		if cd.exists(path.Join(root, version)) {
+			log.Printf("Using version %s of %q\n", version, root)
+			return version
+		}
+	}
+	return latestVersion
+}
+
+// exists reports whether name exists on the drive. Reading a directory fails,
+// but not with a not-exist error.
+func (cd *configDrive) exists(name string) bool {
+	_, err := cd.readFile(name)
+	return !os.IsNotExist(err)
 }
 
 func (cd *configDrive) tryReadFile(filename string) ([]byte, error) {
@@ -15,6 +15,7 @@
 package configdrive
 
 import (
+	"net"
 	"reflect"
 	"testing"
 
@@ -34,13 +35,14 @@ func TestFetchMetadata(t *testing.T) {
 			files: test.NewMockFilesystem(test.File{Path: "/openstack/latest/meta_data.json", Contents: ""}),
 		},
 		{
-			root:  "/",
-			files: test.NewMockFilesystem(test.File{Path: "/openstack/latest/meta_data.json", Contents: `{"ignore": "me"}`}),
+			root:     "/",
+			files:    test.NewMockFilesystem(test.File{Path: "/openstack/latest/meta_data.json", Contents: `{"ignore": "me"}`}),
+			metadata: datasource.Metadata{Raw: []byte(`{"ignore": "me"}`)},
 		},
 		{
 			root:     "/",
 			files:    test.NewMockFilesystem(test.File{Path: "/openstack/latest/meta_data.json", Contents: `{"hostname": "host"}`}),
-			metadata: datasource.Metadata{Hostname: "host"},
+			metadata: datasource.Metadata{Hostname: "host", Raw: []byte(`{"hostname": "host"}`)},
 		},
 		{
 			root: "/media/configdrive",
@@ -54,6 +56,56 @@ func TestFetchMetadata(t *testing.T) {
 					"1": "key1",
 					"2": "key2",
 				},
+				Raw: []byte(`{"hostname": "host", "network_config": {"content_path": "config_file.json"}, "public_keys":{"1": "key1", "2": "key2"}}`),
+			},
+		},
+		{
+			root: "/",
+			files: test.NewMockFilesystem(
+				test.File{Path: "/openstack/2012-08-10/meta_data.json", Contents: `{"hostname": "old"}`},
+				test.File{Path: "/openstack/2015-10-15/meta_data.json", Contents: `{"hostname": "host", "uuid": "83679162-1378-4288-a2d4-70e13ec132aa", "availability_zone": "nova"}`},
+				test.File{Path: "/openstack/2099-01-01/meta_data.json", Contents: `{"hostname": "unsupported"}`},
+			),
+			metadata: datasource.Metadata{
+				InstanceID:       "83679162-1378-4288-a2d4-70e13ec132aa",
+				Hostname:         "host",
+				AvailabilityZone: "nova",
+				Raw:              []byte(`{"hostname": "host", "uuid": "83679162-1378-4288-a2d4-70e13ec132aa", "availability_zone": "nova"}`),
+			},
+		},
+		{
+			root: "/",
+			files: test.NewMockFilesystem(
+				test.File{Path: "/openstack/latest/meta_data.json", Contents: `{"hostname": "host", "uuid": "83679162-1378-4288-a2d4-70e13ec132aa"}`},
+				test.File{Path: "/ec2/latest/meta-data.json", Contents: `{
+  "instance-id": "i-00000001",
+  "local-hostname": "ec2-host",
+  "local-ipv4": "10.0.0.3",
+  "public-ipv4": "203.0.113.3",
+  "placement": {"availability-zone": "us-east-1a"},
+  "block-device-mapping": {"ami": "vda", "root": "/dev/vda", "ephemeral0": "/dev/vdb"}
+}`},
+			),
+			metadata: datasource.Metadata{
+				InstanceID:       "83679162-1378-4288-a2d4-70e13ec132aa",
+				Hostname:         "host",
+				AvailabilityZone: "us-east-1a",
+				PrivateIPv4:      net.ParseIP("10.0.0.3"),
+				PublicIPv4:       net.ParseIP("203.0.113.3"),
+				BlockDeviceMappings: map[string]string{
+					"ami":        "vda",
+					"root":       "/dev/vda",
+					"ephemeral0": "/dev/vdb",
+				},
+				Raw: []byte(`{"hostname": "host", "uuid": "83679162-1378-4288-a2d4-70e13ec132aa"}`),
+			},
+		},
+		{
+			root:  "/",
+			files: test.NewMockFilesystem(test.File{Path: "/ec2/2009-04-04/meta-data.json", Contents: `{"instance-id": "i-00000002", "public-ipv4": ""}`}),
+			metadata: datasource.Metadata{
+				InstanceID: "i-00000002",
+				Raw:        []byte(`{"instance-id": "i-00000002", "public-ipv4": ""}`),
 			},
 		},
 	} {
@@ -102,6 +154,96 @@ func TestFetchUserdata(t *testing.T) {
 	}
 }
 
+func TestSelectVersion(t *testing.T) {
+	for _, tt := range []struct {
+		files   test.MockFilesystem
+		version string
+	}{
+		{
+			files:   test.NewMockFilesystem(),
+			version: "latest",
+		},
+		{
+			files: test.NewMockFilesystem(
+				test.File{Path: "/openstack/latest/user_data"},
+				test.File{Path: "/openstack/2017-02-22/user_data"},
+			),
+			version: "latest",
+		},
+		{
+			files: test.NewMockFilesystem(
+				test.File{Path: "/openstack/2013-04-04/user_data"},
+				test.File{Path: "/openstack/2017-02-22/user_data"},
+				test.File{Path: "/openstack/2099-01-01/user_data"},
+			),
+			version: "2017-02-22",
+		},
+	} {
+		cd := configDrive{"/", tt.files.ReadFile}
+		if version := cd.selectVersion("/openstack", openstackVersions); version != tt.version {
+			t.Fatalf("bad version for %+v: want %q, got %q", tt.files, tt.version, version)
+		}
+	}
+}
+
+func TestFetchVendordata(t *testing.T) {
+	for _, tt := range []struct {
+		root  string
+		files test.MockFilesystem
+
+		vendordata string
+	}{
+		{
+			"/",
+			test.NewMockFilesystem(),
+			"",
+		},
+		{
+			"/",
+			test.NewMockFilesystem(test.File{Path: "/openstack/latest/vendor_data.json", Contents: `"#cloud-config\nhostname: vendor\n"`}),
+			"#cloud-config\nhostname: vendor\n",
+		},
+		{
+			"/",
+			test.NewMockFilesystem(test.File{Path: "/openstack/latest/vendor_data.json", Contents: `{"cloud-init": "#cloud-config"}`}),
+			"#cloud-config",
+		},
+		{
+			"/media/configdrive",
+			test.NewMockFilesystem(
+				test.File{Path: "/media/configdrive/openstack/latest/vendor_data.json", Contents: `{}`},
+				test.File{Path: "/media/configdrive/openstack/latest/vendor_data2.json", Contents: `{"b": {"cloud-init": "second"}, "a": {"ignore": "me"}}`},
+			),
+			"second",
+		},
+		{
+			"/",
+			test.NewMockFilesystem(
+				test.File{Path: "/openstack/latest/vendor_data.json", Contents: `{`},
+				test.File{Path: "/openstack/latest/vendor_data2.json", Contents: `{"a": {"cloud-init": "dynamic"}}`},
+			),
+			"dynamic",
+		},
+	} {
+		cd := configDrive{tt.root, tt.files.ReadFile}
+		vendordata, err := cd.FetchVendordata()
+		if err != nil {
+			t.Fatalf("bad error for %+v: want %v, got %q", tt, nil, err)
+		}
+		if string(vendordata) != tt.vendordata {
+			t.Fatalf("bad vendordata for %+v: want %q, got %q", tt, tt.vendordata, vendordata)
+		}
+	}
+}
+
+func TestFetchVendordataError(t *testing.T) {
+	files := test.NewMockFilesystem(test.File{Path: "/openstack/latest/vendor_data.json", Contents: `{`})
+	cd := configDrive{"/", files.ReadFile}
+	if _, err := cd.FetchVendordata(); err == nil {
+		t.Fatalf("bad error: want non-nil, got %v", err)
+	}
+}
+
 func TestConfigRoot(t *testing.T) {
 	for _, tt := range []struct {
 		root       string
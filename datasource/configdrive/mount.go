@@ -0,0 +1,225 @@
+// Copyright 2015 CoreOS, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package configdrive
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"os"
+	"os/exec"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"github.com/coreos/coreos-cloudinit/datasource"
+)
+
+// Label is the filesystem label of a config drive.
+const Label = "config-2"
+
+const (
+	labelDir    = "/dev/disk/by-label"
+	sysBlockDir = "/sys/class/block"
+	devDir      = "/dev"
+)
+
+var ErrNoDevice = fmt.Errorf("no block device labelled %s", Label)
+
+// mountedDrive is a config drive which is found by its label and mounted by
+// the datasource itself, so no mount units are needed. The drive is mounted
+// the first time it is read and stays mounted until Unmount is called, so
+// that ConfigRoot is a readable directory.
+type mountedDrive struct {
+	configDrive
+	device  string
+	find    func() (string, error)
+	mount   func(device, dir string) error
+	unmount func(dir string) error
+}
+
+// NewMountedDatasource returns a datasource which finds the block device
+// labelled config-2 and mounts it read-only to a temporary directory to read
+// the data.
+func NewMountedDatasource() *mountedDrive {
+	return &mountedDrive{
+		configDrive: configDrive{readFile: ioutil.ReadFile},
+		find: func() (string, error) {
+			return findDevice(labelDir, sysBlockDir, devDir, blkid)
+		},
+		mount:   mount,
+		unmount: unmount,
+	}
+}
+
+func (md *mountedDrive) IsAvailable() bool {
+	device, err := md.find()
+	if err != nil {
+		return false
+	}
+	md.device = device
+	return true
+}
+
+// ConfigRoot returns the directory the drive is mounted at, mounting it if
+// it has not been read yet.
+func (md *mountedDrive) ConfigRoot() string {
+	if err := md.mountOnce(); err != nil {
+		log.Printf("Failed to mount %s: %v\n", md.device, err)
+		return ""
+	}
+	return md.root
+}
+
+func (md *mountedDrive) FetchMetadata() (datasource.Metadata, error) {
+	if err := md.mountOnce(); err != nil {
+		return datasource.Metadata{}, err
+	}
+	return md.configDrive.FetchMetadata()
+}
+
+func (md *mountedDrive) FetchUserdata() ([]byte, error) {
+	if err := md.mountOnce(); err != nil {
+		return nil, err
+	}
+	return md.configDrive.FetchUserdata()
+}
+
+func (md *mountedDrive) FetchVendordata() ([]byte, error) {
+	if err := md.mountOnce(); err != nil {
+		return nil, err
+	}
+	return md.configDrive.FetchVendordata()
+}
+
+// Unmount unmounts the drive if it was mounted and removes the mount point.
+func (md *mountedDrive) Unmount() error {
+	if md.root == "" {
+		return nil
+	}
+	if err := md.unmount(md.root); err != nil {
+		return err
+	}
+	err := os.Remove(md.root)
+	md.root = ""
+	return err
+}
+
+// mountOnce mounts the drive to a temporary directory, unless it is mounted
+// already.
+func (md *mountedDrive) mountOnce() error {
+	if md.root != "" {
+		return nil
+	}
+	if md.device == "" {
+		return ErrNoDevice
+	}
+	dir, err := ioutil.TempDir("", "configdrive-")
+	if err != nil {
+		return err
+	}
+
+	log.Printf("Mounting %s at %s\n", md.device, dir)
+	if err = md.mount(md.device, dir); err != nil {
+		os.Remove(dir)
+		return err
+	}
+	md.root = dir
+	return nil
+}
+
+// findDevice returns the block device labelled config-2. The udev links are
+// consulted first, then blkid, and finally the labels of the block devices
+// listed in sysfs are read directly, for images with neither udev nor blkid.
+func findDevice(labelDir, sysBlockDir, devDir string, blkid func(label string) (string, error)) (string, error) {
+	labels := []string{Label, strings.ToUpper(Label)}
+
+	for _, label := range labels {
+		if device, err := filepath.EvalSymlinks(path.Join(labelDir, label)); err == nil {
+			return device, nil
+		}
+	}
+
+	for _, label := range labels {
+		if device, err := blkid(label); err == nil && device != "" {
+			return device, nil
+		}
+	}
+
+	names, err := ioutil.ReadDir(sysBlockDir)
+	if err != nil {
+		return "", ErrNoDevice
+	}
+	for _, name := range names {
+		device := path.Join(devDir, name.Name())
+		if label := probeLabel(device); strings.EqualFold(label, Label) {
+			return device, nil
+		}
+	}
+	return "", ErrNoDevice
+}
+
+func blkid(label string) (string, error) {
+	output, err := exec.Command("blkid", "-l", "-o", "device", "-t", "LABEL="+label).Output()
+	return strings.TrimSpace(string(output)), err
+}
+
+// probeLabel reads the label of an ISO 9660 or FAT filesystem on device, or
+// returns an empty string if it holds neither.
+func probeLabel(device string) string {
+	f, err := os.Open(device)
+	if err != nil {
+		return ""
+	}
+	defer f.Close()
+
+	// The primary volume descriptor follows the 32KiB system area.
+	pvd := make([]byte, 72)
+	if _, err := f.ReadAt(pvd, 0x8000); err == nil && pvd[0] == 1 && string(pvd[1:6]) == "CD001" {
+		return trimLabel(pvd[40:72])
+	}
+
+	boot := make([]byte, 90)
+	if _, err := f.ReadAt(boot, 0); err != nil {
+		return ""
+	}
+	switch {
+	case bytes.HasPrefix(boot[82:90], []byte("FAT32")):
+		return trimLabel(boot[71:82])
+	case bytes.HasPrefix(boot[54:62], []byte("FAT")):
+		return trimLabel(boot[43:54])
+	}
+	return ""
+}
+
+func trimLabel(label []byte) string {
+	return strings.TrimRight(string(label), " \x00")
+}
+
+func mount(device, dir string) error {
+	if output, err := exec.Command("mount", "-o", "ro", device, dir).CombinedOutput(); err != nil {
+		return errors.New(strings.TrimSpace(string(output)))
+	}
+	return nil
+}
+
+func unmount(dir string) error {
+	if output, err := exec.Command("umount", dir).CombinedOutput(); err != nil {
+		return errors.New(strings.TrimSpace(string(output)))
+	}
+	return nil
+}
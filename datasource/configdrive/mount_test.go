@@ -0,0 +1,198 @@
+// Copyright 2015 CoreOS, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package configdrive
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"path"
+	"reflect"
+	"testing"
+
+	"github.com/coreos/coreos-cloudinit/datasource"
+)
+
+func writeImage(t *testing.T, name string, offset int64, header []byte) {
+	f, err := os.Create(name)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	defer f.Close()
+	if _, err := f.WriteAt(header, offset); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+}
+
+func isoHeader(label string) []byte {
+	pvd := make([]byte, 72)
+	pvd[0] = 1
+	copy(pvd[1:], "CD001")
+	copy(pvd[40:], label+"                                ")
+	return pvd
+}
+
+func TestProbeLabel(t *testing.T) {
+	dir, err := ioutil.TempDir(os.TempDir(), "coreos-cloudinit-")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	fat32 := make([]byte, 90)
+	copy(fat32[71:], "config-2   FAT32   ")
+	fat16 := make([]byte, 90)
+	copy(fat16[43:], "CONFIG-2   FAT16   ")
+
+	for _, tt := range []struct {
+		offset int64
+		header []byte
+		label  string
+	}{
+		{offset: 0x8000, header: isoHeader("config-2"), label: "config-2"},
+		{offset: 0, header: fat32, label: "config-2"},
+		{offset: 0, header: fat16, label: "CONFIG-2"},
+		{offset: 0, header: []byte("garbage"), label: ""},
+	} {
+		image := path.Join(dir, "image")
+		writeImage(t, image, tt.offset, tt.header)
+		if label := probeLabel(image); label != tt.label {
+			t.Fatalf("bad label (%q): want %q, got %q", tt.header, tt.label, label)
+		}
+	}
+
+	if label := probeLabel(path.Join(dir, "missing")); label != "" {
+		t.Fatalf("bad label: want %q, got %q", "", label)
+	}
+}
+
+func TestFindDevice(t *testing.T) {
+	dir, err := ioutil.TempDir(os.TempDir(), "coreos-cloudinit-")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	labelDir := path.Join(dir, "by-label")
+	sysDir := path.Join(dir, "sys")
+	devDir := path.Join(dir, "dev")
+	for _, d := range []string{labelDir, path.Join(sysDir, "sr0"), path.Join(sysDir, "vda"), devDir} {
+		if err := os.MkdirAll(d, 0755); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+	}
+	noBlkid := func(string) (string, error) { return "", errors.New("blkid not found") }
+
+	if _, err := findDevice(labelDir, sysDir, devDir, noBlkid); err != ErrNoDevice {
+		t.Fatalf("bad error: want %v, got %v", ErrNoDevice, err)
+	}
+
+	// sysfs
+	writeImage(t, path.Join(devDir, "vda"), 0, []byte("garbage"))
+	writeImage(t, path.Join(devDir, "sr0"), 0x8000, isoHeader("CONFIG-2"))
+	if device, err := findDevice(labelDir, sysDir, devDir, noBlkid); err != nil || device != path.Join(devDir, "sr0") {
+		t.Fatalf("bad device: want %q, got %q (%v)", path.Join(devDir, "sr0"), device, err)
+	}
+
+	// blkid
+	blkid := func(label string) (string, error) {
+		if label == "CONFIG-2" {
+			return "/dev/vdb", nil
+		}
+		return "", errors.New("exit status 2")
+	}
+	if device, err := findDevice(labelDir, sysDir, devDir, blkid); err != nil || device != "/dev/vdb" {
+		t.Fatalf("bad device: want %q, got %q (%v)", "/dev/vdb", device, err)
+	}
+
+	// udev
+	if err := os.Symlink("../dev/vda", path.Join(labelDir, "config-2")); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if device, err := findDevice(labelDir, sysDir, devDir, blkid); err != nil || device != path.Join(devDir, "vda") {
+		t.Fatalf("bad device: want %q, got %q (%v)", path.Join(devDir, "vda"), device, err)
+	}
+}
+
+func TestMountedDrive(t *testing.T) {
+	var mounted []string
+	md := &mountedDrive{
+		configDrive: configDrive{readFile: ioutil.ReadFile},
+		find:        func() (string, error) { return "/dev/sr0", nil },
+		mount: func(device, dir string) error {
+			if device != "/dev/sr0" {
+				return errors.New("bad device")
+			}
+			mounted = append(mounted, dir)
+			if err := os.MkdirAll(path.Join(dir, "openstack", "latest"), 0755); err != nil {
+				return err
+			}
+			if err := ioutil.WriteFile(path.Join(dir, "openstack", "latest", "meta_data.json"), []byte(`{"hostname": "host", "uuid": "1234"}`), 0644); err != nil {
+				return err
+			}
+			return ioutil.WriteFile(path.Join(dir, "openstack", "latest", "user_data"), []byte("#cloud-config"), 0644)
+		},
+		unmount: func(dir string) error {
+			for i, d := range mounted {
+				if d == dir {
+					mounted = append(mounted[:i], mounted[i+1:]...)
+					return os.RemoveAll(path.Join(dir, "openstack"))
+				}
+			}
+			return errors.New("not mounted")
+		},
+	}
+
+	if _, err := md.FetchUserdata(); err != ErrNoDevice {
+		t.Fatalf("bad error: want %v, got %v", ErrNoDevice, err)
+	}
+	if !md.IsAvailable() {
+		t.Fatalf("drive not available")
+	}
+	metadata, err := md.FetchMetadata()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if expect := (datasource.Metadata{InstanceID: "1234", Hostname: "host", Raw: []byte(`{"hostname": "host", "uuid": "1234"}`)}); !reflect.DeepEqual(expect, metadata) {
+		t.Fatalf("bad metadata: want %#v, got %#v", expect, metadata)
+	}
+	userdata, err := md.FetchUserdata()
+	if err != nil || string(userdata) != "#cloud-config" {
+		t.Fatalf("bad userdata: want %q, got %q (%v)", "#cloud-config", userdata, err)
+	}
+	if len(mounted) != 1 {
+		t.Fatalf("bad mounts: want 1, got %v", mounted)
+	}
+	if root := md.ConfigRoot(); root != mounted[0] {
+		t.Fatalf("bad config root: want %q, got %q", mounted[0], root)
+	}
+	if _, err := os.Stat(path.Join(md.ConfigRoot(), "openstack", "latest", "user_data")); err != nil {
+		t.Fatalf("bad config root: %v", err)
+	}
+
+	root := md.ConfigRoot()
+	if err := md.Unmount(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(mounted) != 0 {
+		t.Fatalf("drive left mounted at %v", mounted)
+	}
+	if _, err := os.Stat(root); !os.IsNotExist(err) {
+		t.Fatalf("mount point %q left behind (%v)", root, err)
+	}
+	if md.root != "" {
+		t.Fatalf("bad root: want %q, got %q", "", md.root)
+	}
+}
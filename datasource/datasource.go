@@ -24,15 +24,42 @@ type Datasource interface {
 	ConfigRoot() string
 	FetchMetadata() (Metadata, error)
 	FetchUserdata() ([]byte, error)
+	FetchVendordata() ([]byte, error)
 	Type() string
 }
 
+// ReadyReporter is implemented by datasources for platforms which must be
+// told that the machine has been provisioned.
+type ReadyReporter interface {
+	ReportReady() error
+}
+
+// Unmounter is implemented by datasources which mount the media they read
+// from themselves, so that it can be unmounted once the data has been used.
+type Unmounter interface {
+	Unmount() error
+}
+
+// PasswordProvider is implemented by datasources for platforms which hand out
+// a clear text password for the default user.
+type PasswordProvider interface {
+	FetchPassword() (string, error)
+}
+
 type Metadata struct {
-	PublicIPv4    net.IP
-	PublicIPv6    net.IP
-	PrivateIPv4   net.IP
-	PrivateIPv6   net.IP
-	Hostname      string
-	SSHPublicKeys map[string]string
-	NetworkConfig interface{}
+	InstanceID          string
+	Region              string
+	AvailabilityZone    string
+	PublicIPv4          net.IP
+	PublicIPv6          net.IP
+	PrivateIPv4         net.IP
+	PrivateIPv6         net.IP
+	FloatingIPv4        net.IP
+	Hostname            string
+	SSHPublicKeys       map[string]string
+	BlockDeviceMappings map[string]string
+	NetworkConfig       interface{}
+	// Raw is the metadata document as served by the datasource, usually
+	// JSON, from which the other fields were derived.
+	Raw []byte
 }
@@ -0,0 +1,138 @@
+// Copyright 2015 CoreOS, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package detect
+
+import (
+	"io/ioutil"
+	"log"
+	"os"
+	"path"
+	"strings"
+)
+
+const (
+	DefaultRoot = "/"
+
+	dmiPath       = "sys/class/dmi/id"
+	labelPath     = "dev/disk/by-label"
+	hypervisorDir = "sys/hypervisor"
+)
+
+// Platform names returned by Detect. They match the names accepted by the
+// -oem flag where such an OEM exists.
+const (
+	Azure        = "azure"
+	CloudSigma   = "cloudsigma"
+	ConfigDrive  = "configdrive"
+	DigitalOcean = "digitalocean"
+	EC2          = "ec2-compat"
+	Hetzner      = "hetzner"
+	OpenStack    = "openstack"
+	Scaleway     = "scaleway"
+	VMware       = "vmware"
+	Vultr        = "vultr"
+)
+
+// azureAssetTag is the chassis asset tag set on every Azure virtual machine.
+const azureAssetTag = "7783-7084-3265-9085-8269-3286-77"
+
+// Detector guesses the platform a machine is running on from DMI/SMBIOS
+// data, block device labels and hypervisor hints found under root.
+type Detector struct {
+	root string
+}
+
+func NewDetector(root string) *Detector {
+	return &Detector{root}
+}
+
+// Detect returns the names of the platforms the machine appears to be running
+// on, most specific first. Every name appears at most once.
+func (d *Detector) Detect() []string {
+	var platforms []string
+	add := func(platform, reason string) {
+		for _, p := range platforms {
+			if p == platform {
+				return
+			}
+		}
+		log.Printf("Detected platform %q (%s)\n", platform, reason)
+		platforms = append(platforms, platform)
+	}
+
+	vendor := d.dmi("sys_vendor")
+	product := d.dmi("product_name")
+	assetTag := d.dmi("chassis_asset_tag")
+	serial := d.dmi("product_serial")
+
+	switch {
+	case vendor == "DigitalOcean":
+		add(DigitalOcean, "sys_vendor is "+vendor)
+	case strings.HasPrefix(vendor, "Amazon EC2"):
+		add(EC2, "sys_vendor is "+vendor)
+	case strings.HasPrefix(strings.ToLower(serial), "ec2"):
+		add(EC2, "product_serial is "+serial)
+	case assetTag == azureAssetTag:
+		add(Azure, "chassis_asset_tag is "+assetTag)
+	case strings.HasPrefix(product, "OpenStack"), strings.HasPrefix(vendor, "OpenStack"):
+		add(OpenStack, "product_name is "+product)
+	case assetTag == "OpenTelekomCloud":
+		add(OpenStack, "chassis_asset_tag is "+assetTag)
+	case vendor == "Hetzner":
+		add(Hetzner, "sys_vendor is "+vendor)
+	case vendor == "Vultr":
+		add(Vultr, "sys_vendor is "+vendor)
+	case vendor == "Scaleway", strings.HasPrefix(product, "Scaleway"):
+		add(Scaleway, "product_name is "+product)
+	case strings.HasPrefix(product, "CloudSigma"):
+		add(CloudSigma, "product_name is "+product)
+	case strings.Contains(vendor, "VMware"), strings.Contains(product, "VMware"):
+		add(VMware, "product_name is "+product)
+	}
+
+	if d.hasLabel("config-2") || d.hasLabel("CONFIG-2") {
+		add(ConfigDrive, "found block device labelled config-2")
+	}
+
+	// Older EC2 instances run under Xen and expose no useful DMI data.
+	if uuid := d.read(path.Join(hypervisorDir, "uuid")); strings.HasPrefix(strings.ToLower(uuid), "ec2") {
+		add(EC2, "hypervisor uuid is "+uuid)
+	}
+
+	return platforms
+}
+
+// SystemUUID returns the SMBIOS system UUID of the machine, or an empty
+// string if it cannot be read.
+func (d *Detector) SystemUUID() string {
+	return strings.ToLower(d.dmi("product_uuid"))
+}
+
+func (d *Detector) dmi(name string) string {
+	return d.read(path.Join(dmiPath, name))
+}
+
+func (d *Detector) hasLabel(label string) bool {
+	_, err := os.Lstat(path.Join(d.root, labelPath, label))
+	return err == nil
+}
+
+func (d *Detector) read(name string) string {
+	data, err := ioutil.ReadFile(path.Join(d.root, name))
+	if err != nil {
+		return ""
+	}
+	return strings.TrimSpace(string(data))
+}
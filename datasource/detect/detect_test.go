@@ -0,0 +1,116 @@
+// Copyright 2015 CoreOS, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package detect
+
+import (
+	"io/ioutil"
+	"os"
+	"path"
+	"reflect"
+	"testing"
+)
+
+func TestDetect(t *testing.T) {
+	for _, tt := range []struct {
+		files  map[string]string
+		labels []string
+
+		platforms []string
+	}{
+		{},
+		{
+			files:     map[string]string{"sys/class/dmi/id/sys_vendor": "DigitalOcean\n"},
+			platforms: []string{DigitalOcean},
+		},
+		{
+			files:     map[string]string{"sys/class/dmi/id/sys_vendor": "Hetzner\n"},
+			platforms: []string{Hetzner},
+		},
+		{
+			files:     map[string]string{"sys/class/dmi/id/sys_vendor": "Vultr\n"},
+			platforms: []string{Vultr},
+		},
+		{
+			files:     map[string]string{"sys/class/dmi/id/sys_vendor": "Scaleway\n", "sys/class/dmi/id/product_name": "Scaleway DEV1-S\n"},
+			platforms: []string{Scaleway},
+		},
+		{
+			files: map[string]string{
+				"sys/class/dmi/id/sys_vendor":   "Xen\n",
+				"sys/class/dmi/id/product_name": "HVM domU\n",
+				"sys/hypervisor/uuid":           "ec2e1916-9099-7caf-fd21-012345abcdef\n",
+			},
+			platforms: []string{EC2},
+		},
+		{
+			files: map[string]string{
+				"sys/class/dmi/id/sys_vendor":     "Amazon EC2\n",
+				"sys/class/dmi/id/product_serial": "ec2e1916-9099-7caf-fd21-012345abcdef\n",
+			},
+			platforms: []string{EC2},
+		},
+		{
+			files: map[string]string{
+				"sys/class/dmi/id/sys_vendor":        "Microsoft Corporation\n",
+				"sys/class/dmi/id/chassis_asset_tag": "7783-7084-3265-9085-8269-3286-77\n",
+			},
+			platforms: []string{Azure},
+		},
+		{
+			files:     map[string]string{"sys/class/dmi/id/product_name": "OpenStack Nova\n"},
+			labels:    []string{"config-2"},
+			platforms: []string{OpenStack, ConfigDrive},
+		},
+		{
+			files:     map[string]string{"sys/class/dmi/id/product_name": "VMware Virtual Platform\n"},
+			platforms: []string{VMware},
+		},
+		{
+			files:     map[string]string{"sys/class/dmi/id/product_name": "CloudSigma\n"},
+			platforms: []string{CloudSigma},
+		},
+		{
+			// No datasource reads NoCloud volumes.
+			labels: []string{"cidata"},
+		},
+	} {
+		root, err := ioutil.TempDir(os.TempDir(), "coreos-cloudinit-")
+		if err != nil {
+			t.Fatalf("Unable to create tempdir: %v", err)
+		}
+		defer os.RemoveAll(root)
+
+		for name, contents := range tt.files {
+			if err := os.MkdirAll(path.Dir(path.Join(root, name)), 0755); err != nil {
+				t.Fatalf("Unable to create directory: %v", err)
+			}
+			if err := ioutil.WriteFile(path.Join(root, name), []byte(contents), 0644); err != nil {
+				t.Fatalf("Unable to write file: %v", err)
+			}
+		}
+		for _, label := range tt.labels {
+			if err := os.MkdirAll(path.Join(root, labelPath), 0755); err != nil {
+				t.Fatalf("Unable to create directory: %v", err)
+			}
+			if err := os.Symlink("../../sr0", path.Join(root, labelPath, label)); err != nil {
+				t.Fatalf("Unable to create symlink: %v", err)
+			}
+		}
+
+		if platforms := NewDetector(root).Detect(); !reflect.DeepEqual(tt.platforms, platforms) {
+			t.Errorf("bad platforms for %v %v: want %q, got %q", tt.files, tt.labels, tt.platforms, platforms)
+		}
+	}
+}
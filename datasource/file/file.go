@@ -50,6 +50,10 @@ func (f *localFile) FetchUserdata() ([]byte, error) {
 	return ioutil.ReadFile(f.path)
 }
 
+func (f *localFile) FetchVendordata() ([]byte, error) {
+	return nil, nil
+}
+
 func (f *localFile) Type() string {
 	return "local-file"
 }
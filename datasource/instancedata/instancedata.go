@@ -0,0 +1,278 @@
+// Copyright 2015 CoreOS, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package instancedata
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"os"
+	"path"
+	"strconv"
+	"strings"
+
+	"github.com/coreos/coreos-cloudinit/datasource"
+	"github.com/coreos/coreos-cloudinit/datasource/detect"
+
+	"gopkg.in/yaml.v2"
+)
+
+const (
+	DefaultDir    = "/run/cloudinit"
+	File          = "instance-data.json"
+	SensitiveFile = "instance-data-sensitive.json"
+
+	redacted = "redacted for non-root user"
+)
+
+// sensitiveKeys are the parts of the keys of the raw metadata whose values
+// are only written to the sensitive document. Keys are compared without case,
+// dashes and underscores, so that e.g. "user_data" and "cloudinit-user-data"
+// both match "userdata".
+var sensitiveKeys = []string{"userdata", "vendordata", "customdata", "password"}
+
+// platforms maps datasource types to the platform they imply.
+var platforms = map[string]string{
+	"azure":                         detect.Azure,
+	"cloud-drive":                   detect.ConfigDrive,
+	"cloudstack-metadata-service":   "cloudstack",
+	"digitalocean-metadata-service": detect.DigitalOcean,
+	"ec2-metadata-service":          detect.EC2,
+	"hetzner-metadata-service":      detect.Hetzner,
+	"openstack-metadata-service":    detect.OpenStack,
+	"packet-metadata-service":       "packet",
+	"scaleway-metadata-service":     detect.Scaleway,
+	"server-context":                detect.CloudSigma,
+	"vmware":                        detect.VMware,
+	"vultr-metadata-service":        detect.Vultr,
+	"waagent":                       detect.Azure,
+}
+
+// Platform returns the platform implied by a datasource type, or an empty
+// string if the datasource can be used on any platform.
+func Platform(dsType string) string {
+	return platforms[dsType]
+}
+
+// V1 holds the normalized values of the instance-data document. Its keys are
+// stable across datasources.
+type V1 struct {
+	Platform         string            `json:"platform"`
+	Datasource       string            `json:"datasource"`
+	Region           string            `json:"region"`
+	AvailabilityZone string            `json:"availability_zone"`
+	InstanceID       string            `json:"instance_id"`
+	LocalHostname    string            `json:"local_hostname"`
+	PublicIPv4       net.IP            `json:"public_ipv4,omitempty"`
+	PublicIPv6       net.IP            `json:"public_ipv6,omitempty"`
+	PrivateIPv4      net.IP            `json:"private_ipv4,omitempty"`
+	PrivateIPv6      net.IP            `json:"private_ipv6,omitempty"`
+	PublicSSHKeys    map[string]string `json:"public_ssh_keys"`
+}
+
+// InstanceData is the document written to instance-data.json. Datasource
+// holds the raw metadata as provided by the datasource.
+type InstanceData struct {
+	V1         V1          `json:"v1"`
+	Datasource interface{} `json:"ds"`
+}
+
+// sensitiveData is the document written to instance-data-sensitive.json,
+// which is only readable by root.
+type sensitiveData struct {
+	InstanceData
+	Userdata   string `json:"user_data"`
+	Vendordata string `json:"vendor_data"`
+}
+
+func New(platform, dsType string, metadata datasource.Metadata) InstanceData {
+	keys := metadata.SSHPublicKeys
+	if keys == nil {
+		keys = map[string]string{}
+	}
+	return InstanceData{
+		V1: V1{
+			Platform:         platform,
+			Datasource:       dsType,
+			Region:           metadata.Region,
+			AvailabilityZone: metadata.AvailabilityZone,
+			InstanceID:       metadata.InstanceID,
+			LocalHostname:    metadata.Hostname,
+			PublicIPv4:       metadata.PublicIPv4,
+			PublicIPv6:       metadata.PublicIPv6,
+			PrivateIPv4:      metadata.PrivateIPv4,
+			PrivateIPv6:      metadata.PrivateIPv6,
+			PublicSSHKeys:    keys,
+		},
+		Datasource: rawMetadata(metadata.Raw),
+	}
+}
+
+// rawMetadata returns the metadata document of the datasource in a form which
+// is serialized as JSON. Documents which are neither JSON nor YAML, such as
+// the XML of waagent, are kept as a string.
+func rawMetadata(data []byte) interface{} {
+	if len(data) == 0 {
+		return nil
+	}
+	var doc interface{}
+	if err := json.Unmarshal(data, &doc); err == nil {
+		return doc
+	}
+	if err := yaml.Unmarshal(data, &doc); err == nil {
+		switch doc.(type) {
+		case map[interface{}]interface{}, []interface{}:
+			return jsonValue(doc)
+		}
+	}
+	return string(data)
+}
+
+// jsonValue converts a value decoded from YAML into one which can be
+// serialized as JSON, by turning the keys of all maps into strings.
+func jsonValue(value interface{}) interface{} {
+	switch v := value.(type) {
+	case map[interface{}]interface{}:
+		m := make(map[string]interface{}, len(v))
+		for key, val := range v {
+			m[fmt.Sprint(key)] = jsonValue(val)
+		}
+		return m
+	case []interface{}:
+		l := make([]interface{}, len(v))
+		for i, val := range v {
+			l[i] = jsonValue(val)
+		}
+		return l
+	}
+	return value
+}
+
+// Write writes the instance-data document to dir, along with a variant which
+// also contains the user-data and vendor-data and is only readable by root.
+func Write(dir string, data InstanceData, userdata, vendordata []byte) error {
+	public, err := json.MarshalIndent(InstanceData{data.V1, redact(data.Datasource)}, "", "  ")
+	if err != nil {
+		return err
+	}
+	sensitive, err := json.MarshalIndent(sensitiveData{data, string(userdata), string(vendordata)}, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return err
+	}
+	if err := writeFile(path.Join(dir, SensitiveFile), sensitive, 0600); err != nil {
+		return err
+	}
+	return writeFile(path.Join(dir, File), public, 0644)
+}
+
+// redact returns a copy of the raw metadata in which the values of sensitive
+// keys are replaced.
+func redact(value interface{}) interface{} {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		m := make(map[string]interface{}, len(v))
+		for key, val := range v {
+			if isSensitive(key) {
+				m[key] = redacted
+			} else {
+				m[key] = redact(val)
+			}
+		}
+		return m
+	case []interface{}:
+		l := make([]interface{}, len(v))
+		for i, val := range v {
+			l[i] = redact(val)
+		}
+		return l
+	}
+	return value
+}
+
+func isSensitive(key string) bool {
+	key = strings.ToLower(strings.NewReplacer("-", "", "_", "").Replace(key))
+	for _, s := range sensitiveKeys {
+		if strings.Contains(key, s) {
+			return true
+		}
+	}
+	return false
+}
+
+// writeFile atomically replaces filename, making sure it ends up with the
+// given permissions regardless of the umask or an earlier version.
+func writeFile(filename string, data []byte, perm os.FileMode) error {
+	tmp := filename + ".tmp"
+	if err := ioutil.WriteFile(tmp, data, perm); err != nil {
+		return err
+	}
+	if err := os.Chmod(tmp, perm); err != nil {
+		os.Remove(tmp)
+		return err
+	}
+	return os.Rename(tmp, filename)
+}
+
+// Read returns the instance-data document written to dir. The sensitive
+// variant is preferred if it is readable.
+func Read(dir string) (interface{}, error) {
+	data, err := ioutil.ReadFile(path.Join(dir, SensitiveFile))
+	if os.IsPermission(err) || os.IsNotExist(err) {
+		data, err = ioutil.ReadFile(path.Join(dir, File))
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var doc interface{}
+	if err := json.Unmarshal(data, &doc); err != nil {
+		return nil, err
+	}
+	return doc, nil
+}
+
+// Lookup returns the value found at the given dot-separated path within a
+// document returned by Read. Numeric path components index into lists. An
+// empty path returns the whole document.
+func Lookup(doc interface{}, query string) (interface{}, error) {
+	if query == "" {
+		return doc, nil
+	}
+
+	value := doc
+	for _, key := range strings.Split(query, ".") {
+		switch v := value.(type) {
+		case map[string]interface{}:
+			var ok bool
+			if value, ok = v[key]; !ok {
+				return nil, fmt.Errorf("no such key %q in %q", key, query)
+			}
+		case []interface{}:
+			i, err := strconv.Atoi(key)
+			if err != nil || i < 0 || i >= len(v) {
+				return nil, fmt.Errorf("invalid index %q in %q", key, query)
+			}
+			value = v[i]
+		default:
+			return nil, fmt.Errorf("cannot look up %q in %q: not an object or list", key, query)
+		}
+	}
+	return value, nil
+}
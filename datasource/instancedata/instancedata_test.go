@@ -0,0 +1,171 @@
+// Copyright 2015 CoreOS, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package instancedata
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net"
+	"os"
+	"path"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/coreos/coreos-cloudinit/datasource"
+)
+
+func TestNew(t *testing.T) {
+	for i, tt := range []struct {
+		metadata datasource.Metadata
+		ds       interface{}
+	}{
+		{
+			metadata: datasource.Metadata{},
+			ds:       nil,
+		},
+		{
+			metadata: datasource.Metadata{Raw: []byte(`{"links":[]}`)},
+			ds:       map[string]interface{}{"links": []interface{}{}},
+		},
+		{
+			metadata: datasource.Metadata{Raw: []byte("instance-id: 1\npublic-keys:\n- key\n")},
+			ds:       map[string]interface{}{"instance-id": 1, "public-keys": []interface{}{"key"}},
+		},
+		{
+			metadata: datasource.Metadata{Raw: []byte("<SharedConfig/>")},
+			ds:       "<SharedConfig/>",
+		},
+		{
+			metadata: datasource.Metadata{NetworkConfig: map[string]string{"interface.0.name": "eth0"}},
+			ds:       nil,
+		},
+	} {
+		data := New("test", "test-datasource", tt.metadata)
+		if !reflect.DeepEqual(tt.ds, data.Datasource) {
+			t.Errorf("bad ds (%d): want %#v, got %#v", i, tt.ds, data.Datasource)
+		}
+		if data.V1.PublicSSHKeys == nil {
+			t.Errorf("bad public_ssh_keys (%d): want non-nil map", i)
+		}
+	}
+}
+
+func TestWriteRead(t *testing.T) {
+	dir, err := ioutil.TempDir(os.TempDir(), "coreos-cloudinit-")
+	if err != nil {
+		t.Fatalf("bad tempdir: %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	data := New("ec2-compat", "ec2-metadata-service", datasource.Metadata{
+		InstanceID:       "i-12345678",
+		Region:           "us-west-2",
+		AvailabilityZone: "us-west-2a",
+		Hostname:         "host",
+		PublicIPv4:       net.ParseIP("5.6.7.8"),
+		SSHPublicKeys:    map[string]string{"test1": "key"},
+		Raw:              []byte(`{"instance-id": "i-12345678", "meta": {"cloudinit-user-data": "c2VjcmV0"}}`),
+	})
+	if err := Write(dir, data, []byte("#cloud-config"), nil); err != nil {
+		t.Fatalf("bad error: want %v, got %v", nil, err)
+	}
+
+	for filename, perm := range map[string]os.FileMode{File: 0644, SensitiveFile: 0600} {
+		fi, err := os.Stat(path.Join(dir, filename))
+		if err != nil {
+			t.Fatalf("bad error (%s): want %v, got %v", filename, nil, err)
+		}
+		if fi.Mode().Perm() != perm {
+			t.Errorf("bad permissions (%s): want %v, got %v", filename, perm, fi.Mode().Perm())
+		}
+	}
+
+	public, err := ioutil.ReadFile(path.Join(dir, File))
+	if err != nil {
+		t.Fatalf("bad error: want %v, got %v", nil, err)
+	}
+	if strings.Contains(string(public), "#cloud-config") || strings.Contains(string(public), "c2VjcmV0") {
+		t.Errorf("bad public document: contains user-data: %s", public)
+	}
+	var publicDoc interface{}
+	if err := json.Unmarshal(public, &publicDoc); err != nil {
+		t.Fatalf("bad error: want %v, got %v", nil, err)
+	}
+	if value, err := Lookup(publicDoc, "ds.meta.cloudinit-user-data"); err != nil || value != redacted {
+		t.Errorf("bad public value: want %q, got %#v (%v)", redacted, value, err)
+	}
+
+	doc, err := Read(dir)
+	if err != nil {
+		t.Fatalf("bad error: want %v, got %v", nil, err)
+	}
+	for _, tt := range []struct {
+		query string
+		value interface{}
+	}{
+		{"v1.instance_id", "i-12345678"},
+		{"v1.region", "us-west-2"},
+		{"v1.availability_zone", "us-west-2a"},
+		{"v1.local_hostname", "host"},
+		{"v1.public_ipv4", "5.6.7.8"},
+		{"v1.public_ssh_keys.test1", "key"},
+		{"ds.instance-id", "i-12345678"},
+		{"ds.meta.cloudinit-user-data", "c2VjcmV0"},
+		{"user_data", "#cloud-config"},
+		{"vendor_data", ""},
+	} {
+		value, err := Lookup(doc, tt.query)
+		if err != nil {
+			t.Errorf("bad error (%q): want %v, got %v", tt.query, nil, err)
+		}
+		if !reflect.DeepEqual(tt.value, value) {
+			t.Errorf("bad value (%q): want %#v, got %#v", tt.query, tt.value, value)
+		}
+	}
+}
+
+func TestLookup(t *testing.T) {
+	doc := map[string]interface{}{
+		"v1": map[string]interface{}{"platform": "packet"},
+		"ds": map[string]interface{}{
+			"interfaces": []interface{}{
+				map[string]interface{}{"name": "eth0"},
+			},
+		},
+	}
+
+	for _, tt := range []struct {
+		query string
+		value interface{}
+		err   bool
+	}{
+		{"", doc, false},
+		{"v1.platform", "packet", false},
+		{"ds.interfaces.0.name", "eth0", false},
+		{"ds.interfaces.1.name", nil, true},
+		{"ds.interfaces.first", nil, true},
+		{"v1.platform.name", nil, true},
+		{"v2", nil, true},
+	} {
+		value, err := Lookup(doc, tt.query)
+		if (err != nil) != tt.err {
+			t.Errorf("bad error (%q): want error %t, got %v", tt.query, tt.err, err)
+		}
+		if !reflect.DeepEqual(tt.value, value) {
+			t.Errorf("bad value (%q): want %#v, got %#v", tt.query, tt.value, value)
+		}
+	}
+}
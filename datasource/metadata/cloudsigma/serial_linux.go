@@ -0,0 +1,51 @@
+// Copyright 2015 CoreOS, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cloudsigma
+
+import (
+	"io"
+	"os"
+	"syscall"
+	"unsafe"
+
+	ioctl "github.com/vtolstov/go-ioctl"
+)
+
+// openSerial opens the serial port at device in raw mode, so that the EOT
+// terminating server context replies is passed through.
+func openSerial(device string) (io.ReadWriteCloser, error) {
+	f, err := os.OpenFile(device, os.O_RDWR|syscall.O_NOCTTY, 0)
+	if err != nil {
+		return nil, err
+	}
+
+	var t syscall.Termios
+	if err := ioctl.IOCTL(f.Fd(), syscall.TCGETS, uintptr(unsafe.Pointer(&t))); err != nil {
+		f.Close()
+		return nil, err
+	}
+	t.Iflag &^= syscall.IGNBRK | syscall.BRKINT | syscall.PARMRK | syscall.ISTRIP | syscall.INLCR | syscall.IGNCR | syscall.ICRNL | syscall.IXON
+	t.Oflag &^= syscall.OPOST
+	t.Lflag &^= syscall.ECHO | syscall.ECHONL | syscall.ICANON | syscall.ISIG | syscall.IEXTEN
+	t.Cflag &^= syscall.CSIZE | syscall.PARENB
+	t.Cflag |= syscall.CS8 | syscall.CLOCAL | syscall.CREAD
+	t.Cc[syscall.VMIN] = 1
+	t.Cc[syscall.VTIME] = 0
+	if err := ioctl.IOCTL(f.Fd(), syscall.TCSETS, uintptr(unsafe.Pointer(&t))); err != nil {
+		f.Close()
+		return nil, err
+	}
+	return f, nil
+}
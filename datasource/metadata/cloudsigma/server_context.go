@@ -0,0 +1,244 @@
+// Copyright 2015 CoreOS, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cloudsigma
+
+import (
+	"bufio"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"log"
+	"net"
+	"strings"
+	"time"
+
+	"github.com/coreos/coreos-cloudinit/datasource"
+	"github.com/coreos/coreos-cloudinit/pkg"
+)
+
+const (
+	DefaultDevice = "/dev/ttyS1"
+
+	productNamePath   = "/sys/class/dmi/id/product_name"
+	userdataFieldName = "cloudinit-user-data"
+
+	// requestPattern is the format of server context requests. An empty key
+	// requests the whole context.
+	requestPattern = "<\n%s\n>"
+	// eot terminates every server context reply.
+	eot = '\x04'
+	// replyTimeout bounds the wait for a server context reply, as the host
+	// may never answer.
+	replyTimeout = 10 * time.Second
+)
+
+// serverContext is the part of the CloudSigma server context used by the
+// datasource.
+type serverContext struct {
+	Name string            `json:"name"`
+	UUID string            `json:"uuid"`
+	Meta map[string]string `json:"meta"`
+	Nics []struct {
+		Mac      string `json:"mac"`
+		IPv4Conf struct {
+			InterfaceType string `json:"interface_type"`
+			IP            struct {
+				UUID string `json:"uuid"`
+			} `json:"ip"`
+		} `json:"ip_v4_conf"`
+		VLAN struct {
+			UUID string `json:"uuid"`
+		} `json:"vlan"`
+		Runtime struct {
+			IPv4 struct {
+				UUID string `json:"uuid"`
+			} `json:"ip_v4"`
+		} `json:"runtime"`
+	} `json:"nics"`
+}
+
+type serverContextService struct {
+	open      func() (io.ReadWriteCloser, error)
+	readFile  func(filename string) ([]byte, error)
+	findLocal func(mac string) (net.IP, error)
+	timeout   time.Duration
+}
+
+// NewServerContextService returns a datasource reading the CloudSigma server
+// context from the serial port at device.
+func NewServerContextService(device string) *serverContextService {
+	return &serverContextService{
+		open:      func() (io.ReadWriteCloser, error) { return openSerial(device) },
+		readFile:  ioutil.ReadFile,
+		findLocal: findLocalIP,
+		timeout:   replyTimeout,
+	}
+}
+
+func (scs *serverContextService) IsAvailable() bool {
+	productName, err := scs.readFile(productNamePath)
+	return err == nil && strings.HasPrefix(string(productName), "CloudSigma")
+}
+
+func (scs *serverContextService) AvailabilityChanges() bool {
+	return false
+}
+
+func (scs *serverContextService) ConfigRoot() string {
+	return ""
+}
+
+func (scs *serverContextService) FetchMetadata() (metadata datasource.Metadata, err error) {
+	var context serverContext
+	if context, metadata.Raw, err = scs.fetchContext(); err != nil {
+		return
+	}
+
+	metadata.InstanceID = context.UUID
+	if context.Name != "" {
+		metadata.Hostname = context.Name
+	} else {
+		metadata.Hostname = context.UUID
+	}
+
+	// CloudSigma instances are given a single public key, which is stored
+	// in the meta section as "ssh_public_key".
+	metadata.SSHPublicKeys = map[string]string{}
+	if fields := strings.Fields(context.Meta["ssh_public_key"]); len(fields) > 0 {
+		metadata.SSHPublicKeys[fields[len(fields)-1]] = context.Meta["ssh_public_key"]
+	}
+
+	for _, nic := range context.Nics {
+		if ip := net.ParseIP(nic.IPv4Conf.IP.UUID); ip != nil {
+			metadata.PublicIPv4 = ip
+		} else if ip := net.ParseIP(nic.Runtime.IPv4.UUID); ip != nil && nic.VLAN.UUID == "" {
+			metadata.PublicIPv4 = ip
+		}
+		if nic.VLAN.UUID != "" {
+			if ip, err := scs.findLocal(nic.Mac); err == nil {
+				metadata.PrivateIPv4 = ip
+			} else {
+				log.Printf("Failed finding the address of %q: %v\n", nic.Mac, err)
+			}
+		}
+	}
+
+	return
+}
+
+func (scs *serverContextService) FetchUserdata() ([]byte, error) {
+	context, _, err := scs.fetchContext()
+	if err != nil {
+		return []byte{}, err
+	}
+
+	userdata, ok := context.Meta[userdataFieldName]
+	if ok && isBase64Encoded(userdataFieldName, context.Meta) {
+		return base64.StdEncoding.DecodeString(userdata)
+	}
+	return []byte(userdata), nil
+}
+
+func (scs *serverContextService) FetchVendordata() ([]byte, error) {
+	return nil, nil
+}
+
+func (scs *serverContextService) Type() string {
+	return "server-context"
+}
+
+func (scs *serverContextService) fetchContext() (context serverContext, data []byte, err error) {
+	var port io.ReadWriteCloser
+	if port, err = scs.open(); err != nil {
+		return
+	}
+	defer port.Close()
+
+	if data, err = fetch(port, "", scs.timeout); err != nil {
+		return
+	}
+	err = json.Unmarshal(data, &context)
+	return
+}
+
+// fetch requests the given key of the server context over rw and returns the
+// raw reply. If no complete reply arrives within timeout, an ErrTimeout is
+// returned; the pending read only ends once the caller closes rw.
+func fetch(rw io.ReadWriter, key string, timeout time.Duration) ([]byte, error) {
+	if _, err := fmt.Fprintf(rw, requestPattern, key); err != nil {
+		return nil, err
+	}
+
+	type result struct {
+		reply []byte
+		err   error
+	}
+	done := make(chan result, 1)
+	go func() {
+		reply, err := bufio.NewReader(rw).ReadBytes(eot)
+		done <- result{reply, err}
+	}()
+
+	select {
+	case r := <-done:
+		if r.err != nil {
+			return nil, r.err
+		}
+		return r.reply[:len(r.reply)-1], nil
+	case <-time.After(timeout):
+		return nil, pkg.ErrTimeout{Err: fmt.Errorf("no reply from the server context within %v", timeout)}
+	}
+}
+
+// isBase64Encoded reports whether field is listed in the comma-separated
+// base64_fields of the meta section.
+func isBase64Encoded(field string, meta map[string]string) bool {
+	for _, f := range strings.Split(meta["base64_fields"], ",") {
+		if strings.TrimSpace(f) == field {
+			return true
+		}
+	}
+	return false
+}
+
+// findLocalIP returns the first IPv4 address of the interface with the given
+// MAC address. Private networks on CloudSigma are configured by DHCP.
+func findLocalIP(mac string) (net.IP, error) {
+	hwaddr, err := net.ParseMAC(mac)
+	if err != nil {
+		return nil, err
+	}
+	ifaces, err := net.Interfaces()
+	if err != nil {
+		return nil, err
+	}
+	for _, iface := range ifaces {
+		if iface.HardwareAddr.String() != hwaddr.String() {
+			continue
+		}
+		addrs, err := iface.Addrs()
+		if err != nil {
+			return nil, err
+		}
+		for _, addr := range addrs {
+			if ipnet, ok := addr.(*net.IPNet); ok && ipnet.IP.To4() != nil {
+				return ipnet.IP, nil
+			}
+		}
+	}
+	return nil, fmt.Errorf("no IPv4 address found for %s", mac)
+}
@@ -0,0 +1,231 @@
+// Copyright 2015 CoreOS, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cloudsigma
+
+import (
+	"bufio"
+	"errors"
+	"io"
+	"net"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/coreos/coreos-cloudinit/datasource"
+	"github.com/coreos/coreos-cloudinit/pkg"
+)
+
+// pipePort is one end of a pair of pipes connected to a fake server context.
+type pipePort struct {
+	io.Reader
+	io.Writer
+}
+
+func (p pipePort) Close() error {
+	p.Writer.(io.Closer).Close()
+	return nil
+}
+
+// serve answers a single server context request read from r with context
+// followed by EOT, and returns the request.
+func serve(r io.Reader, w io.WriteCloser, context string, requests chan<- string) {
+	request, _ := bufio.NewReader(r).ReadString('>')
+	requests <- request
+	io.WriteString(w, context+"\x04")
+	w.Close()
+}
+
+func newTestService(context string, requests chan<- string) *serverContextService {
+	return &serverContextService{
+		open: func() (io.ReadWriteCloser, error) {
+			clientR, serverW := io.Pipe()
+			serverR, clientW := io.Pipe()
+			go serve(serverR, serverW, context, requests)
+			return pipePort{clientR, clientW}, nil
+		},
+		readFile: func(string) ([]byte, error) { return []byte("CloudSigma\n"), nil },
+		timeout:  time.Second,
+		findLocal: func(mac string) (net.IP, error) {
+			if mac == "22:40:85:4f:d3:ce" {
+				return net.ParseIP("192.168.1.10"), nil
+			}
+			return nil, errors.New("not found")
+		},
+	}
+}
+
+const testContext = `{
+  "context": true,
+  "cpu": 4000,
+  "meta": {
+    "base64_fields": "cloudinit-user-data",
+    "cloudinit-user-data": "I2Nsb3VkLWNvbmZpZwoKaG9zdG5hbWU6IGNvcmVvczE=",
+    "ssh_public_key": "ssh-rsa AAAAB3NzaC1yc2E.../hQ5D5 john@doe"
+  },
+  "name": "coreos",
+  "nics": [
+    {
+      "ip_v4_conf": {
+        "conf": "static",
+        "ip": {
+          "uuid": "31.171.251.74"
+        }
+      },
+      "mac": "22:3d:09:6b:90:f3",
+      "vlan": null
+    },
+    {
+      "ip_v4_conf": null,
+      "mac": "22:40:85:4f:d3:ce",
+      "vlan": {
+        "uuid": "5dec030e-25b8-4621-a5a4-a3302c9d9619"
+      }
+    }
+  ],
+  "uuid": "20a0059b-041e-4d0c-bcc6-9b2852de48b3"
+}`
+
+func TestFetch(t *testing.T) {
+	requests := make(chan string, 1)
+	port, _ := newTestService(`{"name": "coreos"}`, requests).open()
+	defer port.Close()
+
+	data, err := fetch(port, "", time.Second)
+	if err != nil {
+		t.Fatalf("bad error: want %v, got %v", nil, err)
+	}
+	if string(data) != `{"name": "coreos"}` {
+		t.Errorf("bad reply: want %q, got %q", `{"name": "coreos"}`, data)
+	}
+	if request := <-requests; request != "<\n\n>" {
+		t.Errorf("bad request: want %q, got %q", "<\n\n>", request)
+	}
+}
+
+func TestFetchTimeout(t *testing.T) {
+	clientR, serverW := io.Pipe()
+	serverR, clientW := io.Pipe()
+	go bufio.NewReader(serverR).ReadString('>')
+	port := pipePort{clientR, clientW}
+	defer serverW.Close()
+	defer port.Close()
+
+	if _, err := fetch(port, "", 10*time.Millisecond); !isTimeout(err) {
+		t.Errorf("bad error: want timeout, got %v", err)
+	}
+}
+
+func isTimeout(err error) bool {
+	_, ok := err.(pkg.ErrTimeout)
+	return ok
+}
+
+func TestServerContextFetchMetadata(t *testing.T) {
+	for i, tt := range []struct {
+		context  string
+		metadata datasource.Metadata
+		err      bool
+	}{
+		{
+			context: testContext,
+			metadata: datasource.Metadata{
+				InstanceID:    "20a0059b-041e-4d0c-bcc6-9b2852de48b3",
+				Hostname:      "coreos",
+				PublicIPv4:    net.ParseIP("31.171.251.74"),
+				PrivateIPv4:   net.ParseIP("192.168.1.10"),
+				SSHPublicKeys: map[string]string{"john@doe": "ssh-rsa AAAAB3NzaC1yc2E.../hQ5D5 john@doe"},
+			},
+		},
+		{
+			context: `{"uuid": "20a0059b", "nics": [{"mac": "22:3d:09:6b:90:f3", "ip_v4_conf": {"conf": "dhcp"}, "runtime": {"ip_v4": {"uuid": "31.171.251.75"}}}]}`,
+			metadata: datasource.Metadata{
+				InstanceID:    "20a0059b",
+				Hostname:      "20a0059b",
+				PublicIPv4:    net.ParseIP("31.171.251.75"),
+				SSHPublicKeys: map[string]string{},
+			},
+		},
+		{
+			context: `{"uuid": "20a0059b", "meta": {"ssh_public_key": " \n"}}`,
+			metadata: datasource.Metadata{
+				InstanceID:    "20a0059b",
+				Hostname:      "20a0059b",
+				SSHPublicKeys: map[string]string{},
+			},
+		},
+		{
+			context: "bad",
+			err:     true,
+		},
+	} {
+		metadata, err := newTestService(tt.context, make(chan string, 1)).FetchMetadata()
+		if (err != nil) != tt.err {
+			t.Errorf("bad error (%d): want error %t, got %v", i, tt.err, err)
+		}
+		tt.metadata.Raw = []byte(tt.context)
+		if !tt.err && !reflect.DeepEqual(tt.metadata, metadata) {
+			t.Errorf("bad metadata (%d): want %#v, got %#v", i, tt.metadata, metadata)
+		}
+	}
+}
+
+func TestServerContextFetchUserdata(t *testing.T) {
+	for i, tt := range []struct {
+		context  string
+		userdata string
+	}{
+		{testContext, "#cloud-config\n\nhostname: coreos1"},
+		{`{"meta": {"cloudinit-user-data": "#cloud-config"}}`, "#cloud-config"},
+		{`{"meta": {}}`, ""},
+	} {
+		userdata, err := newTestService(tt.context, make(chan string, 1)).FetchUserdata()
+		if err != nil {
+			t.Errorf("bad error (%d): want %v, got %v", i, nil, err)
+		}
+		if string(userdata) != tt.userdata {
+			t.Errorf("bad userdata (%d): want %q, got %q", i, tt.userdata, userdata)
+		}
+	}
+}
+
+func TestServerContextIsAvailable(t *testing.T) {
+	for _, tt := range []struct {
+		productName string
+		available   bool
+	}{
+		{"CloudSigma\n", true},
+		{"KVM\n", false},
+	} {
+		scs := &serverContextService{readFile: func(string) ([]byte, error) { return []byte(tt.productName), nil }}
+		if available := scs.IsAvailable(); available != tt.available {
+			t.Errorf("bad availability (%q): want %t, got %t", tt.productName, tt.available, available)
+		}
+	}
+}
+
+func TestIsBase64Encoded(t *testing.T) {
+	for _, tt := range []struct {
+		meta    map[string]string
+		encoded bool
+	}{
+		{map[string]string{}, false},
+		{map[string]string{"base64_fields": "foo"}, false},
+		{map[string]string{"base64_fields": "foo, cloudinit-user-data"}, true},
+	} {
+		if encoded := isBase64Encoded(userdataFieldName, tt.meta); encoded != tt.encoded {
+			t.Errorf("bad result (%q): want %t, got %t", tt.meta, tt.encoded, encoded)
+		}
+	}
+}
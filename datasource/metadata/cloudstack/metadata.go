@@ -0,0 +1,249 @@
+// Copyright 2015 CoreOS, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cloudstack
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/coreos/coreos-cloudinit/datasource"
+	"github.com/coreos/coreos-cloudinit/datasource/metadata"
+	"github.com/coreos/coreos-cloudinit/pkg"
+)
+
+const (
+	apiVersion   = "latest/"
+	userdataPath = apiVersion + "user-data"
+	metadataPath = apiVersion + "meta-data"
+
+	// passwordPort is the port of the virtual router's password server.
+	passwordPort = "8080"
+)
+
+// DefaultLeaseFiles are the lease files searched for the address of the DHCP
+// server, which on CloudStack is the virtual router serving the metadata.
+var DefaultLeaseFiles = []string{
+	"/run/systemd/netif/leases/*",
+	"/var/lib/dhclient/*.lease*",
+	"/var/lib/dhcp/*.lease*",
+}
+
+var ErrNoRouter = errors.New("no DHCP server found in the lease files")
+
+type doer interface {
+	Do(*http.Request) ([]byte, error)
+}
+
+type metadataService struct {
+	metadata.MetadataService
+	discover       func() (net.IP, error)
+	passwordServer string
+	passwordClient doer
+}
+
+// NewDatasource returns a datasource which finds the virtual router in the
+// given lease files.
+func NewDatasource(leaseFiles []string) *metadataService {
+	return &metadataService{
+		MetadataService: metadata.NewDatasource("", apiVersion, userdataPath, metadataPath),
+		discover:        func() (net.IP, error) { return discoverRouter(leaseFiles) },
+		passwordClient:  pkg.NewHttpClient(),
+	}
+}
+
+func (ms *metadataService) IsAvailable() bool {
+	router, err := ms.discover()
+	if err != nil {
+		log.Printf("Failed to find the CloudStack virtual router: %v\n", err)
+		return false
+	}
+	ms.Root = fmt.Sprintf("http://%s/", hostPort(router, ""))
+	ms.passwordServer = fmt.Sprintf("http://%s/", hostPort(router, passwordPort))
+	return ms.MetadataService.IsAvailable()
+}
+
+func (ms *metadataService) FetchMetadata() (metadata datasource.Metadata, err error) {
+	attrs := map[string]*string{
+		"instance-id":       &metadata.InstanceID,
+		"local-hostname":    &metadata.Hostname,
+		"availability-zone": &metadata.AvailabilityZone,
+	}
+	// raw collects the attributes which are set by name, as CloudStack
+	// has no single metadata document.
+	raw := map[string]interface{}{}
+	for name, value := range attrs {
+		if *value, err = ms.fetchAttribute(name); err != nil {
+			return
+		}
+		raw[name] = *value
+	}
+
+	var addr string
+	if addr, err = ms.fetchAttribute("local-ipv4"); err != nil {
+		return
+	}
+	metadata.PrivateIPv4 = net.ParseIP(addr)
+	raw["local-ipv4"] = addr
+	if addr, err = ms.fetchAttribute("public-ipv4"); err != nil {
+		return
+	}
+	metadata.PublicIPv4 = net.ParseIP(addr)
+	raw["public-ipv4"] = addr
+
+	var data []byte
+	if data, err = ms.FetchData(ms.MetadataUrl() + "/public-keys"); err != nil {
+		return
+	}
+	metadata.SSHPublicKeys = map[string]string{}
+	scanner := bufio.NewScanner(strings.NewReader(string(data)))
+	for i := 0; scanner.Scan(); {
+		if key := strings.TrimSpace(scanner.Text()); key != "" {
+			metadata.SSHPublicKeys[fmt.Sprintf("%d", i)] = key
+			i++
+		}
+	}
+	if err = scanner.Err(); err != nil {
+		return
+	}
+	raw["public-keys"] = metadata.SSHPublicKeys
+	for name, value := range raw {
+		if value == "" {
+			delete(raw, name)
+		}
+	}
+	metadata.Raw, err = json.Marshal(raw)
+	return
+}
+
+// FetchPassword asks the password server of the virtual router for the
+// password of the default user. The server only hands out a password once,
+// so it is acknowledged as saved as soon as it has been received. An empty
+// password is returned if none is pending.
+func (ms *metadataService) FetchPassword() (string, error) {
+	data, err := ms.requestPassword("send_my_password")
+	if err != nil {
+		return "", err
+	}
+	switch password := strings.TrimSpace(string(data)); password {
+	case "", "saved_password", "bad_request":
+		return "", nil
+	default:
+		if _, err := ms.requestPassword("saved_password"); err != nil {
+			log.Printf("Failed to acknowledge the password: %v\n", err)
+		}
+		return password, nil
+	}
+}
+
+func (ms *metadataService) Type() string {
+	return "cloudstack-metadata-service"
+}
+
+func (ms *metadataService) requestPassword(request string) ([]byte, error) {
+	req, err := http.NewRequest("GET", ms.passwordServer, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("DomU_Request", request)
+	return ms.passwordClient.Do(req)
+}
+
+func (ms *metadataService) fetchAttribute(name string) (string, error) {
+	data, err := ms.FetchData(ms.MetadataUrl() + "/" + name)
+	return strings.TrimSpace(string(data)), err
+}
+
+func hostPort(ip net.IP, port string) string {
+	if port == "" {
+		if ip.To4() == nil {
+			return "[" + ip.String() + "]"
+		}
+		return ip.String()
+	}
+	return net.JoinHostPort(ip.String(), port)
+}
+
+// discoverRouter returns the DHCP server found in the most recently modified
+// of the lease files matching patterns.
+func discoverRouter(patterns []string) (net.IP, error) {
+	var files []string
+	for _, pattern := range patterns {
+		matches, err := filepath.Glob(pattern)
+		if err != nil {
+			return nil, err
+		}
+		files = append(files, matches...)
+	}
+	sort.Sort(byModTime(files))
+
+	for _, file := range files {
+		data, err := ioutil.ReadFile(file)
+		if err != nil {
+			continue
+		}
+		if ip := parseLease(string(data)); ip != nil {
+			return ip, nil
+		}
+	}
+	return nil, ErrNoRouter
+}
+
+// parseLease extracts the DHCP server address from a systemd-networkd or a
+// dhclient lease file. dhclient appends leases, so the last one wins.
+func parseLease(lease string) (ip net.IP) {
+	scanner := bufio.NewScanner(strings.NewReader(lease))
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		switch {
+		case strings.HasPrefix(line, "SERVER_ADDRESS="):
+			if addr := net.ParseIP(strings.TrimPrefix(line, "SERVER_ADDRESS=")); addr != nil {
+				ip = addr
+			}
+		case strings.HasPrefix(line, "option dhcp-server-identifier "):
+			value := strings.TrimPrefix(line, "option dhcp-server-identifier ")
+			if addr := net.ParseIP(strings.TrimSuffix(value, ";")); addr != nil {
+				ip = addr
+			}
+		}
+	}
+	return
+}
+
+// byModTime orders files from the most to the least recently modified.
+type byModTime []string
+
+func (f byModTime) Len() int      { return len(f) }
+func (f byModTime) Swap(i, j int) { f[i], f[j] = f[j], f[i] }
+func (f byModTime) Less(i, j int) bool {
+	return modTime(f[i]).After(modTime(f[j]))
+}
+
+func modTime(file string) time.Time {
+	if info, err := os.Stat(file); err == nil {
+		return info.ModTime()
+	}
+	return time.Time{}
+}
@@ -0,0 +1,241 @@
+// Copyright 2015 CoreOS, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cloudstack
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/coreos/coreos-cloudinit/datasource"
+	"github.com/coreos/coreos-cloudinit/datasource/metadata"
+	"github.com/coreos/coreos-cloudinit/datasource/metadata/test"
+	"github.com/coreos/coreos-cloudinit/pkg"
+)
+
+func TestType(t *testing.T) {
+	want := "cloudstack-metadata-service"
+	if kind := (&metadataService{}).Type(); kind != want {
+		t.Fatalf("bad type: want %q, got %q", want, kind)
+	}
+}
+
+func TestParseLease(t *testing.T) {
+	for _, tt := range []struct {
+		lease string
+		ip    net.IP
+	}{
+		{lease: "", ip: nil},
+		{
+			lease: "# This is private data. Do not parse.\nADDRESS=10.1.1.43\nSERVER_ADDRESS=10.1.1.1\nROUTER=10.1.1.1\n",
+			ip:    net.ParseIP("10.1.1.1"),
+		},
+		{
+			lease: `lease {
+  interface "eth0";
+  fixed-address 10.1.1.43;
+  option dhcp-server-identifier 10.1.1.1;
+}
+lease {
+  interface "eth0";
+  fixed-address 10.1.1.43;
+  option dhcp-server-identifier 10.1.1.254;
+}
+`,
+			ip: net.ParseIP("10.1.1.254"),
+		},
+		{lease: "SERVER_ADDRESS=bad\n", ip: nil},
+	} {
+		if ip := parseLease(tt.lease); !reflect.DeepEqual(tt.ip, ip) {
+			t.Fatalf("bad server address (%q): want %s, got %s", tt.lease, tt.ip, ip)
+		}
+	}
+}
+
+func TestDiscoverRouter(t *testing.T) {
+	dir, err := ioutil.TempDir(os.TempDir(), "coreos-cloudinit-")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	if _, err := discoverRouter([]string{path.Join(dir, "*")}); err != ErrNoRouter {
+		t.Fatalf("bad error: want %v, got %v", ErrNoRouter, err)
+	}
+
+	old := path.Join(dir, "dhclient-eth0.leases")
+	ioutil.WriteFile(old, []byte("lease {\n  option dhcp-server-identifier 10.1.1.1;\n}\n"), 0644)
+	past := time.Now().Add(-time.Hour)
+	os.Chtimes(old, past, past)
+	ioutil.WriteFile(path.Join(dir, "2"), []byte("SERVER_ADDRESS=10.2.2.1\n"), 0644)
+	ioutil.WriteFile(path.Join(dir, "3"), []byte("ADDRESS=10.3.3.3\n"), 0644)
+
+	ip, err := discoverRouter([]string{path.Join(dir, "*")})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if want := net.ParseIP("10.2.2.1"); !ip.Equal(want) {
+		t.Fatalf("bad router: want %s, got %s", want, ip)
+	}
+}
+
+func TestIsAvailable(t *testing.T) {
+	for _, tt := range []struct {
+		router    net.IP
+		resources map[string]string
+		expect    bool
+	}{
+		{router: nil, expect: false},
+		{router: net.ParseIP("10.1.1.1"), resources: map[string]string{}, expect: false},
+		{
+			router:    net.ParseIP("10.1.1.1"),
+			resources: map[string]string{"http://10.1.1.1/latest/": ""},
+			expect:    true,
+		},
+	} {
+		ms := &metadataService{
+			MetadataService: metadata.MetadataService{
+				ApiVersion: apiVersion,
+				Client:     &test.HttpClient{Resources: tt.resources},
+			},
+			discover: func() (net.IP, error) {
+				if tt.router == nil {
+					return nil, ErrNoRouter
+				}
+				return tt.router, nil
+			},
+		}
+		if a := ms.IsAvailable(); a != tt.expect {
+			t.Fatalf("bad availability (%s): want %t, got %t", tt.router, tt.expect, a)
+		}
+		if tt.expect && ms.passwordServer != "http://10.1.1.1:8080/" {
+			t.Fatalf("bad password server: want %q, got %q", "http://10.1.1.1:8080/", ms.passwordServer)
+		}
+	}
+}
+
+func TestFetchMetadata(t *testing.T) {
+	for _, tt := range []struct {
+		resources map[string]string
+		clientErr error
+		expect    datasource.Metadata
+		expectErr error
+	}{
+		{
+			resources: map[string]string{
+				"/latest/meta-data/instance-id":       "8d9b3a2c-5f47-4d5e-9a67-2ba3b4c5e1d0\n",
+				"/latest/meta-data/local-hostname":    "VM-8d9b3a2c",
+				"/latest/meta-data/availability-zone": "zone1",
+				"/latest/meta-data/local-ipv4":        "10.1.1.43",
+				"/latest/meta-data/public-ipv4":       "192.0.2.43",
+				"/latest/meta-data/public-keys":       "ssh-rsa AAAA key1\n\nssh-ed25519 AAAA key2\n",
+			},
+			expect: datasource.Metadata{
+				InstanceID:       "8d9b3a2c-5f47-4d5e-9a67-2ba3b4c5e1d0",
+				Hostname:         "VM-8d9b3a2c",
+				AvailabilityZone: "zone1",
+				PrivateIPv4:      net.ParseIP("10.1.1.43"),
+				PublicIPv4:       net.ParseIP("192.0.2.43"),
+				SSHPublicKeys: map[string]string{
+					"0": "ssh-rsa AAAA key1",
+					"1": "ssh-ed25519 AAAA key2",
+				},
+				Raw: []byte(`{"availability-zone":"zone1","instance-id":"8d9b3a2c-5f47-4d5e-9a67-2ba3b4c5e1d0","local-hostname":"VM-8d9b3a2c","local-ipv4":"10.1.1.43","public-ipv4":"192.0.2.43","public-keys":{"0":"ssh-rsa AAAA key1","1":"ssh-ed25519 AAAA key2"}}`),
+			},
+		},
+		{
+			resources: map[string]string{
+				"/latest/meta-data/local-hostname": "VM-1",
+			},
+			expect: datasource.Metadata{
+				Hostname:      "VM-1",
+				SSHPublicKeys: map[string]string{},
+				Raw:           []byte(`{"local-hostname":"VM-1","public-keys":{}}`),
+			},
+		},
+		{
+			clientErr: pkg.ErrTimeout{Err: fmt.Errorf("test error")},
+			expectErr: pkg.ErrTimeout{Err: fmt.Errorf("test error")},
+		},
+	} {
+		ms := &metadataService{
+			MetadataService: metadata.MetadataService{
+				Root:         "/",
+				MetadataPath: metadataPath,
+				Client:       &test.HttpClient{Resources: tt.resources, Err: tt.clientErr},
+			},
+		}
+		metadata, err := ms.FetchMetadata()
+		if Error(err) != Error(tt.expectErr) {
+			t.Fatalf("bad error (%q): want %v, got %v", tt.resources, tt.expectErr, err)
+		}
+		if tt.expectErr == nil && !reflect.DeepEqual(tt.expect, metadata) {
+			t.Fatalf("bad fetch (%q): want %#v, got %#v", tt.resources, tt.expect, metadata)
+		}
+	}
+}
+
+func TestFetchPassword(t *testing.T) {
+	for _, tt := range []struct {
+		response string
+		expect   string
+		acked    bool
+	}{
+		{response: "", expect: "", acked: false},
+		{response: "saved_password", expect: "", acked: false},
+		{response: "bad_request", expect: "", acked: false},
+		{response: "s3cr3t\n", expect: "s3cr3t", acked: true},
+	} {
+		var acked bool
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			switch r.Header.Get("DomU_Request") {
+			case "send_my_password":
+				w.Write([]byte(tt.response))
+			case "saved_password":
+				acked = true
+				w.Write([]byte("saved_password"))
+			default:
+				w.Write([]byte("bad_request"))
+			}
+		}))
+
+		ms := &metadataService{passwordServer: server.URL + "/", passwordClient: pkg.NewHttpClient()}
+		password, err := ms.FetchPassword()
+		server.Close()
+		if err != nil {
+			t.Fatalf("bad error (%q): want %v, got %v", tt.response, nil, err)
+		}
+		if password != tt.expect {
+			t.Fatalf("bad password (%q): want %q, got %q", tt.response, tt.expect, password)
+		}
+		if acked != tt.acked {
+			t.Fatalf("bad acknowledgement (%q): want %t, got %t", tt.response, tt.acked, acked)
+		}
+	}
+}
+
+func Error(err error) string {
+	if err != nil {
+		return err.Error()
+	}
+	return ""
+}
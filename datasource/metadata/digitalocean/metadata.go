@@ -28,6 +28,7 @@ const (
 	apiVersion     = "metadata/v1"
 	userdataUrl    = apiVersion + "/user-data"
 	metadataPath   = apiVersion + ".json"
+	vendordataPath = apiVersion + "/vendor-data"
 )
 
 type Address struct {
@@ -54,9 +55,21 @@ type DNS struct {
 	Nameservers []string `json:"nameservers"`
 }
 
+// FloatingIP describes the floating IP assigned to the droplet, which is
+// routed to the anchor address of its public interface.
+type FloatingIP struct {
+	IPv4 struct {
+		Active    bool   `json:"active"`
+		IPAddress string `json:"ip_address"`
+	} `json:"ipv4"`
+}
+
 type Metadata struct {
+	DropletID  int        `json:"droplet_id"`
 	Hostname   string     `json:"hostname"`
+	Region     string     `json:"region"`
 	Interfaces Interfaces `json:"interfaces"`
+	FloatingIP FloatingIP `json:"floating_ip"`
 	PublicKeys []string   `json:"public_keys"`
 	DNS        DNS        `json:"dns"`
 }
@@ -66,7 +79,9 @@ type metadataService struct {
 }
 
 func NewDatasource(root string) *metadataService {
-	return &metadataService{MetadataService: metadata.NewDatasource(root, apiVersion, userdataUrl, metadataPath)}
+	ms := metadata.NewDatasource(root, apiVersion, userdataUrl, metadataPath)
+	ms.VendordataPath = vendordataPath
+	return &metadataService{MetadataService: ms}
 }
 
 func (ms *metadataService) FetchMetadata() (metadata datasource.Metadata, err error) {
@@ -96,12 +111,20 @@ func (ms *metadataService) FetchMetadata() (metadata datasource.Metadata, err er
 			metadata.PrivateIPv6 = net.ParseIP(m.Interfaces.Private[0].IPv6.IPAddress)
 		}
 	}
+	if m.FloatingIP.IPv4.Active {
+		metadata.FloatingIPv4 = net.ParseIP(m.FloatingIP.IPv4.IPAddress)
+	}
+	if m.DropletID != 0 {
+		metadata.InstanceID = strconv.Itoa(m.DropletID)
+	}
+	metadata.Region = m.Region
 	metadata.Hostname = m.Hostname
 	metadata.SSHPublicKeys = map[string]string{}
 	for i, key := range m.PublicKeys {
 		metadata.SSHPublicKeys[strconv.Itoa(i)] = key
 	}
 	metadata.NetworkConfig = m
+	metadata.Raw = data
 
 	return
 }
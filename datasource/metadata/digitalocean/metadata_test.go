@@ -76,21 +76,37 @@ func TestFetchMetadata(t *testing.T) {
           "cidr": 126,
           "gateway": "fe00::"
         },
+        "anchor_ipv4": {
+          "ip_address": "10.17.0.5",
+          "netmask": "255.255.0.0",
+          "gateway": "10.17.0.1"
+        },
         "mac": "ab:cd:ef:gh:ij",
         "type": "public"
       }
     ]
+  },
+  "floating_ip": {
+    "ipv4": {
+      "active": true,
+      "ip_address": "203.0.113.7"
+    }
   }
 }`,
 			},
 			expect: datasource.Metadata{
-				PublicIPv4: net.ParseIP("192.168.1.2"),
-				PublicIPv6: net.ParseIP("fe00::"),
+				InstanceID:   "1",
+				Region:       "nyc2",
+				PublicIPv4:   net.ParseIP("192.168.1.2"),
+				PublicIPv6:   net.ParseIP("fe00::"),
+				FloatingIPv4: net.ParseIP("203.0.113.7"),
 				SSHPublicKeys: map[string]string{
 					"0": "publickey1",
 					"1": "publickey2",
 				},
 				NetworkConfig: Metadata{
+					DropletID: 1,
+					Region:    "nyc2",
 					Interfaces: Interfaces{
 						Public: []Interface{
 							Interface{
@@ -104,11 +120,17 @@ func TestFetchMetadata(t *testing.T) {
 									Cidr:      126,
 									Gateway:   "fe00::",
 								},
+								AnchorIPv4: &Address{
+									IPAddress: "10.17.0.5",
+									Netmask:   "255.255.0.0",
+									Gateway:   "10.17.0.1",
+								},
 								MAC:  "ab:cd:ef:gh:ij",
 								Type: "public",
 							},
 						},
 					},
+					FloatingIP: floatingIP("203.0.113.7"),
 					PublicKeys: []string{"publickey1", "publickey2"},
 				},
 			},
@@ -125,6 +147,9 @@ func TestFetchMetadata(t *testing.T) {
 				MetadataPath: tt.metadataPath,
 			},
 		}
+		if tt.expectErr == nil && tt.clientErr == nil {
+			tt.expect.Raw = []byte(tt.resources[tt.root+tt.metadataPath])
+		}
 		metadata, err := service.FetchMetadata()
 		if Error(err) != Error(tt.expectErr) {
 			t.Fatalf("bad error (%q): want %q, got %q", tt.resources, tt.expectErr, err)
@@ -135,6 +160,12 @@ func TestFetchMetadata(t *testing.T) {
 	}
 }
 
+func floatingIP(ip string) (f FloatingIP) {
+	f.IPv4.Active = true
+	f.IPv4.IPAddress = ip
+	return
+}
+
 func Error(err error) string {
 	if err != nil {
 		return err.Error()
@@ -17,6 +17,7 @@ package ec2
 import (
 	"bufio"
 	"bytes"
+	"encoding/json"
 	"fmt"
 	"log"
 	"net"
@@ -44,6 +45,9 @@ func NewDatasource(root string) *metadataService {
 
 func (ms metadataService) FetchMetadata() (datasource.Metadata, error) {
 	metadata := datasource.Metadata{}
+	// raw collects the attributes which are set by their path, as EC2 has
+	// no single metadata document.
+	raw := map[string]interface{}{}
 
 	if keynames, err := ms.fetchAttributes(fmt.Sprintf("%s/public-keys", ms.MetadataUrl())); err == nil {
 		keyIDs := make(map[string]string)
@@ -64,29 +68,55 @@ func (ms metadataService) FetchMetadata() (datasource.Metadata, error) {
 			metadata.SSHPublicKeys[name] = sshkey
 			log.Printf("Found SSH key for %q\n", name)
 		}
+		raw["public-keys"] = metadata.SSHPublicKeys
+	} else if _, ok := err.(pkg.ErrNotFound); !ok {
+		return metadata, err
+	}
+
+	if instanceID, err := ms.fetchAttribute(fmt.Sprintf("%s/instance-id", ms.MetadataUrl())); err == nil {
+		metadata.InstanceID = instanceID
+		raw["instance-id"] = instanceID
+	} else if _, ok := err.(pkg.ErrNotFound); !ok {
+		return metadata, err
+	}
+
+	if zone, err := ms.fetchAttribute(fmt.Sprintf("%s/placement/availability-zone", ms.MetadataUrl())); err == nil {
+		metadata.AvailabilityZone = zone
+		raw["placement/availability-zone"] = zone
+		metadata.Region = strings.TrimRight(zone, "abcdefghijklmnopqrstuvwxyz")
 	} else if _, ok := err.(pkg.ErrNotFound); !ok {
 		return metadata, err
 	}
 
 	if hostname, err := ms.fetchAttribute(fmt.Sprintf("%s/hostname", ms.MetadataUrl())); err == nil {
 		metadata.Hostname = strings.Split(hostname, " ")[0]
+		raw["hostname"] = hostname
 	} else if _, ok := err.(pkg.ErrNotFound); !ok {
 		return metadata, err
 	}
 
 	if localAddr, err := ms.fetchAttribute(fmt.Sprintf("%s/local-ipv4", ms.MetadataUrl())); err == nil {
 		metadata.PrivateIPv4 = net.ParseIP(localAddr)
+		raw["local-ipv4"] = localAddr
 	} else if _, ok := err.(pkg.ErrNotFound); !ok {
 		return metadata, err
 	}
 
 	if publicAddr, err := ms.fetchAttribute(fmt.Sprintf("%s/public-ipv4", ms.MetadataUrl())); err == nil {
 		metadata.PublicIPv4 = net.ParseIP(publicAddr)
+		raw["public-ipv4"] = publicAddr
 	} else if _, ok := err.(pkg.ErrNotFound); !ok {
 		return metadata, err
 	}
 
-	return metadata, nil
+	for name, value := range raw {
+		if value == "" {
+			delete(raw, name)
+		}
+	}
+	var err error
+	metadata.Raw, err = json.Marshal(raw)
+	return metadata, err
 }
 
 func (ms metadataService) Type() string {
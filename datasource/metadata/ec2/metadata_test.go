@@ -162,18 +162,24 @@ func TestFetchMetadata(t *testing.T) {
 			root:         "/",
 			metadataPath: "2009-04-04/meta-data",
 			resources: map[string]string{
-				"/2009-04-04/meta-data/hostname":                  "host",
-				"/2009-04-04/meta-data/local-ipv4":                "1.2.3.4",
-				"/2009-04-04/meta-data/public-ipv4":               "5.6.7.8",
-				"/2009-04-04/meta-data/public-keys":               "0=test1\n",
-				"/2009-04-04/meta-data/public-keys/0":             "openssh-key",
-				"/2009-04-04/meta-data/public-keys/0/openssh-key": "key",
+				"/2009-04-04/meta-data/hostname":                    "host",
+				"/2009-04-04/meta-data/instance-id":                 "i-12345678",
+				"/2009-04-04/meta-data/placement/availability-zone": "us-west-2a",
+				"/2009-04-04/meta-data/local-ipv4":                  "1.2.3.4",
+				"/2009-04-04/meta-data/public-ipv4":                 "5.6.7.8",
+				"/2009-04-04/meta-data/public-keys":                 "0=test1\n",
+				"/2009-04-04/meta-data/public-keys/0":               "openssh-key",
+				"/2009-04-04/meta-data/public-keys/0/openssh-key":   "key",
 			},
 			expect: datasource.Metadata{
-				Hostname:      "host",
-				PrivateIPv4:   net.ParseIP("1.2.3.4"),
-				PublicIPv4:    net.ParseIP("5.6.7.8"),
-				SSHPublicKeys: map[string]string{"test1": "key"},
+				InstanceID:       "i-12345678",
+				Region:           "us-west-2",
+				AvailabilityZone: "us-west-2a",
+				Hostname:         "host",
+				PrivateIPv4:      net.ParseIP("1.2.3.4"),
+				PublicIPv4:       net.ParseIP("5.6.7.8"),
+				SSHPublicKeys:    map[string]string{"test1": "key"},
+				Raw:              []byte(`{"hostname":"host","instance-id":"i-12345678","local-ipv4":"1.2.3.4","placement/availability-zone":"us-west-2a","public-ipv4":"5.6.7.8","public-keys":{"test1":"key"}}`),
 			},
 		},
 		{
@@ -192,6 +198,7 @@ func TestFetchMetadata(t *testing.T) {
 				PrivateIPv4:   net.ParseIP("1.2.3.4"),
 				PublicIPv4:    net.ParseIP("5.6.7.8"),
 				SSHPublicKeys: map[string]string{"test1": "key"},
+				Raw:           []byte(`{"hostname":"host domain another_domain","local-ipv4":"1.2.3.4","public-ipv4":"5.6.7.8","public-keys":{"test1":"key"}}`),
 			},
 		},
 		{
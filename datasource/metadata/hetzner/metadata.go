@@ -0,0 +1,111 @@
+// Copyright 2015 CoreOS, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package hetzner
+
+import (
+	"net"
+	"strconv"
+
+	"github.com/coreos/coreos-cloudinit/datasource"
+	"github.com/coreos/coreos-cloudinit/datasource/metadata"
+
+	"gopkg.in/yaml.v2"
+)
+
+const (
+	DefaultAddress = "http://169.254.169.254/"
+	apiVersion     = "hetzner/v1/"
+	userdataPath   = apiVersion + "userdata"
+	metadataPath   = apiVersion + "metadata"
+)
+
+type Subnet struct {
+	Type           string   `yaml:"type" json:"type"`
+	Address        string   `yaml:"address" json:"address,omitempty"`
+	Gateway        string   `yaml:"gateway" json:"gateway,omitempty"`
+	DNSNameservers []string `yaml:"dns_nameservers" json:"dns_nameservers,omitempty"`
+}
+
+type Interface struct {
+	Type       string   `yaml:"type" json:"type"`
+	Name       string   `yaml:"name" json:"name"`
+	MACAddress string   `yaml:"mac_address" json:"mac_address"`
+	Subnets    []Subnet `yaml:"subnets" json:"subnets"`
+}
+
+// NetworkConfig is the network configuration of the server, in the format
+// of version 1 of the cloud-init network config.
+type NetworkConfig struct {
+	Version int         `yaml:"version" json:"version"`
+	Config  []Interface `yaml:"config" json:"config"`
+}
+
+type Metadata struct {
+	InstanceID       int64         `yaml:"instance-id"`
+	Hostname         string        `yaml:"hostname"`
+	AvailabilityZone string        `yaml:"availability-zone"`
+	Region           string        `yaml:"region"`
+	PublicIPv4       string        `yaml:"public-ipv4"`
+	PublicKeys       []string      `yaml:"public-keys"`
+	NetworkConfig    NetworkConfig `yaml:"network-config"`
+}
+
+type metadataService struct {
+	metadata.MetadataService
+}
+
+func NewDatasource(root string) *metadataService {
+	ms := metadata.NewDatasource(root, metadataPath, userdataPath, metadataPath)
+	return &metadataService{MetadataService: ms}
+}
+
+func (ms *metadataService) FetchMetadata() (metadata datasource.Metadata, err error) {
+	var data []byte
+	var m Metadata
+
+	if data, err = ms.FetchData(ms.MetadataUrl()); err != nil || len(data) == 0 {
+		return
+	}
+	if err = yaml.Unmarshal(data, &m); err != nil {
+		return
+	}
+
+	if m.InstanceID != 0 {
+		metadata.InstanceID = strconv.FormatInt(m.InstanceID, 10)
+	}
+	metadata.Region = m.Region
+	metadata.AvailabilityZone = m.AvailabilityZone
+	metadata.Hostname = m.Hostname
+	metadata.PublicIPv4 = net.ParseIP(m.PublicIPv4)
+	for _, iface := range m.NetworkConfig.Config {
+		for _, subnet := range iface.Subnets {
+			if ip, _, err := net.ParseCIDR(subnet.Address); err == nil && ip.To4() == nil && metadata.PublicIPv6 == nil {
+				metadata.PublicIPv6 = ip
+			}
+		}
+	}
+	metadata.SSHPublicKeys = map[string]string{}
+	for i, key := range m.PublicKeys {
+		metadata.SSHPublicKeys[strconv.Itoa(i)] = key
+	}
+	metadata.NetworkConfig = m.NetworkConfig
+	metadata.Raw = data
+
+	return
+}
+
+func (ms metadataService) Type() string {
+	return "hetzner-metadata-service"
+}
@@ -0,0 +1,136 @@
+// Copyright 2015 CoreOS, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package hetzner
+
+import (
+	"fmt"
+	"net"
+	"reflect"
+	"testing"
+
+	"github.com/coreos/coreos-cloudinit/datasource"
+	"github.com/coreos/coreos-cloudinit/datasource/metadata"
+	"github.com/coreos/coreos-cloudinit/datasource/metadata/test"
+	"github.com/coreos/coreos-cloudinit/pkg"
+)
+
+func TestType(t *testing.T) {
+	want := "hetzner-metadata-service"
+	if kind := (metadataService{}).Type(); kind != want {
+		t.Fatalf("bad type: want %q, got %q", want, kind)
+	}
+}
+
+func TestFetchMetadata(t *testing.T) {
+	for _, tt := range []struct {
+		root         string
+		metadataPath string
+		resources    map[string]string
+		expect       datasource.Metadata
+		clientErr    error
+		expectErr    error
+	}{
+		{
+			root:         "/",
+			metadataPath: "hetzner/v1/metadata",
+			resources: map[string]string{
+				"/hetzner/v1/metadata": "bad: [",
+			},
+			expectErr: fmt.Errorf("yaml: line 1: did not find expected node content"),
+		},
+		{
+			root:         "/",
+			metadataPath: "hetzner/v1/metadata",
+			resources: map[string]string{
+				"/hetzner/v1/metadata": `availability-zone: fsn1-dc14
+hostname: my-server
+instance-id: 42
+local-ipv4: ''
+network-config:
+  config:
+  - mac_address: 96:00:00:00:00:01
+    name: eth0
+    subnets:
+    - dns_nameservers:
+      - 185.12.64.1
+      ipv4: true
+      type: dhcp
+    - address: 2a01:4f8:c17:1::1/64
+      gateway: fe80::1
+      ipv6: true
+      type: static
+    type: physical
+  version: 1
+public-ipv4: 1.2.3.4
+public-keys:
+- ssh-ed25519 AAAA key1
+region: eu-central
+`,
+			},
+			expect: datasource.Metadata{
+				InstanceID:       "42",
+				Region:           "eu-central",
+				AvailabilityZone: "fsn1-dc14",
+				Hostname:         "my-server",
+				PublicIPv4:       net.ParseIP("1.2.3.4"),
+				PublicIPv6:       net.ParseIP("2a01:4f8:c17:1::1"),
+				SSHPublicKeys:    map[string]string{"0": "ssh-ed25519 AAAA key1"},
+				NetworkConfig: NetworkConfig{
+					Version: 1,
+					Config: []Interface{
+						{
+							Type:       "physical",
+							Name:       "eth0",
+							MACAddress: "96:00:00:00:00:01",
+							Subnets: []Subnet{
+								{Type: "dhcp", DNSNameservers: []string{"185.12.64.1"}},
+								{Type: "static", Address: "2a01:4f8:c17:1::1/64", Gateway: "fe80::1"},
+							},
+						},
+					},
+				},
+			},
+		},
+		{
+			clientErr: pkg.ErrTimeout{Err: fmt.Errorf("test error")},
+			expectErr: pkg.ErrTimeout{Err: fmt.Errorf("test error")},
+		},
+	} {
+		service := &metadataService{
+			MetadataService: metadata.MetadataService{
+				Root:         tt.root,
+				Client:       &test.HttpClient{Resources: tt.resources, Err: tt.clientErr},
+				MetadataPath: tt.metadataPath,
+			},
+		}
+		if tt.expectErr == nil && tt.clientErr == nil {
+			tt.expect.Raw = []byte(tt.resources[tt.root+tt.metadataPath])
+		}
+		metadata, err := service.FetchMetadata()
+		if Error(err) != Error(tt.expectErr) {
+			t.Fatalf("bad error (%q): want %q, got %q", tt.resources, tt.expectErr, err)
+		}
+		if !reflect.DeepEqual(tt.expect, metadata) {
+			t.Fatalf("bad fetch (%q): want %#v, got %#v", tt.resources, tt.expect, metadata)
+		}
+	}
+}
+
+func Error(err error) string {
+	if err != nil {
+		return err.Error()
+	}
+	return ""
+}
@@ -21,18 +21,19 @@ import (
 )
 
 type MetadataService struct {
-	Root         string
-	Client       pkg.Getter
-	ApiVersion   string
-	UserdataPath string
-	MetadataPath string
+	Root           string
+	Client         pkg.Getter
+	ApiVersion     string
+	UserdataPath   string
+	MetadataPath   string
+	VendordataPath string
 }
 
 func NewDatasource(root, apiVersion, userdataPath, metadataPath string) MetadataService {
 	if !strings.HasSuffix(root, "/") {
 		root += "/"
 	}
-	return MetadataService{root, pkg.NewHttpClient(), apiVersion, userdataPath, metadataPath}
+	return MetadataService{root, pkg.NewHttpClient(), apiVersion, userdataPath, metadataPath, ""}
 }
 
 func (ms MetadataService) IsAvailable() bool {
@@ -52,6 +53,15 @@ func (ms MetadataService) FetchUserdata() ([]byte, error) {
 	return ms.FetchData(ms.UserdataUrl())
 }
 
+// FetchVendordata returns the vendor-data published by the service, or an
+// empty slice if the service does not publish any.
+func (ms MetadataService) FetchVendordata() ([]byte, error) {
+	if ms.VendordataPath == "" {
+		return []byte{}, nil
+	}
+	return ms.FetchData(ms.VendordataUrl())
+}
+
 func (ms MetadataService) FetchData(url string) ([]byte, error) {
 	if data, err := ms.Client.GetRetry(url); err == nil {
 		return data, err
@@ -69,3 +79,7 @@ func (ms MetadataService) MetadataUrl() string {
 func (ms MetadataService) UserdataUrl() string {
 	return (ms.Root + ms.UserdataPath)
 }
+
+func (ms MetadataService) VendordataUrl() string {
+	return (ms.Root + ms.VendordataPath)
+}
@@ -105,6 +105,42 @@ func TestFetchUserdata(t *testing.T) {
 	}
 }
 
+func TestFetchVendordata(t *testing.T) {
+	for _, tt := range []struct {
+		root           string
+		vendordataPath string
+		resources      map[string]string
+		vendordata     []byte
+	}{
+		{
+			root:       "/",
+			resources:  map[string]string{"/": "root"},
+			vendordata: []byte{},
+		},
+		{
+			root:           "/",
+			vendordataPath: "metadata/v1/vendor-data",
+			resources: map[string]string{
+				"/metadata/v1/vendor-data": "vendor",
+			},
+			vendordata: []byte("vendor"),
+		},
+	} {
+		service := &MetadataService{
+			Root:           tt.root,
+			Client:         &test.HttpClient{Resources: tt.resources, Err: nil},
+			VendordataPath: tt.vendordataPath,
+		}
+		data, err := service.FetchVendordata()
+		if err != nil {
+			t.Fatalf("bad error (%q): want %v, got %q", tt.resources, nil, err)
+		}
+		if !bytes.Equal(data, tt.vendordata) {
+			t.Fatalf("bad vendordata (%q): want %q, got %q", tt.resources, tt.vendordata, data)
+		}
+	}
+}
+
 func TestUrls(t *testing.T) {
 	for _, tt := range []struct {
 		root         string
@@ -17,8 +17,10 @@
 package openstack
 
 import (
+	"bytes"
 	"encoding/json"
 	"net"
+	"sort"
 	"strconv"
 
 	"github.com/coreos/coreos-cloudinit/datasource"
@@ -26,10 +28,12 @@ import (
 )
 
 const (
-	DefaultAddress = "http://169.254.169.254/"
-	apiVersion     = "openstack/latest"
-	userdataUrl    = apiVersion + "/user_data"
-	metadataPath   = apiVersion + "/meta_data.json"
+	DefaultAddress  = "http://169.254.169.254/"
+	apiVersion      = "openstack/latest"
+	userdataUrl     = apiVersion + "/user_data"
+	metadataPath    = apiVersion + "/meta_data.json"
+	vendordataPath  = apiVersion + "/vendor_data.json"
+	vendordata2Path = apiVersion + "/vendor_data2.json"
 )
 
 type Address struct {
@@ -56,6 +60,7 @@ type DNS struct {
 }
 
 type Metadata struct {
+	UUID       string            `json:"uuid"`
 	Hostname   string            `json:"hostname"`
 	Interfaces Interfaces        `json:"interfaces"`
 	PublicKeys map[string]string `json:"public_keys"`
@@ -67,7 +72,9 @@ type metadataService struct {
 }
 
 func NewDatasource(root string) *metadataService {
-	return &metadataService{MetadataService: metadata.NewDatasource(root, apiVersion, userdataUrl, metadataPath)}
+	ms := metadata.NewDatasource(root, apiVersion, userdataUrl, metadataPath)
+	ms.VendordataPath = vendordataPath
+	return &metadataService{MetadataService: ms}
 }
 
 func (ms *metadataService) FetchMetadata() (metadata datasource.Metadata, err error) {
@@ -99,14 +106,110 @@ func (ms *metadataService) FetchMetadata() (metadata datasource.Metadata, err er
 		}
 	}
 
+	metadata.InstanceID = m.UUID
 	metadata.Hostname = m.Hostname
 	metadata.SSHPublicKeys = map[string]string{}
 	metadata.SSHPublicKeys[strconv.Itoa(0)] = m.PublicKeys["root"]
 	metadata.NetworkConfig = data
+	metadata.Raw = data
 
 	return
 }
 
+// FetchVendordata returns the cloud-init payload of vendor_data.json, falling
+// back to vendor_data2.json.
+func (ms *metadataService) FetchVendordata() ([]byte, error) {
+	return ReadVendordata(func(dynamic bool) ([]byte, error) {
+		if dynamic {
+			return ms.FetchData(ms.Root + vendordata2Path)
+		}
+		return ms.FetchData(ms.VendordataUrl())
+	})
+}
+
 func (ms metadataService) Type() string {
 	return "openstack-metadata-service"
 }
+
+// ReadVendordata returns the cloud-init payload of vendor_data.json, read by
+// read with dynamic set to false. If that file cannot be read or parsed, or
+// carries no payload, the payload of vendor_data2.json, read with dynamic set
+// to true, is returned instead. The error of vendor_data.json is returned if
+// neither file yields a payload.
+func ReadVendordata(read func(dynamic bool) ([]byte, error)) ([]byte, error) {
+	data, err := read(false)
+	if err == nil {
+		if data, err = ParseVendordata(data); err == nil && len(data) > 0 {
+			return data, nil
+		}
+	}
+
+	data2, err2 := read(true)
+	if err2 == nil {
+		data2, err2 = ParseDynamicVendordata(data2)
+	}
+	switch {
+	case err2 == nil && len(data2) > 0:
+		return data2, nil
+	case err != nil:
+		return nil, err
+	case err2 != nil:
+		return nil, err2
+	}
+	return data2, nil
+}
+
+// ParseVendordata extracts the cloud-init payload from the contents of an
+// OpenStack vendor_data.json. The document is either a JSON string holding
+// the payload or an object holding it under the "cloud-init" key.
+func ParseVendordata(data []byte) ([]byte, error) {
+	if len(bytes.TrimSpace(data)) == 0 {
+		return []byte{}, nil
+	}
+
+	var vd interface{}
+	if err := json.Unmarshal(data, &vd); err != nil {
+		return nil, err
+	}
+	return extractVendordata(vd), nil
+}
+
+// ParseDynamicVendordata extracts the cloud-init payload from the contents of
+// an OpenStack vendor_data2.json, which maps the name of each dynamic
+// vendor-data service to its response. The first service (in name order)
+// providing a payload wins.
+func ParseDynamicVendordata(data []byte) ([]byte, error) {
+	if len(bytes.TrimSpace(data)) == 0 {
+		return []byte{}, nil
+	}
+
+	var services map[string]interface{}
+	if err := json.Unmarshal(data, &services); err != nil {
+		return nil, err
+	}
+
+	names := make([]string, 0, len(services))
+	for name := range services {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		if vd := extractVendordata(services[name]); len(vd) > 0 {
+			return vd, nil
+		}
+	}
+	return []byte{}, nil
+}
+
+func extractVendordata(vd interface{}) []byte {
+	switch v := vd.(type) {
+	case string:
+		return []byte(v)
+	case map[string]interface{}:
+		if ci, ok := v["cloud-init"]; ok {
+			return extractVendordata(ci)
+		}
+	}
+	return []byte{}
+}
@@ -16,11 +16,14 @@ package packet
 
 import (
 	"encoding/json"
+	"log"
 	"net"
+	"net/http"
 	"strconv"
 
 	"github.com/coreos/coreos-cloudinit/datasource"
 	"github.com/coreos/coreos-cloudinit/datasource/metadata"
+	"github.com/coreos/coreos-cloudinit/pkg"
 )
 
 const (
@@ -37,32 +40,63 @@ type Netblock struct {
 	Gateway       net.IP `json:"gateway"`
 	AddressFamily int    `json:"address_family"`
 	Public        bool   `json:"public"`
+	Management    bool   `json:"management"`
 }
 
 type Nic struct {
 	Name string `json:"name"`
 	Mac  string `json:"mac"`
+	Bond string `json:"bond"`
+}
+
+// Bonding describes how the physical NICs are aggregated. Mode is the Linux
+// bonding mode number.
+type Bonding struct {
+	Mode            int    `json:"mode"`
+	LinkAggregation string `json:"link_aggregation"`
+	Mac             string `json:"mac"`
+}
+
+// VLAN is a layer 2 VLAN carried over the bond, with the netblocks assigned
+// to the machine on it.
+type VLAN struct {
+	ID        int        `json:"vlan"`
+	Netblocks []Netblock `json:"addresses"`
 }
 
 type NetworkData struct {
+	Bonding    *Bonding   `json:"bonding"`
 	Interfaces []Nic      `json:"interfaces"`
 	Netblocks  []Netblock `json:"addresses"`
+	VLANs      []VLAN     `json:"vlans"`
 	DNS        []net.IP   `json:"dns"`
 }
 
 // Metadata that will be pulled from the https://metadata.packet.net/metadata only. We have the opportunity to add more later.
 type Metadata struct {
-	Hostname    string      `json:"hostname"`
-	SSHKeys     []string    `json:"ssh_keys"`
-	NetworkData NetworkData `json:"network"`
+	ID           string      `json:"id"`
+	Hostname     string      `json:"hostname"`
+	Facility     string      `json:"facility"`
+	SSHKeys      []string    `json:"ssh_keys"`
+	NetworkData  NetworkData `json:"network"`
+	PhoneHomeURL string      `json:"phone_home_url"`
+}
+
+type doer interface {
+	Do(*http.Request) ([]byte, error)
 }
 
 type metadataService struct {
 	metadata.MetadataService
+	phoneHomeURL string
+	client       doer
 }
 
 func NewDatasource(root string) *metadataService {
-	return &metadataService{MetadataService: metadata.NewDatasource(root, apiVersion, userdataUrl, metadataPath)}
+	return &metadataService{
+		MetadataService: metadata.NewDatasource(root, apiVersion, userdataUrl, metadataPath),
+		client:          pkg.NewHttpClient(),
+	}
 }
 
 func (ms *metadataService) FetchMetadata() (metadata datasource.Metadata, err error) {
@@ -90,6 +124,8 @@ func (ms *metadataService) FetchMetadata() (metadata datasource.Metadata, err er
 			}
 		}
 	}
+	metadata.InstanceID = m.ID
+	metadata.Region = m.Facility
 	metadata.Hostname = m.Hostname
 	metadata.SSHPublicKeys = map[string]string{}
 	for i, key := range m.SSHKeys {
@@ -97,10 +133,27 @@ func (ms *metadataService) FetchMetadata() (metadata datasource.Metadata, err er
 	}
 
 	metadata.NetworkConfig = m.NetworkData
+	metadata.Raw = data
+	ms.phoneHomeURL = m.PhoneHomeURL
 
 	return
 }
 
+// ReportReady phones home to Packet, which marks the provisioning of the
+// machine as complete.
+func (ms *metadataService) ReportReady() error {
+	if ms.phoneHomeURL == "" {
+		return nil
+	}
+	req, err := http.NewRequest("POST", ms.phoneHomeURL, nil)
+	if err != nil {
+		return err
+	}
+	log.Printf("Phoning home to %s\n", ms.phoneHomeURL)
+	_, err = ms.client.Do(req)
+	return err
+}
+
 func (ms metadataService) Type() string {
 	return "packet-metadata-service"
 }
@@ -0,0 +1,86 @@
+// Copyright 2015 CoreOS, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package packet
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+
+	"github.com/coreos/coreos-cloudinit/datasource/metadata"
+	"github.com/coreos/coreos-cloudinit/datasource/metadata/test"
+	"github.com/coreos/coreos-cloudinit/pkg"
+)
+
+func TestFetchMetadata(t *testing.T) {
+	ms := &metadataService{
+		MetadataService: metadata.MetadataService{
+			Root:         "/",
+			MetadataPath: "metadata",
+			Client: &test.HttpClient{Resources: map[string]string{
+				"/metadata": `{
+  "id": "6b8c5b1a",
+  "hostname": "packet-host",
+  "facility": "ewr1",
+  "phone_home_url": "http://tinkerbell.ewr1.packet.net/phone-home",
+  "network": {
+    "bonding": {"mode": 4, "link_aggregation": "bonded", "mac": "0c:c4:7a:e5:48:8a"},
+    "interfaces": [{"name": "enp1s0f0", "mac": "0c:c4:7a:e5:48:8a", "bond": "bond0"}],
+    "vlans": [{"vlan": 1000, "addresses": []}]
+  }
+}`,
+			}},
+		},
+	}
+
+	metadata, err := ms.FetchMetadata()
+	if err != nil {
+		t.Fatalf("bad error: want %v, got %v", nil, err)
+	}
+	expect := NetworkData{
+		Bonding:    &Bonding{Mode: 4, LinkAggregation: "bonded", Mac: "0c:c4:7a:e5:48:8a"},
+		Interfaces: []Nic{{Name: "enp1s0f0", Mac: "0c:c4:7a:e5:48:8a", Bond: "bond0"}},
+		VLANs:      []VLAN{{ID: 1000, Netblocks: []Netblock{}}},
+	}
+	if !reflect.DeepEqual(expect, metadata.NetworkConfig) {
+		t.Fatalf("bad network config: want %#v, got %#v", expect, metadata.NetworkConfig)
+	}
+	if ms.phoneHomeURL != "http://tinkerbell.ewr1.packet.net/phone-home" {
+		t.Fatalf("bad phone home url: got %q", ms.phoneHomeURL)
+	}
+}
+
+func TestReportReady(t *testing.T) {
+	var phoned bool
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != "POST" || r.URL.Path != "/phone-home" {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		phoned = true
+	}))
+	defer server.Close()
+
+	ms := &metadataService{client: pkg.NewHttpClient()}
+	if err := ms.ReportReady(); err != nil || phoned {
+		t.Fatalf("bad phone home without url: err %v, phoned %t", err, phoned)
+	}
+
+	ms.phoneHomeURL = server.URL + "/phone-home"
+	if err := ms.ReportReady(); err != nil || !phoned {
+		t.Fatalf("bad phone home: err %v, phoned %t", err, phoned)
+	}
+}
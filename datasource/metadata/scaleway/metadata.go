@@ -0,0 +1,145 @@
+// Copyright 2015 CoreOS, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package scaleway
+
+import (
+	"encoding/json"
+	"fmt"
+	"net"
+	"os"
+	"strconv"
+	"syscall"
+
+	"github.com/coreos/coreos-cloudinit/datasource"
+	"github.com/coreos/coreos-cloudinit/datasource/metadata"
+	"github.com/coreos/coreos-cloudinit/pkg"
+)
+
+const (
+	DefaultAddress = "http://169.254.42.42/"
+	apiVersion     = "conf"
+	userdataPath   = "user_data/cloud-init"
+	metadataPath   = apiVersion + "?format=json"
+
+	// maxPrivilegedPort is the highest source port accepted by the metadata
+	// service for requests of the user-data.
+	maxPrivilegedPort = 1023
+)
+
+type PublicIP struct {
+	Address string `json:"address"`
+	Dynamic bool   `json:"dynamic"`
+}
+
+type IPv6 struct {
+	Address string `json:"address"`
+	Gateway string `json:"gateway"`
+	Netmask string `json:"netmask"`
+}
+
+type SSHPublicKey struct {
+	Key         string `json:"key"`
+	Fingerprint string `json:"fingerprint"`
+}
+
+type Metadata struct {
+	ID            string         `json:"id"`
+	Hostname      string         `json:"hostname"`
+	MACAddress    string         `json:"mac_address"`
+	Zone          string         `json:"zone"`
+	PrivateIP     string         `json:"private_ip"`
+	PublicIP      *PublicIP      `json:"public_ip"`
+	IPv6          *IPv6          `json:"ipv6"`
+	SSHPublicKeys []SSHPublicKey `json:"ssh_public_keys"`
+}
+
+type metadataService struct {
+	metadata.MetadataService
+}
+
+// NewDatasource returns a datasource for the Scaleway metadata service. The
+// service only serves the user-data to requests made from a privileged port,
+// so its requests are made from the first free port below 1024.
+func NewDatasource(root string) *metadataService {
+	ms := metadata.NewDatasource(root, apiVersion, userdataPath, metadataPath)
+	ms.Client = pkg.NewHttpClientWithDial(dialPrivileged)
+	return &metadataService{MetadataService: ms}
+}
+
+func (ms *metadataService) FetchMetadata() (metadata datasource.Metadata, err error) {
+	var data []byte
+	var m Metadata
+
+	if data, err = ms.FetchData(ms.MetadataUrl()); err != nil || len(data) == 0 {
+		return
+	}
+	if err = json.Unmarshal(data, &m); err != nil {
+		return
+	}
+
+	metadata.InstanceID = m.ID
+	metadata.AvailabilityZone = m.Zone
+	if len(m.Zone) > 2 {
+		metadata.Region = m.Zone[:len(m.Zone)-2]
+	}
+	metadata.Hostname = m.Hostname
+	metadata.PrivateIPv4 = net.ParseIP(m.PrivateIP)
+	if m.PublicIP != nil {
+		metadata.PublicIPv4 = net.ParseIP(m.PublicIP.Address)
+	}
+	if m.IPv6 != nil {
+		metadata.PublicIPv6 = net.ParseIP(m.IPv6.Address)
+	}
+	metadata.SSHPublicKeys = map[string]string{}
+	for i, key := range m.SSHPublicKeys {
+		metadata.SSHPublicKeys[strconv.Itoa(i)] = key.Key
+	}
+	metadata.NetworkConfig = m
+	metadata.Raw = data
+
+	return
+}
+
+func (ms metadataService) Type() string {
+	return "scaleway-metadata-service"
+}
+
+// dialPrivileged connects to addr from the highest free privileged port.
+func dialPrivileged(network, addr string) (net.Conn, error) {
+	raddr, err := net.ResolveTCPAddr(network, addr)
+	if err != nil {
+		return nil, err
+	}
+	for port := maxPrivilegedPort; port > 0; port-- {
+		conn, err := net.DialTCP(network, &net.TCPAddr{Port: port}, raddr)
+		if err == nil {
+			return conn, nil
+		}
+		if !isAddrInUse(err) {
+			return nil, err
+		}
+	}
+	return nil, fmt.Errorf("no free privileged port to connect to %s", addr)
+}
+
+func isAddrInUse(err error) bool {
+	if opErr, ok := err.(*net.OpError); ok {
+		err = opErr.Err
+	}
+	if sysErr, ok := err.(*os.SyscallError); ok {
+		err = sysErr.Err
+	}
+	return err == syscall.EADDRINUSE
+}
@@ -0,0 +1,137 @@
+// Copyright 2015 CoreOS, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package scaleway
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"reflect"
+	"syscall"
+	"testing"
+
+	"github.com/coreos/coreos-cloudinit/datasource"
+	"github.com/coreos/coreos-cloudinit/datasource/metadata"
+	"github.com/coreos/coreos-cloudinit/datasource/metadata/test"
+	"github.com/coreos/coreos-cloudinit/pkg"
+)
+
+func TestType(t *testing.T) {
+	want := "scaleway-metadata-service"
+	if kind := (metadataService{}).Type(); kind != want {
+		t.Fatalf("bad type: want %q, got %q", want, kind)
+	}
+}
+
+func TestFetchMetadata(t *testing.T) {
+	for _, tt := range []struct {
+		root         string
+		metadataPath string
+		resources    map[string]string
+		expect       datasource.Metadata
+		clientErr    error
+		expectErr    error
+	}{
+		{
+			root:         "/",
+			metadataPath: "conf?format=json",
+			resources: map[string]string{
+				"/conf?format=json": "bad",
+			},
+			expectErr: fmt.Errorf("invalid character 'b' looking for beginning of value"),
+		},
+		{
+			root:         "/",
+			metadataPath: "conf?format=json",
+			resources: map[string]string{
+				"/conf?format=json": `{
+  "id": "5f4e4a21-0fb4-4a3a-a5ed-d3f33f4ad6a4",
+  "name": "scw-test",
+  "hostname": "scw-test",
+  "commercial_type": "DEV1-S",
+  "zone": "fr-par-1",
+  "private_ip": "10.68.34.5",
+  "public_ip": {"address": "51.15.1.2", "dynamic": false, "id": "ip"},
+  "ipv6": {"address": "2001:bc8:47c0:1c1c::1", "gateway": "2001:bc8:47c0:1c1c::", "netmask": "64"},
+  "ssh_public_keys": [{"key": "ssh-rsa AAAA key1", "fingerprint": "2048 MD5:..."}]
+}`,
+			},
+			expect: datasource.Metadata{
+				InstanceID:       "5f4e4a21-0fb4-4a3a-a5ed-d3f33f4ad6a4",
+				Region:           "fr-par",
+				AvailabilityZone: "fr-par-1",
+				Hostname:         "scw-test",
+				PrivateIPv4:      net.ParseIP("10.68.34.5"),
+				PublicIPv4:       net.ParseIP("51.15.1.2"),
+				PublicIPv6:       net.ParseIP("2001:bc8:47c0:1c1c::1"),
+				SSHPublicKeys:    map[string]string{"0": "ssh-rsa AAAA key1"},
+				NetworkConfig: Metadata{
+					ID:            "5f4e4a21-0fb4-4a3a-a5ed-d3f33f4ad6a4",
+					Hostname:      "scw-test",
+					Zone:          "fr-par-1",
+					PrivateIP:     "10.68.34.5",
+					PublicIP:      &PublicIP{Address: "51.15.1.2"},
+					IPv6:          &IPv6{Address: "2001:bc8:47c0:1c1c::1", Gateway: "2001:bc8:47c0:1c1c::", Netmask: "64"},
+					SSHPublicKeys: []SSHPublicKey{{Key: "ssh-rsa AAAA key1", Fingerprint: "2048 MD5:..."}},
+				},
+			},
+		},
+		{
+			clientErr: pkg.ErrTimeout{Err: fmt.Errorf("test error")},
+			expectErr: pkg.ErrTimeout{Err: fmt.Errorf("test error")},
+		},
+	} {
+		service := &metadataService{
+			MetadataService: metadata.MetadataService{
+				Root:         tt.root,
+				Client:       &test.HttpClient{Resources: tt.resources, Err: tt.clientErr},
+				MetadataPath: tt.metadataPath,
+			},
+		}
+		if tt.expectErr == nil && tt.clientErr == nil {
+			tt.expect.Raw = []byte(tt.resources[tt.root+tt.metadataPath])
+		}
+		metadata, err := service.FetchMetadata()
+		if Error(err) != Error(tt.expectErr) {
+			t.Fatalf("bad error (%q): want %q, got %q", tt.resources, tt.expectErr, err)
+		}
+		if !reflect.DeepEqual(tt.expect, metadata) {
+			t.Fatalf("bad fetch (%q): want %#v, got %#v", tt.resources, tt.expect, metadata)
+		}
+	}
+}
+
+func TestIsAddrInUse(t *testing.T) {
+	for _, tt := range []struct {
+		err   error
+		inUse bool
+	}{
+		{&net.OpError{Op: "dial", Err: os.NewSyscallError("bind", syscall.EADDRINUSE)}, true},
+		{&net.OpError{Op: "dial", Err: os.NewSyscallError("bind", syscall.EACCES)}, false},
+		{errors.New("test error"), false},
+	} {
+		if inUse := isAddrInUse(tt.err); inUse != tt.inUse {
+			t.Errorf("bad result (%v): want %t, got %t", tt.err, tt.inUse, inUse)
+		}
+	}
+}
+
+func Error(err error) string {
+	if err != nil {
+		return err.Error()
+	}
+	return ""
+}
@@ -0,0 +1,114 @@
+// Copyright 2015 CoreOS, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package vultr
+
+import (
+	"encoding/json"
+	"net"
+	"strconv"
+
+	"github.com/coreos/coreos-cloudinit/datasource"
+	"github.com/coreos/coreos-cloudinit/datasource/metadata"
+)
+
+const (
+	DefaultAddress = "http://169.254.169.254/"
+	apiVersion     = "v1"
+	userdataPath   = "latest/user-data"
+	metadataPath   = apiVersion + ".json"
+)
+
+type IPv4 struct {
+	Address string `json:"address"`
+	Netmask string `json:"netmask"`
+	Gateway string `json:"gateway"`
+}
+
+type IPv6 struct {
+	Address string `json:"address"`
+	Network string `json:"network"`
+	Prefix  string `json:"prefix"`
+}
+
+type Interface struct {
+	IPv4        *IPv4  `json:"ipv4"`
+	IPv6        *IPv6  `json:"ipv6"`
+	MAC         string `json:"mac"`
+	NetworkType string `json:"network-type"`
+}
+
+type Region struct {
+	RegionCode string `json:"regioncode"`
+}
+
+type Metadata struct {
+	InstanceID string      `json:"instanceid"`
+	Hostname   string      `json:"hostname"`
+	Region     Region      `json:"region"`
+	PublicKeys []string    `json:"public-keys"`
+	Interfaces []Interface `json:"interfaces"`
+}
+
+type metadataService struct {
+	metadata.MetadataService
+}
+
+func NewDatasource(root string) *metadataService {
+	ms := metadata.NewDatasource(root, metadataPath, userdataPath, metadataPath)
+	return &metadataService{MetadataService: ms}
+}
+
+func (ms *metadataService) FetchMetadata() (metadata datasource.Metadata, err error) {
+	var data []byte
+	var m Metadata
+
+	if data, err = ms.FetchData(ms.MetadataUrl()); err != nil || len(data) == 0 {
+		return
+	}
+	if err = json.Unmarshal(data, &m); err != nil {
+		return
+	}
+
+	for _, iface := range m.Interfaces {
+		switch iface.NetworkType {
+		case "public":
+			if iface.IPv4 != nil && metadata.PublicIPv4 == nil {
+				metadata.PublicIPv4 = net.ParseIP(iface.IPv4.Address)
+			}
+			if iface.IPv6 != nil && metadata.PublicIPv6 == nil {
+				metadata.PublicIPv6 = net.ParseIP(iface.IPv6.Address)
+			}
+		case "private":
+			if iface.IPv4 != nil && metadata.PrivateIPv4 == nil {
+				metadata.PrivateIPv4 = net.ParseIP(iface.IPv4.Address)
+			}
+		}
+	}
+	metadata.InstanceID = m.InstanceID
+	metadata.Region = m.Region.RegionCode
+	metadata.Hostname = m.Hostname
+	metadata.SSHPublicKeys = map[string]string{}
+	for i, key := range m.PublicKeys {
+		metadata.SSHPublicKeys[strconv.Itoa(i)] = key
+	}
+	metadata.NetworkConfig = m
+	metadata.Raw = data
+
+	return
+}
+
+func (ms metadataService) Type() string {
+	return "vultr-metadata-service"
+}
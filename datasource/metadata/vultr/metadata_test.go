@@ -0,0 +1,136 @@
+// Copyright 2015 CoreOS, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package vultr
+
+import (
+	"fmt"
+	"net"
+	"reflect"
+	"testing"
+
+	"github.com/coreos/coreos-cloudinit/datasource"
+	"github.com/coreos/coreos-cloudinit/datasource/metadata"
+	"github.com/coreos/coreos-cloudinit/datasource/metadata/test"
+	"github.com/coreos/coreos-cloudinit/pkg"
+)
+
+func TestType(t *testing.T) {
+	want := "vultr-metadata-service"
+	if kind := (metadataService{}).Type(); kind != want {
+		t.Fatalf("bad type: want %q, got %q", want, kind)
+	}
+}
+
+func TestFetchMetadata(t *testing.T) {
+	for _, tt := range []struct {
+		root         string
+		metadataPath string
+		resources    map[string]string
+		expect       datasource.Metadata
+		clientErr    error
+		expectErr    error
+	}{
+		{
+			root:         "/",
+			metadataPath: "v1.json",
+			resources: map[string]string{
+				"/v1.json": "bad",
+			},
+			expectErr: fmt.Errorf("invalid character 'b' looking for beginning of value"),
+		},
+		{
+			root:         "/",
+			metadataPath: "v1.json",
+			resources: map[string]string{
+				"/v1.json": `{
+  "hostname": "vultr-guest",
+  "instanceid": "a747bfz6385e",
+  "interfaces": [
+    {
+      "ipv4": {"additional": [], "address": "45.76.7.171", "gateway": "45.76.6.1", "netmask": "255.255.254.0"},
+      "ipv6": {"additional": [], "address": "2001:19f0:5:28a7::", "network": "2001:19f0:5:28a7::", "prefix": "64"},
+      "mac": "56:00:03:1b:4e:ca",
+      "network-type": "public"
+    },
+    {
+      "ipv4": {"additional": [], "address": "10.1.112.3", "gateway": "", "netmask": "255.255.240.0"},
+      "mac": "5a:00:03:1b:4e:ca",
+      "network-type": "private"
+    }
+  ],
+  "public-keys": ["ssh-rsa AAAA key1"],
+  "region": {"regioncode": "EWR"}
+}`,
+			},
+			expect: datasource.Metadata{
+				InstanceID:    "a747bfz6385e",
+				Region:        "EWR",
+				Hostname:      "vultr-guest",
+				PublicIPv4:    net.ParseIP("45.76.7.171"),
+				PublicIPv6:    net.ParseIP("2001:19f0:5:28a7::"),
+				PrivateIPv4:   net.ParseIP("10.1.112.3"),
+				SSHPublicKeys: map[string]string{"0": "ssh-rsa AAAA key1"},
+				NetworkConfig: Metadata{
+					InstanceID: "a747bfz6385e",
+					Hostname:   "vultr-guest",
+					Region:     Region{RegionCode: "EWR"},
+					PublicKeys: []string{"ssh-rsa AAAA key1"},
+					Interfaces: []Interface{
+						{
+							IPv4:        &IPv4{Address: "45.76.7.171", Gateway: "45.76.6.1", Netmask: "255.255.254.0"},
+							IPv6:        &IPv6{Address: "2001:19f0:5:28a7::", Network: "2001:19f0:5:28a7::", Prefix: "64"},
+							MAC:         "56:00:03:1b:4e:ca",
+							NetworkType: "public",
+						},
+						{
+							IPv4:        &IPv4{Address: "10.1.112.3", Netmask: "255.255.240.0"},
+							MAC:         "5a:00:03:1b:4e:ca",
+							NetworkType: "private",
+						},
+					},
+				},
+			},
+		},
+		{
+			clientErr: pkg.ErrTimeout{Err: fmt.Errorf("test error")},
+			expectErr: pkg.ErrTimeout{Err: fmt.Errorf("test error")},
+		},
+	} {
+		service := &metadataService{
+			MetadataService: metadata.MetadataService{
+				Root:         tt.root,
+				Client:       &test.HttpClient{Resources: tt.resources, Err: tt.clientErr},
+				MetadataPath: tt.metadataPath,
+			},
+		}
+		if tt.expectErr == nil && tt.clientErr == nil {
+			tt.expect.Raw = []byte(tt.resources[tt.root+tt.metadataPath])
+		}
+		metadata, err := service.FetchMetadata()
+		if Error(err) != Error(tt.expectErr) {
+			t.Fatalf("bad error (%q): want %q, got %q", tt.resources, tt.expectErr, err)
+		}
+		if !reflect.DeepEqual(tt.expect, metadata) {
+			t.Fatalf("bad fetch (%q): want %#v, got %#v", tt.resources, tt.expect, metadata)
+		}
+	}
+}
+
+func Error(err error) string {
+	if err != nil {
+		return err.Error()
+	}
+	return ""
+}
@@ -15,11 +15,16 @@
 package proc_cmdline
 
 import (
+	"bytes"
 	"errors"
 	"io/ioutil"
 	"log"
+	"net"
+	"strconv"
 	"strings"
+	"time"
 
+	"github.com/coreos/coreos-cloudinit/config"
 	"github.com/coreos/coreos-cloudinit/datasource"
 	"github.com/coreos/coreos-cloudinit/pkg"
 )
@@ -27,25 +32,48 @@ import (
 const (
 	ProcCmdlineLocation        = "/proc/cmdline"
 	ProcCmdlineCloudConfigFlag = "cloud-config-url"
+
+	// ProcCmdlineCloudConfigDataFlag carries an inline cloud-config, encoded
+	// in base64 and optionally gzipped before that.
+	ProcCmdlineCloudConfigDataFlag = "cloud-config-data"
+
+	// ProcCmdlineDatasourceFlag selects the platforms whose datasources are
+	// used, as a comma separated list of names accepted by -oem.
+	ProcCmdlineDatasourceFlag = "ds"
+
+	// ProcCmdlineNetworkWaitFlag is the time to wait for the network before
+	// fetching cloud-config-url. rd.neednet=1 implies DefaultNetworkWait.
+	ProcCmdlineNetworkWaitFlag = "cloud-config-network-wait"
+
+	DefaultNetworkWait = time.Minute
 )
 
+const gzipMagicBytes = "\x1f\x8b"
+
+// NetworkConfig holds the dracut network parameters of the kernel command
+// line. It is provided as the NetworkConfig of the metadata.
+type NetworkConfig struct {
+	IP          []string
+	Nameservers []string
+}
+
 type procCmdline struct {
-	Location string
+	Location  string
+	networkUp func() bool
 }
 
 func NewDatasource() *procCmdline {
-	return &procCmdline{Location: ProcCmdlineLocation}
+	return &procCmdline{Location: ProcCmdlineLocation, networkUp: networkUp}
 }
 
 func (c *procCmdline) IsAvailable() bool {
-	contents, err := ioutil.ReadFile(c.Location)
+	cmdline, err := c.read()
 	if err != nil {
 		return false
 	}
-
-	cmdline := strings.TrimSpace(string(contents))
-	_, err = findCloudConfigURL(cmdline)
-	return (err == nil)
+	_, hasURL := cmdline.lookup(ProcCmdlineCloudConfigFlag)
+	_, hasData := cmdline.lookup(ProcCmdlineCloudConfigDataFlag)
+	return hasURL || hasData
 }
 
 func (c *procCmdline) AvailabilityChanges() bool {
@@ -56,22 +84,46 @@ func (c *procCmdline) ConfigRoot() string {
 	return ""
 }
 
-func (c *procCmdline) FetchMetadata() (datasource.Metadata, error) {
-	return datasource.Metadata{}, nil
+func (c *procCmdline) FetchMetadata() (metadata datasource.Metadata, err error) {
+	cmdline, err := c.read()
+	if err != nil {
+		return
+	}
+
+	var network NetworkConfig
+	for _, p := range cmdline {
+		switch p.key {
+		case "ip":
+			network.IP = append(network.IP, p.value)
+		case "nameserver":
+			network.Nameservers = append(network.Nameservers, p.value)
+		}
+	}
+	if len(network.IP) > 0 {
+		metadata.NetworkConfig = network
+	}
+	return
 }
 
 func (c *procCmdline) FetchUserdata() ([]byte, error) {
-	contents, err := ioutil.ReadFile(c.Location)
+	cmdline, err := c.read()
 	if err != nil {
 		return nil, err
 	}
 
-	cmdline := strings.TrimSpace(string(contents))
-	url, err := findCloudConfigURL(cmdline)
+	if data, ok := cmdline.lookup(ProcCmdlineCloudConfigDataFlag); ok {
+		return decodeCloudConfigData(data)
+	}
+
+	url, err := cmdline.cloudConfigURL()
 	if err != nil {
 		return nil, err
 	}
 
+	if timeout := cmdline.networkWait(); timeout > 0 {
+		c.waitForNetwork(timeout)
+	}
+
 	client := pkg.NewHttpClient()
 	cfg, err := client.GetRetry(url)
 	if err != nil {
@@ -81,30 +133,174 @@ func (c *procCmdline) FetchUserdata() ([]byte, error) {
 	return cfg, nil
 }
 
+func (c *procCmdline) FetchVendordata() ([]byte, error) {
+	return nil, nil
+}
+
 func (c *procCmdline) Type() string {
 	return "proc-cmdline"
 }
 
-func findCloudConfigURL(input string) (url string, err error) {
-	err = errors.New("cloud-config-url not found")
-	for _, token := range strings.Split(input, " ") {
-		parts := strings.SplitN(token, "=", 2)
+func (c *procCmdline) read() (cmdline, error) {
+	contents, err := ioutil.ReadFile(c.Location)
+	if err != nil {
+		return nil, err
+	}
+	return parseCmdline(strings.TrimSpace(string(contents))), nil
+}
+
+// waitForNetwork waits up to timeout for an interface with a global address.
+func (c *procCmdline) waitForNetwork(timeout time.Duration) {
+	log.Printf("Waiting up to %s for the network\n", timeout)
+	for deadline := time.Now().Add(timeout); !c.networkUp(); time.Sleep(time.Second) {
+		if time.Now().After(deadline) {
+			log.Printf("Network not up after %s, continuing\n", timeout)
+			return
+		}
+	}
+}
 
-		key := parts[0]
-		key = strings.Replace(key, "_", "-", -1)
+// Datasources returns the platforms selected with ds= on the kernel command
+// line found at location.
+func Datasources(location string) ([]string, error) {
+	cmdline, err := (&procCmdline{Location: location}).read()
+	if err != nil {
+		return nil, err
+	}
+	var platforms []string
+	if value, ok := cmdline.lookup(ProcCmdlineDatasourceFlag); ok {
+		for _, platform := range strings.Split(value, ",") {
+			// Arguments of cloud-init style selections (ds=nocloud;s=...)
+			// are not supported.
+			if platform = strings.SplitN(platform, ";", 2)[0]; platform != "" {
+				platforms = append(platforms, platform)
+			}
+		}
+	}
+	return platforms, nil
+}
 
-		if key != "cloud-config-url" {
+// param is a single kernel parameter. Parameters without a value, such as
+// "quiet", have hasValue unset.
+type param struct {
+	key      string
+	value    string
+	hasValue bool
+}
+
+type cmdline []param
+
+// parseCmdline splits the kernel command line into parameters the way the
+// kernel does: parameters are separated by whitespace outside of double
+// quotes, and the quotes are removed. Dashes and underscores are equivalent
+// in keys, so keys are normalized to dashes.
+func parseCmdline(input string) (c cmdline) {
+	var token []rune
+	var quoted, inToken bool
+	flush := func() {
+		if !inToken {
+			return
+		}
+		parts := strings.SplitN(string(token), "=", 2)
+		p := param{key: strings.Replace(parts[0], "_", "-", -1)}
+		if len(parts) == 2 {
+			p.value = parts[1]
+			p.hasValue = true
+		}
+		c = append(c, p)
+		token = token[:0]
+		inToken = false
+	}
+
+	for _, r := range input {
+		switch {
+		case r == '"':
+			quoted = !quoted
+			inToken = true
+		case !quoted && (r == ' ' || r == '\t' || r == '\n'):
+			flush()
+		default:
+			token = append(token, r)
+			inToken = true
+		}
+	}
+	flush()
+	return
+}
+
+// lookup returns the value of the last occurrence of key with a value.
+func (c cmdline) lookup(key string) (value string, ok bool) {
+	for _, p := range c {
+		if p.key == key && p.hasValue {
+			value, ok = p.value, true
+		}
+	}
+	return
+}
+
+func (c cmdline) networkWait() time.Duration {
+	if value, ok := c.lookup(ProcCmdlineNetworkWaitFlag); ok {
+		if seconds, err := strconv.Atoi(value); err == nil {
+			return time.Duration(seconds) * time.Second
+		}
+		if d, err := time.ParseDuration(value); err == nil {
+			return d
+		}
+		log.Printf("Ignoring invalid %s=%q\n", ProcCmdlineNetworkWaitFlag, value)
+	}
+	if value, _ := c.lookup("rd.neednet"); value == "1" {
+		return DefaultNetworkWait
+	}
+	return 0
+}
+
+func findCloudConfigURL(input string) (string, error) {
+	return parseCmdline(input).cloudConfigURL()
+}
+
+func (c cmdline) cloudConfigURL() (url string, err error) {
+	err = errors.New("cloud-config-url not found")
+	for _, p := range c {
+		if p.key != ProcCmdlineCloudConfigFlag {
 			continue
 		}
 
-		if len(parts) != 2 {
+		if !p.hasValue {
 			log.Printf("Found cloud-config-url in /proc/cmdline with no value, ignoring.")
 			continue
 		}
 
-		url = parts[1]
+		url = p.value
 		err = nil
 	}
 
 	return
 }
+
+// decodeCloudConfigData decodes the value of cloud-config-data, which is
+// base64 encoded and may be gzipped.
+func decodeCloudConfigData(data string) ([]byte, error) {
+	decoded, err := config.DecodeBase64Content(data)
+	if err != nil {
+		return nil, err
+	}
+	if bytes.HasPrefix(decoded, []byte(gzipMagicBytes)) {
+		return config.DecodeGzipContent(string(decoded))
+	}
+	return decoded, nil
+}
+
+// networkUp reports whether an interface other than the loopback has a global
+// unicast address.
+func networkUp() bool {
+	addrs, err := net.InterfaceAddrs()
+	if err != nil {
+		return false
+	}
+	for _, addr := range addrs {
+		if ipnet, ok := addr.(*net.IPNet); ok && ipnet.IP.IsGlobalUnicast() {
+			return true
+		}
+	}
+	return false
+}
@@ -15,12 +15,17 @@
 package proc_cmdline
 
 import (
+	"bytes"
+	"compress/gzip"
+	"encoding/base64"
 	"fmt"
 	"io/ioutil"
 	"net/http"
 	"net/http/httptest"
 	"os"
+	"reflect"
 	"testing"
+	"time"
 )
 
 func TestParseCmdlineCloudConfigFound(t *testing.T) {
@@ -100,3 +105,148 @@ func TestProcCmdlineAndFetchConfig(t *testing.T) {
 		t.Errorf("Test failed, response body: %s != %s", cfg, CloudConfigContent)
 	}
 }
+
+func TestParseCmdline(t *testing.T) {
+	for _, tt := range []struct {
+		input  string
+		expect cmdline
+	}{
+		{
+			input:  "",
+			expect: nil,
+		},
+		{
+			input: "quiet  root=/dev/sda1\tcloud_config_url=example.com",
+			expect: cmdline{
+				{key: "quiet"},
+				{key: "root", value: "/dev/sda1", hasValue: true},
+				{key: "cloud-config-url", value: "example.com", hasValue: true},
+			},
+		},
+		{
+			input: `foo="bar baz" "ds=a b" empty= ""`,
+			expect: cmdline{
+				{key: "foo", value: "bar baz", hasValue: true},
+				{key: "ds", value: "a b", hasValue: true},
+				{key: "empty", value: "", hasValue: true},
+				{key: ""},
+			},
+		},
+	} {
+		if c := parseCmdline(tt.input); !reflect.DeepEqual(tt.expect, c) {
+			t.Errorf("bad cmdline (%q): want %#v, got %#v", tt.input, tt.expect, c)
+		}
+	}
+}
+
+func TestDecodeCloudConfigData(t *testing.T) {
+	config := "#cloud-config\nhostname: cmdline\n"
+
+	var gz bytes.Buffer
+	w := gzip.NewWriter(&gz)
+	w.Write([]byte(config))
+	w.Close()
+
+	for _, tt := range []struct {
+		data   string
+		expect string
+		err    bool
+	}{
+		{data: base64.StdEncoding.EncodeToString([]byte(config)), expect: config},
+		{data: base64.StdEncoding.EncodeToString(gz.Bytes()), expect: config},
+		{data: "not base64!", err: true},
+	} {
+		data, err := decodeCloudConfigData(tt.data)
+		if (err != nil) != tt.err {
+			t.Fatalf("bad error (%q): want error %t, got %v", tt.data, tt.err, err)
+		}
+		if string(data) != tt.expect {
+			t.Fatalf("bad data (%q): want %q, got %q", tt.data, tt.expect, data)
+		}
+	}
+}
+
+func TestNetworkWait(t *testing.T) {
+	for _, tt := range []struct {
+		input  string
+		expect time.Duration
+	}{
+		{input: "", expect: 0},
+		{input: "rd.neednet=1", expect: DefaultNetworkWait},
+		{input: "rd.neednet=0", expect: 0},
+		{input: "cloud-config-network-wait=10 rd.neednet=1", expect: 10 * time.Second},
+		{input: "cloud_config_network_wait=1m30s", expect: 90 * time.Second},
+		{input: "cloud-config-network-wait=bad", expect: 0},
+	} {
+		if wait := parseCmdline(tt.input).networkWait(); wait != tt.expect {
+			t.Errorf("bad network wait (%q): want %s, got %s", tt.input, tt.expect, wait)
+		}
+	}
+}
+
+func writeCmdline(t *testing.T, cmdline string) string {
+	file, err := ioutil.TempFile(os.TempDir(), "test_proc_cmdline")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	defer file.Close()
+	if _, err = file.Write([]byte(cmdline)); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	return file.Name()
+}
+
+func TestProcCmdlineData(t *testing.T) {
+	config := "#cloud-config\n"
+	location := writeCmdline(t, fmt.Sprintf("quiet cloud-config-data=%s ip=eth0:dhcp ip=192.0.2.5::192.0.2.1:24::eth1:none nameserver=192.0.2.53 ds=digitalocean,packet;s=ignored\n", base64.StdEncoding.EncodeToString([]byte(config))))
+	defer os.Remove(location)
+
+	p := NewDatasource()
+	p.Location = location
+	if !p.IsAvailable() {
+		t.Fatalf("datasource not available")
+	}
+	cfg, err := p.FetchUserdata()
+	if err != nil || string(cfg) != config {
+		t.Fatalf("bad userdata: want %q, got %q (%v)", config, cfg, err)
+	}
+
+	metadata, err := p.FetchMetadata()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	network := NetworkConfig{
+		IP:          []string{"eth0:dhcp", "192.0.2.5::192.0.2.1:24::eth1:none"},
+		Nameservers: []string{"192.0.2.53"},
+	}
+	if !reflect.DeepEqual(network, metadata.NetworkConfig) {
+		t.Fatalf("bad network config: want %#v, got %#v", network, metadata.NetworkConfig)
+	}
+
+	platforms, err := Datasources(location)
+	if err != nil || !reflect.DeepEqual([]string{"digitalocean", "packet"}, platforms) {
+		t.Fatalf("bad datasources: want %q, got %q (%v)", []string{"digitalocean", "packet"}, platforms, err)
+	}
+}
+
+func TestWaitForNetwork(t *testing.T) {
+	polls := 0
+	p := &procCmdline{networkUp: func() bool {
+		polls++
+		return polls > 1
+	}}
+	p.waitForNetwork(time.Minute)
+	if polls != 2 {
+		t.Fatalf("bad number of polls: want %d, got %d", 2, polls)
+	}
+
+	polls = 0
+	p.networkUp = func() bool {
+		polls++
+		return false
+	}
+	p.waitForNetwork(0)
+	if polls != 1 {
+		t.Fatalf("bad number of polls: want %d, got %d", 1, polls)
+	}
+}
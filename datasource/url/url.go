@@ -50,6 +50,10 @@ func (f *remoteFile) FetchUserdata() ([]byte, error) {
 	return client.GetRetry(f.url)
 }
 
+func (f *remoteFile) FetchVendordata() ([]byte, error) {
+	return nil, nil
+}
+
 func (f *remoteFile) Type() string {
 	return "url"
 }
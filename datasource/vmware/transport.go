@@ -0,0 +1,113 @@
+// Copyright 2015 CoreOS, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package vmware
+
+import (
+	"bytes"
+	"log"
+	"os/exec"
+	"strings"
+)
+
+// Transport carries guestinfo RPC requests to the hypervisor.
+type Transport interface {
+	// Available reports whether the hypervisor can be reached.
+	Available() bool
+	// Send sends an RPC request such as "info-get guestinfo.hostname" and
+	// returns the reply and whether the request succeeded.
+	Send(request string) (reply string, ok bool, err error)
+}
+
+// rpctoolPaths are the locations searched for vmware-rpctool when it is not
+// in $PATH.
+var rpctoolPaths = []string{
+	"/usr/share/oem/bin/vmware-rpctool",
+	"/usr/bin/vmware-rpctool",
+}
+
+// newTransport returns the native backdoor transport if it is supported on
+// this platform and the machine is running under VMware, falling back to
+// vmware-rpctool otherwise.
+func newTransport() Transport {
+	if t := backdoorTransport(); t != nil && t.Available() {
+		return t
+	}
+	if t := newRpctoolTransport(); t != nil {
+		log.Printf("Using %s to read guestinfo\n", t.path)
+		return t
+	}
+	return unavailableTransport{}
+}
+
+type rpctoolTransport struct {
+	path string
+	run  func(name string, args ...string) ([]byte, error)
+}
+
+// newRpctoolTransport returns a transport using vmware-rpctool, or nil if it
+// cannot be found.
+func newRpctoolTransport() *rpctoolTransport {
+	path, err := exec.LookPath("vmware-rpctool")
+	if err != nil {
+		for _, p := range rpctoolPaths {
+			if path, err = exec.LookPath(p); err == nil {
+				break
+			}
+		}
+	}
+	if err != nil {
+		return nil
+	}
+	return &rpctoolTransport{path: path, run: runCommand}
+}
+
+func (t rpctoolTransport) Available() bool {
+	_, err := t.run(t.path, "log coreos-cloudinit: probing guestinfo")
+	return err == nil
+}
+
+// Send runs vmware-rpctool with the request. The tool exits with a non-zero
+// status and prints the reason when a request fails, e.g. when a guestinfo
+// variable is not set.
+func (t rpctoolTransport) Send(request string) (string, bool, error) {
+	out, err := t.run(t.path, request)
+	if _, ok := err.(*exec.ExitError); ok {
+		return strings.TrimSpace(string(out)), false, nil
+	} else if err != nil {
+		return "", false, err
+	}
+	return strings.TrimSuffix(string(out), "\n"), true, nil
+}
+
+func runCommand(name string, args ...string) ([]byte, error) {
+	var out bytes.Buffer
+	cmd := exec.Command(name, args...)
+	cmd.Stdout = &out
+	cmd.Stderr = &out
+	err := cmd.Run()
+	return out.Bytes(), err
+}
+
+// unavailableTransport is used when no transport is supported, so that the
+// datasource is reported as unavailable.
+type unavailableTransport struct{}
+
+func (unavailableTransport) Available() bool {
+	return false
+}
+
+func (unavailableTransport) Send(request string) (string, bool, error) {
+	return "", false, nil
+}
@@ -18,32 +18,56 @@ import (
 	"fmt"
 	"log"
 	"net"
+	"strconv"
+	"strings"
 
 	"github.com/coreos/coreos-cloudinit/config"
 	"github.com/coreos/coreos-cloudinit/datasource"
 	"github.com/coreos/coreos-cloudinit/pkg"
 
-	"github.com/sigma/vmw-guestinfo/rpcvmx"
-	"github.com/sigma/vmw-guestinfo/vmcheck"
+	"gopkg.in/yaml.v2"
 )
 
 type readConfigFunction func(key string) (string, error)
 type urlDownloadFunction func(url string) ([]byte, error)
 
 type vmware struct {
+	transport   Transport
 	readConfig  readConfigFunction
 	urlDownload urlDownloadFunction
 }
 
+// guestinfoMetadata is the document which may be passed in
+// guestinfo.metadata, as JSON or YAML.
+type guestinfoMetadata struct {
+	InstanceID    string      `yaml:"instance-id"`
+	LocalHostname string      `yaml:"local-hostname"`
+	Hostname      string      `yaml:"hostname"`
+	PublicKeys    interface{} `yaml:"public-keys"`
+
+	// Network is a cloud-init network config, either inline or as a
+	// string in the given encoding.
+	Network         interface{} `yaml:"network"`
+	NetworkEncoding string      `yaml:"network.encoding"`
+}
+
 func NewDatasource() *vmware {
-	return &vmware{
-		readConfig:  readConfig,
+	return NewDatasourceWithTransport(newTransport())
+}
+
+// NewDatasourceWithTransport returns a datasource reading guestinfo through
+// the given transport.
+func NewDatasourceWithTransport(t Transport) *vmware {
+	v := &vmware{
+		transport:   t,
 		urlDownload: urlDownload,
 	}
+	v.readConfig = v.readTransport
+	return v
 }
 
 func (v vmware) IsAvailable() bool {
-	return vmcheck.IsVirtualWorld()
+	return v.transport.Available()
 }
 
 func (v vmware) AvailabilityChanges() bool {
@@ -57,6 +81,10 @@ func (v vmware) ConfigRoot() string {
 func (v vmware) FetchMetadata() (metadata datasource.Metadata, err error) {
 	metadata.Hostname, _ = v.readConfig("hostname")
 
+	if err = v.fetchGuestinfoMetadata(&metadata); err != nil {
+		return
+	}
+
 	netconf := map[string]string{}
 	saveConfig := func(key string, args ...interface{}) string {
 		key = fmt.Sprintf(key, args...)
@@ -125,7 +153,9 @@ func (v vmware) FetchMetadata() (metadata datasource.Metadata, err error) {
 			}
 		}
 	}
-	metadata.NetworkConfig = netconf
+	if metadata.NetworkConfig == nil {
+		metadata.NetworkConfig = netconf
+	}
 
 	return
 }
@@ -141,6 +171,17 @@ func (v vmware) FetchUserdata() ([]byte, error) {
 		return nil, err
 	}
 
+	// Fall back to the variables used by cloud-init
+	if data == "" {
+		userdata, userdataEncoding, err := v.readEncoded("userdata")
+		if err != nil {
+			return nil, err
+		}
+		if userdata != "" {
+			data, encoding = userdata, userdataEncoding
+		}
+	}
+
 	// Try to fallback to url if no explicit data
 	if data == "" {
 		url, err := v.readConfig("coreos.config.url")
@@ -157,10 +198,15 @@ func (v vmware) FetchUserdata() ([]byte, error) {
 		}
 	}
 
-	if encoding != "" {
-		return config.DecodeContent(data, encoding)
+	return decode(data, encoding)
+}
+
+func (v vmware) FetchVendordata() ([]byte, error) {
+	data, encoding, err := v.readEncoded("vendordata")
+	if err != nil {
+		return nil, err
 	}
-	return []byte(data), nil
+	return decode(data, encoding)
 }
 
 func (v vmware) Type() string {
@@ -172,9 +218,113 @@ func urlDownload(url string) ([]byte, error) {
 	return client.GetRetry(url)
 }
 
-func readConfig(key string) (string, error) {
-	data, err := rpcvmx.NewConfig().GetString(key, "")
+// fetchGuestinfoMetadata merges the document passed in guestinfo.metadata
+// into metadata. Variables set individually take precedence.
+func (v vmware) fetchGuestinfoMetadata(metadata *datasource.Metadata) error {
+	data, encoding, err := v.readEncoded("metadata")
+	if err != nil || data == "" {
+		return err
+	}
+	raw, err := decode(data, encoding)
+	if err != nil {
+		return err
+	}
+
+	var m guestinfoMetadata
+	if err := yaml.Unmarshal(raw, &m); err != nil {
+		return fmt.Errorf("invalid guestinfo.metadata: %v", err)
+	}
+
+	metadata.InstanceID = m.InstanceID
+	if metadata.Hostname == "" {
+		metadata.Hostname = m.LocalHostname
+	}
+	if metadata.Hostname == "" {
+		metadata.Hostname = m.Hostname
+	}
+
+	var keys []string
+	switch k := m.PublicKeys.(type) {
+	case string:
+		keys = strings.Split(strings.TrimSpace(k), "\n")
+	case []interface{}:
+		for _, key := range k {
+			keys = append(keys, fmt.Sprint(key))
+		}
+	}
+	for i, key := range keys {
+		if metadata.SSHPublicKeys == nil {
+			metadata.SSHPublicKeys = map[string]string{}
+		}
+		metadata.SSHPublicKeys[strconv.Itoa(i)] = key
+	}
+
+	if m.Network != nil {
+		netconf, err := parseNetwork(m.Network, m.NetworkEncoding)
+		if err != nil {
+			return fmt.Errorf("invalid network in guestinfo.metadata: %v", err)
+		}
+		metadata.NetworkConfig = netconf
+	}
+	metadata.Raw = raw
+	return nil
+}
+
+// parseNetwork parses the network config of guestinfo.metadata, which may be
+// wrapped in a "network" key like the network-config of other datasources.
+func parseNetwork(network interface{}, encoding string) (config.Network, error) {
+	var raw []byte
+	var err error
+	if n, ok := network.(string); ok {
+		raw, err = decode(n, encoding)
+	} else {
+		raw, err = yaml.Marshal(network)
+	}
+	if err != nil {
+		return config.Network{}, err
+	}
+
+	var wrapped struct {
+		Network config.Network `yaml:"network"`
+	}
+	if err := yaml.Unmarshal(raw, &wrapped); err == nil && wrapped.Network.Version != 0 {
+		return wrapped.Network, nil
+	}
+	var netconf config.Network
+	err = yaml.Unmarshal(raw, &netconf)
+	return netconf, err
+}
+
+// readEncoded reads the variable with the given key, along with its encoding
+// from the variable <key>.encoding.
+func (v vmware) readEncoded(key string) (data, encoding string, err error) {
+	if data, err = v.readConfig(key); err != nil || data == "" {
+		return
+	}
+	encoding, err = v.readConfig(key + ".encoding")
+	return
+}
+
+// decode decodes data in one of the encodings accepted by config.DecodeContent
+// or their cloud-init aliases.
+func decode(data, encoding string) ([]byte, error) {
+	switch encoding {
+	case "":
+		return []byte(data), nil
+	case "b64":
+		encoding = "base64"
+	case "gz+b64", "gzip+b64", "gz+base64":
+		encoding = "gzip+base64"
+	}
+	return config.DecodeContent(data, encoding)
+}
+
+func (v vmware) readTransport(key string) (string, error) {
+	data, ok, err := v.transport.Send("info-get guestinfo." + key)
 	if err == nil {
+		if !ok {
+			data = ""
+		}
 		log.Printf("Read from %q: %q\n", key, data)
 	} else {
 		log.Printf("Failed to read from %q: %v\n", key, err)
@@ -17,9 +17,12 @@ package vmware
 import (
 	"errors"
 	"net"
+	"os/exec"
 	"reflect"
+	"strings"
 	"testing"
 
+	"github.com/coreos/coreos-cloudinit/config"
 	"github.com/coreos/coreos-cloudinit/datasource"
 )
 
@@ -214,3 +217,205 @@ func TestFetchUserdataError(t *testing.T) {
 		t.Errorf("bad error: want %v, got %v", testErr, err)
 	}
 }
+
+// fakeTransport answers info-get requests from a map of guestinfo variables.
+type fakeTransport struct {
+	variables map[string]string
+	err       error
+}
+
+func (t fakeTransport) Available() bool {
+	return t.err == nil
+}
+
+func (t fakeTransport) Send(request string) (string, bool, error) {
+	if t.err != nil {
+		return "", false, t.err
+	}
+	if !strings.HasPrefix(request, "info-get guestinfo.") {
+		return "Unknown command", false, nil
+	}
+	val, ok := t.variables[strings.TrimPrefix(request, "info-get guestinfo.")]
+	if !ok {
+		return "No value found", false, nil
+	}
+	return val, true, nil
+}
+
+func TestTransport(t *testing.T) {
+	v := NewDatasourceWithTransport(fakeTransport{variables: map[string]string{
+		"hostname":           "test host",
+		"coreos.config.data": "test config",
+	}})
+	if !v.IsAvailable() {
+		t.Errorf("bad availability: want true, got false")
+	}
+	for key, val := range map[string]string{
+		"hostname":           "test host",
+		"coreos.config.data": "test config",
+		"coreos.config.url":  "",
+	} {
+		if got, err := v.readConfig(key); err != nil || got != val {
+			t.Errorf("bad value (%q): want %q, got %q (%v)", key, val, got, err)
+		}
+	}
+
+	testErr := errors.New("test error")
+	v = NewDatasourceWithTransport(fakeTransport{err: testErr})
+	if v.IsAvailable() {
+		t.Errorf("bad availability: want false, got true")
+	}
+	if _, err := v.FetchUserdata(); err != testErr {
+		t.Errorf("bad error: want %v, got %v", testErr, err)
+	}
+}
+
+func TestFetchGuestinfoData(t *testing.T) {
+	tests := []struct {
+		variables map[string]string
+
+		userdata   string
+		vendordata string
+		metadata   datasource.Metadata
+		err        error
+	}{
+		{
+			variables: map[string]string{
+				"userdata":            "dGVzdCBjb25maWc=",
+				"userdata.encoding":   "b64",
+				"vendordata":          "H4sIABaoWlUAAytJLS5RSM7PS8tMBwCQiHNZCwAAAA==",
+				"vendordata.encoding": "gz+b64",
+			},
+			userdata:   "test config",
+			vendordata: "test config",
+			metadata:   datasource.Metadata{NetworkConfig: map[string]string{}},
+		},
+		{
+			variables: map[string]string{
+				"coreos.config.data": "coreos config",
+				"userdata":           "test config",
+				"metadata":           `{"instance-id": "vm-1", "local-hostname": "test host", "public-keys": ["key1", "key2"]}`,
+			},
+			userdata: "coreos config",
+			metadata: datasource.Metadata{
+				InstanceID:    "vm-1",
+				Hostname:      "test host",
+				SSHPublicKeys: map[string]string{"0": "key1", "1": "key2"},
+				NetworkConfig: map[string]string{},
+				Raw:           []byte(`{"instance-id": "vm-1", "local-hostname": "test host", "public-keys": ["key1", "key2"]}`),
+			},
+		},
+		{
+			variables: map[string]string{
+				"hostname":          "guestinfo host",
+				"metadata":          "aW5zdGFuY2UtaWQ6IHZtLTIKbG9jYWwtaG9zdG5hbWU6IHRlc3QgaG9zdApwdWJsaWMta2V5czogc3NoLXJzYSBrZXkxCg==",
+				"metadata.encoding": "base64",
+			},
+			metadata: datasource.Metadata{
+				InstanceID:    "vm-2",
+				Hostname:      "guestinfo host",
+				SSHPublicKeys: map[string]string{"0": "ssh-rsa key1"},
+				NetworkConfig: map[string]string{},
+				Raw:           []byte("instance-id: vm-2\nlocal-hostname: test host\npublic-keys: ssh-rsa key1\n"),
+			},
+		},
+		{
+			variables: map[string]string{
+				"metadata":         "network:\n  version: 2\n  ethernets:\n    eth0:\n      dhcp4: true\n",
+				"interface.0.name": "eth1",
+			},
+			metadata: datasource.Metadata{
+				NetworkConfig: config.Network{
+					Version:   2,
+					Ethernets: map[string]config.NetworkDevice{"eth0": {DHCP4: true}},
+				},
+				Raw: []byte("network:\n  version: 2\n  ethernets:\n    eth0:\n      dhcp4: true\n"),
+			},
+		},
+		{
+			variables: map[string]string{
+				"metadata": `{"network": "bmV0d29yazoKICB2ZXJzaW9uOiAxCiAgY29uZmlnOgogIC0gdHlwZTogcGh5c2ljYWwKICAgIG5hbWU6IGV0aDAK", "network.encoding": "base64"}`,
+			},
+			metadata: datasource.Metadata{
+				NetworkConfig: config.Network{
+					Version: 1,
+					Config:  []config.NetworkConfigEntry{{Type: "physical", Name: "eth0"}},
+				},
+				Raw: []byte(`{"network": "bmV0d29yazoKICB2ZXJzaW9uOiAxCiAgY29uZmlnOgogIC0gdHlwZTogcGh5c2ljYWwKICAgIG5hbWU6IGV0aDAK", "network.encoding": "base64"}`),
+			},
+		},
+		{
+			variables: map[string]string{
+				"metadata": `{"network": "%", "network.encoding": "base64"}`,
+			},
+			err: errors.New(`invalid network in guestinfo.metadata: Unable to decode base64: "illegal base64 data at input byte 0"`),
+		},
+		{
+			variables: map[string]string{
+				"metadata": "[",
+			},
+			metadata: datasource.Metadata{Hostname: ""},
+			err:      errors.New("invalid guestinfo.metadata: yaml: line 1: did not find expected node content"),
+		},
+	}
+
+	for i, tt := range tests {
+		v := NewDatasourceWithTransport(fakeTransport{variables: tt.variables})
+
+		userdata, err := v.FetchUserdata()
+		if err != nil {
+			t.Errorf("bad error (#%d): want %v, got %v", i, nil, err)
+		}
+		if tt.userdata != string(userdata) {
+			t.Errorf("bad userdata (#%d): want %q, got %q", i, tt.userdata, userdata)
+		}
+
+		vendordata, err := v.FetchVendordata()
+		if err != nil {
+			t.Errorf("bad error (#%d): want %v, got %v", i, nil, err)
+		}
+		if tt.vendordata != string(vendordata) {
+			t.Errorf("bad vendordata (#%d): want %q, got %q", i, tt.vendordata, vendordata)
+		}
+
+		metadata, err := v.FetchMetadata()
+		if !reflect.DeepEqual(tt.err, err) {
+			t.Errorf("bad error (#%d): want %v, got %v", i, tt.err, err)
+		}
+		if !reflect.DeepEqual(tt.metadata, metadata) {
+			t.Errorf("bad metadata (#%d): want %#v, got %#v", i, tt.metadata, metadata)
+		}
+	}
+}
+
+func TestRpctoolTransport(t *testing.T) {
+	run := func(name string, args ...string) ([]byte, error) {
+		switch args[0] {
+		case "info-get guestinfo.hostname":
+			return []byte("test host\n"), nil
+		case "info-get guestinfo.missing":
+			return []byte("No value found\n"), exec.Command("false").Run()
+		}
+		return nil, errors.New("not running under VMware")
+	}
+	tr := rpctoolTransport{path: "vmware-rpctool", run: run}
+
+	if tr.Available() {
+		t.Errorf("bad availability: want false, got true")
+	}
+	for _, tt := range []struct {
+		request string
+		reply   string
+		ok      bool
+		err     error
+	}{
+		{"info-get guestinfo.hostname", "test host", true, nil},
+		{"info-get guestinfo.missing", "No value found", false, nil},
+		{"info-get guestinfo.other", "", false, errors.New("not running under VMware")},
+	} {
+		reply, ok, err := tr.Send(tt.request)
+		if reply != tt.reply || ok != tt.ok || !reflect.DeepEqual(tt.err, err) {
+			t.Errorf("bad reply (%q): want (%q, %t, %v), got (%q, %t, %v)", tt.request, tt.reply, tt.ok, tt.err, reply, ok, err)
+		}
+	}
+}
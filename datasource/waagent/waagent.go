@@ -79,6 +79,7 @@ func (a *waagent) FetchMetadata() (metadata datasource.Metadata, err error) {
 	if err = xml.Unmarshal(metadataBytes, &m); err != nil {
 		return
 	}
+	metadata.Raw = metadataBytes
 
 	var instance Instance
 	for _, i := range m.Instances.Instances {
@@ -103,6 +104,10 @@ func (a *waagent) FetchUserdata() ([]byte, error) {
 	return a.tryReadFile(path.Join(a.root, "CustomData"))
 }
 
+func (a *waagent) FetchVendordata() ([]byte, error) {
+	return nil, nil
+}
+
 func (a *waagent) Type() string {
 	return "waagent"
 }
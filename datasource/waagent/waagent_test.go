@@ -16,6 +16,7 @@ package waagent
 
 import (
 	"net"
+	"path"
 	"reflect"
 	"testing"
 
@@ -86,6 +87,9 @@ func TestFetchMetadata(t *testing.T) {
 			},
 		},
 	} {
+		if data, _ := tt.files.ReadFile(path.Join(tt.root, "SharedConfig.xml")); len(data) > 0 {
+			tt.metadata.Raw = data
+		}
 		a := waagent{tt.root, tt.files.ReadFile}
 		metadata, err := a.FetchMetadata()
 		if err != nil {
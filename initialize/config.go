@@ -21,6 +21,7 @@ import (
 	"os"
 	"os/exec"
 	"path"
+	"runtime"
 	"strings"
 
 	"github.com/coreos/coreos-cloudinit/config"
@@ -151,6 +152,16 @@ func Apply(cfg config.CloudConfig, ifaces []network.InterfaceGenerator, env *Env
 	}
 
 	if !isLock(env) {
+		conflicts := network.Validate(ifaces)
+		for _, conflict := range conflicts {
+			log.Printf("Network config %s", conflict)
+		}
+		if network.HasFatal(conflicts) {
+			log.Printf("Refusing to apply the network config, which has fatal conflicts, and restart networking")
+			ifaces = nil
+		}
+
+		resolved := system.ResolvedEnabled(env.Root())
 		var writeFiles []system.File
 		for _, file := range cfg.WriteFiles {
 			writeFiles = append(writeFiles, system.File{File: file})
@@ -160,6 +171,7 @@ func Apply(cfg config.CloudConfig, ifaces []network.InterfaceGenerator, env *Env
 			system.OEM{OEM: cfg.CoreOS.OEM},
 			system.Update{Update: cfg.CoreOS.Update, ReadConfig: system.DefaultReadConfig},
 			system.EtcHosts{EtcHosts: cfg.ManageEtcHosts},
+			system.ResolvConf{ResolvConf: resolvConf(cfg.ResolvConf, ifaces, env, resolved), Resolved: resolved},
 			system.Flannel{Flannel: cfg.CoreOS.Flannel},
 		} {
 			f, err := ccf.File()
@@ -187,17 +199,27 @@ func Apply(cfg config.CloudConfig, ifaces []network.InterfaceGenerator, env *Env
 		}
 
 		wroteEnvironment := false
+		wroteResolved := false
 		for _, file := range writeFiles {
 			fullPath, err := system.WriteFile(&file, env.Root())
 			if err != nil {
 				return err
 			}
-			if path.Clean(file.Path) == "/etc/environment" {
+			switch path.Clean(file.Path) {
+			case "/etc/environment":
 				wroteEnvironment = true
+			case system.ResolvedDropInFile:
+				wroteResolved = true
 			}
 			log.Printf("Wrote file %s to filesystem", fullPath)
 		}
 
+		if wroteResolved {
+			if err = system.RestartResolved(); err != nil {
+				return err
+			}
+		}
+
 		if !wroteEnvironment {
 			ef := env.DefaultEnvironmentFile()
 			if ef != nil {
@@ -210,9 +232,44 @@ func Apply(cfg config.CloudConfig, ifaces []network.InterfaceGenerator, env *Env
 		}
 
 		if len(ifaces) > 0 {
-			units = append(units, createNetworkingUnits(ifaces)...)
-			if err = system.RestartNetwork(ifaces); err != nil {
-				return err
+			switch env.NetworkRenderer() {
+			case NetworkRendererNetworkManager:
+				connections := network.NMConnections(ifaces)
+				for _, file := range createNMConnectionFiles(connections) {
+					fullPath, err := system.WriteFile(&file, env.Root())
+					if err != nil {
+						return err
+					}
+					log.Printf("Wrote NetworkManager connection %s to filesystem", fullPath)
+				}
+				if err = system.RestartNetworkManager(connections); err != nil {
+					return err
+				}
+			case NetworkRendererIfupdown:
+				file := system.File{File: config.File{
+					Path:               system.IfupdownConfigFile,
+					Content:            network.RenderDebianNetconf(ifaces),
+					RawFilePermissions: "0644",
+				}}
+				fullPath, err := system.WriteFile(&file, env.Root())
+				if err != nil {
+					return err
+				}
+				log.Printf("Wrote ifupdown config %s to filesystem", fullPath)
+				if err = system.RestartIfupdown(ifaces); err != nil {
+					return err
+				}
+			default:
+				switch runtime.GOOS {
+				case "freebsd", "openbsd", "netbsd":
+					// The BSDs have no networkd; RestartNetwork writes
+					// their rc.conf config instead.
+				default:
+					units = append(units, createNetworkingUnits(ifaces)...)
+				}
+				if err = system.RestartNetwork(env.Root(), ifaces); err != nil {
+					return err
+				}
 			}
 		}
 
@@ -232,6 +289,30 @@ func Apply(cfg config.CloudConfig, ifaces []network.InterfaceGenerator, env *Env
 	return Lock(env)
 }
 
+// resolvConf returns the DNS config of the machine. The nameservers and search
+// domains of the network config are used unless the cloud-config gives them,
+// or the network renderer already hands them to the resolver, which
+// NetworkManager does, as does networkd to systemd-resolved.
+func resolvConf(rc config.ResolvConf, ifaces []network.InterfaceGenerator, env *Environment, resolved bool) config.ResolvConf {
+	switch env.NetworkRenderer() {
+	case NetworkRendererNetworkManager:
+		return rc
+	case NetworkRendererNetworkd:
+		if resolved {
+			return rc
+		}
+	}
+
+	nameservers, domains := network.DNS(ifaces)
+	if len(rc.Nameservers) == 0 {
+		rc.Nameservers = nameservers
+	}
+	if len(rc.SearchDomains) == 0 {
+		rc.SearchDomains = domains
+	}
+	return rc
+}
+
 func createNetworkingUnits(interfaces []network.InterfaceGenerator) (units []system.Unit) {
 	appendNewUnit := func(units []system.Unit, name, content string) []system.Unit {
 		if content == "" {
@@ -251,6 +332,19 @@ func createNetworkingUnits(interfaces []network.InterfaceGenerator) (units []sys
 	return units
 }
 
+// createNMConnectionFiles returns the keyfiles of connections, which are only
+// readable by root as NetworkManager ignores them otherwise.
+func createNMConnectionFiles(connections []network.NMConnection) (files []system.File) {
+	for _, conn := range connections {
+		files = append(files, system.File{File: config.File{
+			Path:               path.Join(system.NetworkManagerConnectionsDir, conn.Filename),
+			Content:            conn.Content,
+			RawFilePermissions: "0600",
+		}})
+	}
+	return files
+}
+
 // processUnits takes a set of Units and applies them to the given root using
 // the given UnitManager. This can involve things like writing unit files to
 // disk, masking/unmasking units, or invoking systemd
@@ -263,6 +357,7 @@ func processUnits(units []system.Unit, root string, um system.UnitManager) error
 	actions := make([]action, 0, len(units))
 	reload := false
 	restartNetworkd := false
+	triggerUdev := false
 	for _, unit := range units {
 		if unit.Name == "" {
 			log.Printf("Skipping unit without name")
@@ -315,6 +410,9 @@ func processUnits(units []system.Unit, root string, um system.UnitManager) error
 
 		if unit.Group() == "network" {
 			restartNetworkd = true
+			// udev applies .link units as it handles devices, so they
+			// are only applied to existing ones once udev is triggered.
+			triggerUdev = triggerUdev || (unit.Type() == "link" && unit.Content != "")
 		} else if unit.Command != "" {
 			actions = append(actions, action{unit, unit.Command})
 		}
@@ -326,6 +424,18 @@ func processUnits(units []system.Unit, root string, um system.UnitManager) error
 		}
 	}
 
+	if triggerUdev {
+		for _, name := range []string{"systemd-udev-trigger.service", "systemd-udev-settle.service"} {
+			log.Printf("Restarting %s", name)
+			udev := system.Unit{Unit: config.Unit{Name: name}}
+			res, err := um.RunUnitCommand(udev, "restart")
+			if err != nil {
+				return err
+			}
+			log.Printf("Restarted %s (%s)", name, res)
+		}
+	}
+
 	if restartNetworkd {
 		log.Printf("Restarting systemd-networkd")
 		networkd := system.Unit{Unit: config.Unit{Name: "systemd-networkd.service"}}
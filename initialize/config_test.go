@@ -19,6 +19,7 @@ import (
 	"testing"
 
 	"github.com/coreos/coreos-cloudinit/config"
+	"github.com/coreos/coreos-cloudinit/datasource"
 	"github.com/coreos/coreos-cloudinit/network"
 	"github.com/coreos/coreos-cloudinit/system"
 )
@@ -146,6 +147,68 @@ func TestCreateNetworkingUnits(t *testing.T) {
 	}
 }
 
+func TestCreateNMConnectionFiles(t *testing.T) {
+	connections := []network.NMConnection{
+		{ID: "eth0", Filename: "00-eth0.nmconnection", Content: "test connection"},
+	}
+	expect := []system.File{
+		system.File{File: config.File{
+			Path:               "/etc/NetworkManager/system-connections/00-eth0.nmconnection",
+			Content:            "test connection",
+			RawFilePermissions: "0600",
+		}},
+	}
+	if files := createNMConnectionFiles(connections); !reflect.DeepEqual(expect, files) {
+		t.Errorf("bad files: want %#v, got %#v", expect, files)
+	}
+}
+
+func TestResolvConf(t *testing.T) {
+	ifaces, err := network.ProcessDebianNetconf([]byte(`auto eth0
+iface eth0 inet static
+    address 10.0.0.2
+    netmask 255.0.0.0
+    dns-nameservers 8.8.8.8
+    dns-search example.com
+`))
+	if err != nil {
+		t.Fatalf("bad error: want %v, got %v", nil, err)
+	}
+
+	for _, tt := range []struct {
+		rc       config.ResolvConf
+		renderer string
+		resolved bool
+		expect   config.ResolvConf
+	}{
+		{
+			renderer: NetworkRendererNetworkd,
+			expect:   config.ResolvConf{Nameservers: []string{"8.8.8.8"}, SearchDomains: []string{"example.com"}},
+		},
+		{
+			rc:       config.ResolvConf{Nameservers: []string{"1.1.1.1"}, Options: []string{"rotate"}},
+			renderer: NetworkRendererIfupdown,
+			resolved: true,
+			expect:   config.ResolvConf{Nameservers: []string{"1.1.1.1"}, SearchDomains: []string{"example.com"}, Options: []string{"rotate"}},
+		},
+		{
+			renderer: NetworkRendererNetworkd,
+			resolved: true,
+		},
+		{
+			rc:       config.ResolvConf{SearchDomains: []string{"example.org"}},
+			renderer: NetworkRendererNetworkManager,
+			expect:   config.ResolvConf{SearchDomains: []string{"example.org"}},
+		},
+	} {
+		env := NewEnvironment("./", "./", "./", "", datasource.Metadata{})
+		env.SetNetworkRenderer(tt.renderer)
+		if rc := resolvConf(tt.rc, ifaces, env, tt.resolved); !reflect.DeepEqual(tt.expect, rc) {
+			t.Errorf("bad resolv_conf (%s, %t): want %#v, got %#v", tt.renderer, tt.resolved, tt.expect, rc)
+		}
+	}
+}
+
 func TestProcessUnits(t *testing.T) {
 	tests := []struct {
 		units []system.Unit
@@ -188,6 +251,27 @@ func TestProcessUnits(t *testing.T) {
 				reload: true,
 			},
 		},
+		{
+			units: []system.Unit{
+				system.Unit{Unit: config.Unit{
+					Name:    "00-eth0.link",
+					Content: "[Match]\nMACAddress=52:54:00:12:34:56\n\n[Link]\nName=eth0",
+				}},
+				system.Unit{Unit: config.Unit{
+					Name:    "00-eth0.network",
+					Content: "[Match]\nName=eth0",
+				}},
+			},
+			result: TestUnitManager{
+				placed: []string{"00-eth0.link", "00-eth0.network"},
+				commands: []UnitAction{
+					UnitAction{"systemd-udev-trigger.service", "restart"},
+					UnitAction{"systemd-udev-settle.service", "restart"},
+					UnitAction{"systemd-networkd.service", "restart"},
+				},
+				reload: true,
+			},
+		},
 		{
 			units: []system.Unit{
 				system.Unit{Unit: config.Unit{
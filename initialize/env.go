@@ -28,12 +28,20 @@ import (
 
 const DefaultSSHKeyName = "coreos-cloudinit"
 
+// The renderers the network config can be applied with.
+const (
+	NetworkRendererNetworkd       = "networkd"
+	NetworkRendererNetworkManager = "networkmanager"
+	NetworkRendererIfupdown       = "ifupdown"
+)
+
 type Environment struct {
-	root          string
-	configRoot    string
-	workspace     string
-	sshKeyName    string
-	substitutions map[string]string
+	root            string
+	configRoot      string
+	workspace       string
+	sshKeyName      string
+	substitutions   map[string]string
+	networkRenderer string
 }
 
 // TODO(jonboulle): this is getting unwieldy, should be able to simplify the interface somehow
@@ -45,12 +53,13 @@ func NewEnvironment(root, configRoot, workspace, sshKeyName string, metadata dat
 		return ip.String()
 	}
 	substitutions := map[string]string{
-		"$public_ipv4":  firstNonNull(metadata.PublicIPv4, os.Getenv("COREOS_PUBLIC_IPV4")),
-		"$private_ipv4": firstNonNull(metadata.PrivateIPv4, os.Getenv("COREOS_PRIVATE_IPV4")),
-		"$public_ipv6":  firstNonNull(metadata.PublicIPv6, os.Getenv("COREOS_PUBLIC_IPV6")),
-		"$private_ipv6": firstNonNull(metadata.PrivateIPv6, os.Getenv("COREOS_PRIVATE_IPV6")),
+		"$public_ipv4":   firstNonNull(metadata.PublicIPv4, os.Getenv("COREOS_PUBLIC_IPV4")),
+		"$private_ipv4":  firstNonNull(metadata.PrivateIPv4, os.Getenv("COREOS_PRIVATE_IPV4")),
+		"$public_ipv6":   firstNonNull(metadata.PublicIPv6, os.Getenv("COREOS_PUBLIC_IPV6")),
+		"$private_ipv6":  firstNonNull(metadata.PrivateIPv6, os.Getenv("COREOS_PRIVATE_IPV6")),
+		"$floating_ipv4": firstNonNull(metadata.FloatingIPv4, os.Getenv("COREOS_FLOATING_IPV4")),
 	}
-	return &Environment{root, configRoot, workspace, sshKeyName, substitutions}
+	return &Environment{root, configRoot, workspace, sshKeyName, substitutions, ""}
 }
 
 func (e *Environment) Workspace() string {
@@ -73,6 +82,25 @@ func (e *Environment) SetSSHKeyName(name string) {
 	e.sshKeyName = name
 }
 
+// NetworkRenderer returns the renderer the network config is applied with,
+// which unless set is NetworkManager or ifupdown when either is enabled and
+// networkd otherwise.
+func (e *Environment) NetworkRenderer() string {
+	switch {
+	case e.networkRenderer != "":
+		return e.networkRenderer
+	case system.NetworkManagerEnabled(e.root):
+		return NetworkRendererNetworkManager
+	case system.IfupdownEnabled(e.root):
+		return NetworkRendererIfupdown
+	}
+	return NetworkRendererNetworkd
+}
+
+func (e *Environment) SetNetworkRenderer(renderer string) {
+	e.networkRenderer = renderer
+}
+
 // Apply goes through the map of substitutions and replaces all instances of
 // the keys with their respective values. It supports escaping substitutions
 // with a leading '\'.
@@ -108,6 +136,9 @@ func (e *Environment) DefaultEnvironmentFile() *system.EnvFile {
 	if ip, ok := e.substitutions["$private_ipv6"]; ok && len(ip) > 0 {
 		ef.Vars["COREOS_PRIVATE_IPV6"] = ip
 	}
+	if ip, ok := e.substitutions["$floating_ipv4"]; ok && len(ip) > 0 {
+		ef.Vars["COREOS_FLOATING_IPV4"] = ip
+	}
 	if len(ef.Vars) == 0 {
 		return nil
 	} else {
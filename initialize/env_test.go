@@ -105,12 +105,13 @@ addr: $private_ipv4
 
 func TestEnvironmentFile(t *testing.T) {
 	metadata := datasource.Metadata{
-		PublicIPv4:  net.ParseIP("1.2.3.4"),
-		PrivateIPv4: net.ParseIP("5.6.7.8"),
-		PublicIPv6:  net.ParseIP("1234::"),
-		PrivateIPv6: net.ParseIP("5678::"),
+		PublicIPv4:   net.ParseIP("1.2.3.4"),
+		PrivateIPv4:  net.ParseIP("5.6.7.8"),
+		PublicIPv6:   net.ParseIP("1234::"),
+		PrivateIPv6:  net.ParseIP("5678::"),
+		FloatingIPv4: net.ParseIP("9.10.11.12"),
 	}
-	expect := "COREOS_PRIVATE_IPV4=5.6.7.8\nCOREOS_PRIVATE_IPV6=5678::\nCOREOS_PUBLIC_IPV4=1.2.3.4\nCOREOS_PUBLIC_IPV6=1234::\n"
+	expect := "COREOS_FLOATING_IPV4=9.10.11.12\nCOREOS_PRIVATE_IPV4=5.6.7.8\nCOREOS_PRIVATE_IPV6=5678::\nCOREOS_PUBLIC_IPV4=1.2.3.4\nCOREOS_PUBLIC_IPV6=1234::\n"
 
 	dir, err := ioutil.TempDir(os.TempDir(), "coreos-cloudinit-")
 	if err != nil {
@@ -146,3 +147,40 @@ func TestEnvironmentFileNil(t *testing.T) {
 		t.Fatalf("Environment file not nil: %v", ef)
 	}
 }
+
+func TestEnvironmentNetworkRenderer(t *testing.T) {
+	dir, err := ioutil.TempDir(os.TempDir(), "coreos-cloudinit-")
+	if err != nil {
+		t.Fatalf("Unable to create tempdir: %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	env := NewEnvironment(dir, "./", "./", "", datasource.Metadata{})
+	if renderer := env.NetworkRenderer(); renderer != NetworkRendererNetworkd {
+		t.Fatalf("bad renderer: want %q, got %q", NetworkRendererNetworkd, renderer)
+	}
+
+	wants := path.Join(dir, "etc/systemd/system/multi-user.target.wants")
+	if err := os.MkdirAll(wants, 0755); err != nil {
+		t.Fatalf("Unable to create %s: %v", wants, err)
+	}
+	for _, tt := range []struct {
+		service  string
+		renderer string
+	}{
+		{"networking.service", NetworkRendererIfupdown},
+		{"NetworkManager.service", NetworkRendererNetworkManager},
+	} {
+		if err := os.Symlink(path.Join("/lib/systemd/system", tt.service), path.Join(wants, tt.service)); err != nil {
+			t.Fatalf("Unable to enable %s: %v", tt.service, err)
+		}
+		if renderer := env.NetworkRenderer(); renderer != tt.renderer {
+			t.Fatalf("bad renderer: want %q, got %q", tt.renderer, renderer)
+		}
+	}
+
+	env.SetNetworkRenderer(NetworkRendererNetworkd)
+	if renderer := env.NetworkRenderer(); renderer != NetworkRendererNetworkd {
+		t.Fatalf("bad renderer: want %q, got %q", NetworkRendererNetworkd, renderer)
+	}
+}
@@ -0,0 +1,104 @@
+// Copyright 2015 CoreOS, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package network
+
+import (
+	"fmt"
+	"log"
+	"net"
+	"strconv"
+	"strings"
+
+	"github.com/coreos/coreos-cloudinit/datasource/azure"
+)
+
+// ProcessAzureNetconf configures the interfaces reported by the Azure
+// instance metadata service, matched by their MAC address. The primary
+// address of every interface is configured by DHCP, which Azure serves for
+// both IPv4 and IPv6; secondary IPv4 addresses are configured statically
+// within the subnet of the interface.
+func ProcessAzureNetconf(config azure.Network) ([]InterfaceGenerator, error) {
+	log.Println("Processing Azure network config")
+
+	generators := make([]InterfaceGenerator, 0, len(config.Interfaces))
+	for _, iface := range config.Interfaces {
+		generator, err := parseAzureInterface(iface)
+		if err != nil {
+			return nil, err
+		}
+		generators = append(generators, &physicalInterface{*generator})
+	}
+	log.Printf("Parsed %d network interfaces\n", len(generators))
+
+	log.Println("Processed Azure network config")
+	return generators, nil
+}
+
+func parseAzureInterface(iface azure.Interface) (*logicalInterface, error) {
+	hwaddr, err := parseAzureMAC(iface.MacAddress)
+	if err != nil {
+		return nil, err
+	}
+
+	var addresses []net.IPNet
+	if len(iface.IPv4.IPAddress) > 1 {
+		if len(iface.IPv4.Subnet) == 0 {
+			return nil, fmt.Errorf("no subnet for the secondary addresses of %q", iface.MacAddress)
+		}
+		prefix, err := strconv.Atoi(iface.IPv4.Subnet[0].Prefix)
+		if err != nil {
+			return nil, fmt.Errorf("could not parse %q as IPv4 prefix", iface.IPv4.Subnet[0].Prefix)
+		}
+		for _, address := range iface.IPv4.IPAddress[1:] {
+			ip := net.ParseIP(address.PrivateIPAddress)
+			if ip == nil || ip.To4() == nil {
+				return nil, fmt.Errorf("could not parse %q as IPv4 address", address.PrivateIPAddress)
+			}
+			addresses = append(addresses, net.IPNet{
+				IP:   ip,
+				Mask: net.CIDRMask(prefix, net.IPv4len*8),
+			})
+		}
+	}
+
+	var config configMethod = configMethodDHCP{}
+	if len(addresses) > 0 {
+		config = configMethodStatic{
+			addresses:   addresses,
+			nameservers: []net.IP{},
+			routes:      []route{},
+			dhcp:        "yes",
+		}
+	}
+
+	return &logicalInterface{
+		hwaddr:   hwaddr,
+		config:   config,
+		children: []networkInterface{},
+	}, nil
+}
+
+// parseAzureMAC parses a MAC address as reported by the instance metadata
+// service, which omits the separators (e.g. "000D3AF806EC").
+func parseAzureMAC(mac string) (net.HardwareAddr, error) {
+	if len(mac) == 12 {
+		octets := make([]string, 0, 6)
+		for i := 0; i < len(mac); i += 2 {
+			octets = append(octets, mac[i:i+2])
+		}
+		mac = strings.Join(octets, ":")
+	}
+	return net.ParseMAC(mac)
+}
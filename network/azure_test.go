@@ -0,0 +1,83 @@
+// Copyright 2015 CoreOS, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package network
+
+import (
+	"testing"
+
+	"github.com/coreos/coreos-cloudinit/datasource/azure"
+)
+
+func azureInterface(mac, prefix string, addresses ...string) azure.Interface {
+	iface := azure.Interface{MacAddress: mac}
+	for _, address := range addresses {
+		iface.IPv4.IPAddress = append(iface.IPv4.IPAddress, azure.IPAddress{PrivateIPAddress: address})
+	}
+	if prefix != "" {
+		iface.IPv4.Subnet = []azure.Subnet{{Address: "10.0.0.0", Prefix: prefix}}
+	}
+	return iface
+}
+
+func TestProcessAzureNetconf(t *testing.T) {
+	for i, tt := range []struct {
+		config   azure.Network
+		networks []string
+		err      bool
+	}{
+		{
+			config:   azure.Network{},
+			networks: []string{},
+		},
+		{
+			config: azure.Network{Interfaces: []azure.Interface{
+				azureInterface("000D3AF806EC", "24", "10.0.0.4"),
+				azureInterface("00-0D-3A-F8-06-ED", "24", "10.0.1.4", "10.0.1.5", "10.0.1.6"),
+			}},
+			networks: []string{
+				"[Match]\nMACAddress=00:0d:3a:f8:06:ec\n\n[Network]\nDHCP=ipv4\n",
+				"[Match]\nMACAddress=00:0d:3a:f8:06:ed\n\n[Network]\nDHCP=yes\n\n[Address]\nAddress=10.0.1.5/24\n\n[Address]\nAddress=10.0.1.6/24\n",
+			},
+		},
+		{
+			config: azure.Network{Interfaces: []azure.Interface{azureInterface("bad", "24", "10.0.0.4")}},
+			err:    true,
+		},
+		{
+			config: azure.Network{Interfaces: []azure.Interface{azureInterface("000D3AF806EC", "", "10.0.0.4", "10.0.0.5")}},
+			err:    true,
+		},
+		{
+			config: azure.Network{Interfaces: []azure.Interface{azureInterface("000D3AF806EC", "24", "10.0.0.4", "bad")}},
+			err:    true,
+		},
+	} {
+		interfaces, err := ProcessAzureNetconf(tt.config)
+		if (err != nil) != tt.err {
+			t.Fatalf("bad error (#%d): want error %t, got %v", i, tt.err, err)
+		}
+		if tt.err {
+			continue
+		}
+		if len(interfaces) != len(tt.networks) {
+			t.Fatalf("bad number of interfaces (#%d): want %d, got %d", i, len(tt.networks), len(interfaces))
+		}
+		for j, iface := range interfaces {
+			if network := iface.Network(); network != tt.networks[j] {
+				t.Errorf("bad network (#%d, %d): want %q, got %q", i, j, tt.networks[j], network)
+			}
+		}
+	}
+}
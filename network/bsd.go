@@ -0,0 +1,505 @@
+// Copyright 2015 CoreOS, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package network
+
+import (
+	"fmt"
+	"log"
+	"net"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// NetconfFile is a network config file of a system which does not use
+// networkd.
+type NetconfFile struct {
+	Path    string
+	Content string
+}
+
+// laggProtocols maps networkd bond modes to the lagg(4) and trunk(4)
+// protocols.
+var laggProtocols = map[string]string{
+	"balance-rr":    "roundrobin",
+	"active-backup": "failover",
+	"balance-xor":   "loadbalance",
+	"broadcast":     "broadcast",
+	"802.3ad":       "lacp",
+}
+
+var (
+	// clonedName matches the name of a cloned interface, e.g. lagg0, and
+	// captures the name of its cloner.
+	clonedName = regexp.MustCompile("^([A-Za-z]+)[0-9]+$")
+
+	// rcNameInvalid matches the characters which rc.conf variable names
+	// cannot contain.
+	rcNameInvalid = regexp.MustCompile("[^A-Za-z0-9_]")
+)
+
+// bsdInterface is an interface as it is named and configured on a BSD
+// system. Bonds are lagg or trunk interfaces.
+type bsdInterface struct {
+	name      string
+	kind      string
+	members   []string
+	protocol  string
+	vlanID    int
+	parent    string
+	config    configMethodStatic
+	hwaddress net.HardwareAddr
+}
+
+// bsdInterfaces lists the named interfaces along with their config. Bonds,
+// bridges and VLANs are renamed after the cloner of their kind in prefixes,
+// unless their name already is, as some systems cannot rename interfaces.
+func bsdInterfaces(interfaces []InterfaceGenerator, prefixes map[string]string) []bsdInterface {
+	names := make(map[string]string)
+	used := make(map[string]bool)
+	for _, iface := range interfaces {
+		names[iface.Name()] = iface.Name()
+		used[iface.Name()] = true
+	}
+	next := make(map[string]int)
+	for _, iface := range interfaces {
+		prefix, ok := prefixes[iface.Type()]
+		if m := clonedName.FindStringSubmatch(iface.Name()); !ok || (m != nil && m[1] == prefix) {
+			continue
+		}
+		name := fmt.Sprintf("%s%d", prefix, next[prefix])
+		for ; used[name]; name = fmt.Sprintf("%s%d", prefix, next[prefix]) {
+			next[prefix]++
+		}
+		used[name] = true
+		names[iface.Name()] = name
+	}
+
+	parents := make(map[string]string)
+	for _, iface := range interfaces {
+		if ni, ok := iface.(networkInterface); ok {
+			for _, child := range ni.Children() {
+				if _, ok := child.(*vlanInterface); ok {
+					parents[child.Name()] = iface.Name()
+				}
+			}
+		}
+	}
+
+	var bsdIfaces []bsdInterface
+	for _, iface := range interfaces {
+		i := logicalOf(iface)
+		if i == nil {
+			continue
+		}
+		if i.name == "" || i.name == "*" {
+			log.Printf("Skipping interface %q, which cannot be matched on BSD\n", iface.Filename())
+			continue
+		}
+
+		b := bsdInterface{name: names[i.name], kind: iface.Type()}
+		switch c := i.config.(type) {
+		case configMethodStatic:
+			b.config = c
+		case configMethodDHCP:
+			b.config = configMethodStatic{dhcp: "ipv4", hwaddress: c.hwaddress, mtu: c.mtu}
+		}
+		b.hwaddress = b.config.hwaddress
+		if _, ok := iface.(*physicalInterface); !ok && b.hwaddress == nil {
+			b.hwaddress = i.hwaddr
+		}
+
+		switch t := iface.(type) {
+		case *bondInterface:
+			for _, slave := range t.slaves {
+				b.members = append(b.members, bsdName(names, slave))
+			}
+			b.protocol = laggProtocol(t.options)
+		case *bridgeInterface:
+			for _, port := range t.ports {
+				b.members = append(b.members, bsdName(names, port))
+			}
+		case *vlanInterface:
+			b.vlanID = t.id
+			b.parent = t.rawDevice
+			if p, ok := parents[i.name]; ok {
+				b.parent = p
+			}
+			b.parent = bsdName(names, b.parent)
+		}
+		bsdIfaces = append(bsdIfaces, b)
+	}
+	return bsdIfaces
+}
+
+func bsdName(names map[string]string, name string) string {
+	if n, ok := names[name]; ok {
+		return n
+	}
+	return name
+}
+
+// laggProtocol returns the lagg protocol of a bond with the given networkd or
+// Debian options. Bonds default to round robin, as on Linux.
+func laggProtocol(options map[string]string) string {
+	mode, ok := options["Mode"]
+	if !ok {
+		mode = options["mode"]
+	}
+	if n, err := strconv.Atoi(mode); err == nil {
+		mode = bondModes[n]
+	}
+	if mode == "" {
+		mode = "balance-rr"
+	}
+	protocol, ok := laggProtocols[mode]
+	if !ok {
+		log.Printf("Unsupported bond mode %q, using failover\n", mode)
+		return "failover"
+	}
+	return protocol
+}
+
+// splitAddresses splits addresses by family.
+func splitAddresses(addresses []net.IPNet) (v4, v6 []net.IPNet) {
+	for _, address := range addresses {
+		if address.IP.To4() != nil {
+			v4 = append(v4, address)
+		} else {
+			v6 = append(v6, address)
+		}
+	}
+	return
+}
+
+// isDefaultGateway reports whether r is a plain default route.
+func isDefaultGateway(r route) bool {
+	ones, _ := r.destination.Mask.Size()
+	return ones == 0 && r.gateway != nil && !r.onLink
+}
+
+// RenderFreeBSDNetconf renders interfaces as the rc.conf(5) variables of the
+// FreeBSD network scripts. Bonds and bridges are cloned as lagg and bridge
+// interfaces and renamed.
+func RenderFreeBSDNetconf(interfaces []InterfaceGenerator) NetconfFile {
+	var cloned, routes, routes6 []string
+	var defaultRouter, defaultRouter6 string
+	var config, routeConfig string
+	var parents []string
+	vlans := make(map[string][]string)
+	next := map[string]int{}
+	for _, b := range bsdInterfaces(interfaces, nil) {
+		var ifconfig []string
+		switch b.kind {
+		case "bond", "bridge":
+			cloner := map[string]string{"bond": "lagg", "bridge": "bridge"}[b.kind]
+			clone := fmt.Sprintf("%s%d", cloner, next[cloner])
+			next[cloner]++
+			cloned = append(cloned, clone)
+			if clone != b.name {
+				config += rcVar(fmt.Sprintf("ifconfig_%s_name", rcName(clone)), b.name)
+			}
+			for _, member := range b.members {
+				if b.kind == "bond" {
+					ifconfig = append(ifconfig, "laggport "+member)
+				} else {
+					ifconfig = append(ifconfig, "addm "+member)
+				}
+			}
+			if b.kind == "bond" {
+				ifconfig = append([]string{"laggproto " + b.protocol}, ifconfig...)
+			}
+		case "vlan":
+			if _, ok := vlans[b.parent]; !ok {
+				parents = append(parents, b.parent)
+			}
+			if b.name == fmt.Sprintf("%s.%d", b.parent, b.vlanID) {
+				vlans[b.parent] = append(vlans[b.parent], strconv.Itoa(b.vlanID))
+			} else {
+				vlans[b.parent] = append(vlans[b.parent], b.name)
+				config += rcVar(fmt.Sprintf("create_args_%s", rcName(b.name)), fmt.Sprintf("vlan %d", b.vlanID))
+			}
+		}
+
+		dhcp4, dhcp6 := dhcpFamilies(b.config.dhcp)
+		v4, v6 := splitAddresses(b.config.addresses)
+		var aliases []string
+		switch {
+		case dhcp4:
+			ifconfig = append(ifconfig, "DHCP")
+		case len(v4) > 0:
+			ifconfig = append(ifconfig, fmt.Sprintf("inet %s netmask %s", v4[0].IP, ifupdownNetmask(v4[0].Mask)))
+			v4 = v4[1:]
+		}
+		for _, address := range v4 {
+			aliases = append(aliases, fmt.Sprintf("inet %s netmask %s", address.IP, ifupdownNetmask(address.Mask)))
+		}
+		if b.hwaddress != nil {
+			ifconfig = append(ifconfig, "ether "+b.hwaddress.String())
+		}
+		if b.config.mtu != 0 {
+			ifconfig = append(ifconfig, fmt.Sprintf("mtu %d", b.config.mtu))
+		}
+		if len(ifconfig) == 0 || (b.kind == "bridge" && !dhcp4) {
+			ifconfig = append(ifconfig, "up")
+		}
+		config += rcVar(fmt.Sprintf("ifconfig_%s", rcName(b.name)), strings.Join(ifconfig, " "))
+
+		var ipv6 []string
+		if len(v6) > 0 {
+			ones, _ := v6[0].Mask.Size()
+			ipv6 = append(ipv6, fmt.Sprintf("inet6 %s prefixlen %d", v6[0].IP, ones))
+			v6 = v6[1:]
+		}
+		if dhcp6 {
+			log.Printf("Ignoring DHCPv6 on %q, which is not supported on FreeBSD\n", b.name)
+		}
+		if b.config.acceptRA == "yes" || dhcp6 {
+			if len(ipv6) == 0 {
+				ipv6 = append(ipv6, "inet6")
+			}
+			ipv6 = append(ipv6, "accept_rtadv")
+		}
+		if len(ipv6) > 0 {
+			config += rcVar(fmt.Sprintf("ifconfig_%s_ipv6", rcName(b.name)), strings.Join(ipv6, " "))
+		}
+		for _, address := range v6 {
+			ones, _ := address.Mask.Size()
+			aliases = append(aliases, fmt.Sprintf("inet6 %s prefixlen %d", address.IP, ones))
+		}
+		for n, alias := range aliases {
+			config += rcVar(fmt.Sprintf("ifconfig_%s_alias%d", rcName(b.name), n), alias)
+		}
+
+		for _, r := range b.config.routes {
+			v4 := r.destination.IP.To4() != nil
+			switch {
+			case isDefaultGateway(r) && v4 && defaultRouter == "":
+				defaultRouter = r.gateway.String()
+				continue
+			case isDefaultGateway(r) && !v4 && defaultRouter6 == "":
+				defaultRouter6 = r.gateway.String()
+				continue
+			}
+			gateway := fmt.Sprintf("-interface %s", b.name)
+			if r.gateway != nil {
+				gateway = r.gateway.String()
+			}
+			value := fmt.Sprintf("-net %s %s", r.destination.String(), gateway)
+			if v4 {
+				name := fmt.Sprintf("cloudinit%d", len(routes))
+				routes = append(routes, name)
+				routeConfig += rcVar("route_"+name, value)
+			} else {
+				name := fmt.Sprintf("cloudinit%d", len(routes6))
+				routes6 = append(routes6, name)
+				routeConfig += rcVar("ipv6_route_"+name, value)
+			}
+		}
+	}
+
+	var header string
+	if len(cloned) > 0 {
+		header += rcVar("cloned_interfaces", strings.Join(cloned, " "))
+	}
+	for _, parent := range parents {
+		header += rcVar(fmt.Sprintf("vlans_%s", rcName(parent)), strings.Join(vlans[parent], " "))
+	}
+	config = header + config
+	if defaultRouter != "" {
+		config += rcVar("defaultrouter", defaultRouter)
+	}
+	if defaultRouter6 != "" {
+		config += rcVar("ipv6_defaultrouter", defaultRouter6)
+	}
+	if len(routes) > 0 {
+		config += rcVar("static_routes", strings.Join(routes, " "))
+	}
+	if len(routes6) > 0 {
+		config += rcVar("ipv6_static_routes", strings.Join(routes6, " "))
+	}
+	config += routeConfig
+
+	return NetconfFile{Path: "/etc/rc.conf.d/network", Content: config}
+}
+
+func rcVar(name, value string) string {
+	return fmt.Sprintf("%s=%q\n", name, value)
+}
+
+// rcName returns the form of an interface name used in rc.conf variables.
+func rcName(name string) string {
+	return rcNameInvalid.ReplaceAllString(name, "_")
+}
+
+// RenderOpenBSDNetconf renders interfaces as the hostname.if(5) files of
+// OpenBSD, along with the default gateways in mygate(5). Bonds are trunk
+// interfaces, and bonds, bridges and VLANs are named after their cloner.
+func RenderOpenBSDNetconf(interfaces []InterfaceGenerator) []NetconfFile {
+	var files []NetconfFile
+	var gateways []string
+	for _, b := range bsdInterfaces(interfaces, map[string]string{"bond": "trunk", "bridge": "bridge", "vlan": "vlan"}) {
+		var lines []string
+		switch b.kind {
+		case "bond":
+			lines = append(lines, "trunkproto "+b.protocol)
+			for _, member := range b.members {
+				lines = append(lines, "trunkport "+member)
+			}
+		case "bridge":
+			for _, member := range b.members {
+				lines = append(lines, "add "+member)
+			}
+		case "vlan":
+			lines = append(lines, fmt.Sprintf("vnetid %d", b.vlanID), "parent "+b.parent)
+		}
+		if b.hwaddress != nil {
+			lines = append(lines, "lladdr "+b.hwaddress.String())
+		}
+		if b.config.mtu != 0 {
+			lines = append(lines, fmt.Sprintf("mtu %d", b.config.mtu))
+		}
+
+		dhcp4, dhcp6 := dhcpFamilies(b.config.dhcp)
+		v4, v6 := splitAddresses(b.config.addresses)
+		if dhcp4 {
+			lines = append(lines, "dhcp")
+		}
+		for n, address := range v4 {
+			alias := ""
+			if n > 0 || dhcp4 {
+				alias = "alias "
+			}
+			lines = append(lines, fmt.Sprintf("inet %s%s %s", alias, address.IP, ifupdownNetmask(address.Mask)))
+		}
+		for _, address := range v6 {
+			ones, _ := address.Mask.Size()
+			lines = append(lines, fmt.Sprintf("inet6 %s %d", address.IP, ones))
+		}
+		if dhcp6 {
+			log.Printf("Ignoring DHCPv6 on %q, which is not supported on OpenBSD\n", b.name)
+		}
+		if b.config.acceptRA == "yes" || dhcp6 {
+			lines = append(lines, "inet6 autoconf")
+		}
+		lines = append(lines, "up")
+
+		for _, r := range b.config.routes {
+			if isDefaultGateway(r) {
+				gateways = append(gateways, r.gateway.String())
+				continue
+			}
+			lines = append(lines, bsdRouteCommand(r, b.name, "$if"))
+		}
+
+		files = append(files, NetconfFile{
+			Path:    fmt.Sprintf("/etc/hostname.%s", b.name),
+			Content: strings.Join(lines, "\n") + "\n",
+		})
+	}
+	if len(gateways) > 0 {
+		files = append(files, NetconfFile{Path: "/etc/mygate", Content: strings.Join(gateways, "\n") + "\n"})
+	}
+	return files
+}
+
+// RenderNetBSDNetconf renders interfaces as the ifconfig.if(5) files of
+// NetBSD, along with the default gateways in rc.conf(5) variables. Bonds,
+// bridges and VLANs are named after their cloner.
+func RenderNetBSDNetconf(interfaces []InterfaceGenerator) []NetconfFile {
+	var files []NetconfFile
+	var config string
+	for _, b := range bsdInterfaces(interfaces, map[string]string{"bond": "lagg", "bridge": "bridge", "vlan": "vlan"}) {
+		var lines []string
+		switch b.kind {
+		case "bond":
+			lines = append(lines, "create", "laggproto "+b.protocol)
+			for _, member := range b.members {
+				lines = append(lines, "laggport "+member)
+			}
+		case "bridge":
+			lines = append(lines, "create")
+			for _, member := range b.members {
+				lines = append(lines, fmt.Sprintf("!brconfig $int add %s", member))
+			}
+		case "vlan":
+			lines = append(lines, "create", fmt.Sprintf("vlan %d vlanif %s", b.vlanID, b.parent))
+		}
+		if b.hwaddress != nil {
+			lines = append(lines, fmt.Sprintf("link %s active", b.hwaddress))
+		}
+		if b.config.mtu != 0 {
+			lines = append(lines, fmt.Sprintf("mtu %d", b.config.mtu))
+		}
+
+		v4, v6 := splitAddresses(b.config.addresses)
+		for n, address := range v4 {
+			alias := ""
+			if n > 0 {
+				alias = " alias"
+			}
+			lines = append(lines, fmt.Sprintf("inet %s netmask %s%s", address.IP, ifupdownNetmask(address.Mask), alias))
+		}
+		for _, address := range v6 {
+			ones, _ := address.Mask.Size()
+			lines = append(lines, fmt.Sprintf("inet6 %s prefixlen %d alias", address.IP, ones))
+		}
+		lines = append(lines, "up")
+		// dhcpcd handles both DHCP and router advertisements.
+		if b.config.dhcp != "" || b.config.acceptRA == "yes" {
+			lines = append(lines, "!dhcpcd -q $int")
+		}
+
+		for _, r := range b.config.routes {
+			if isDefaultGateway(r) {
+				name := "defaultroute"
+				if r.destination.IP.To4() == nil {
+					name = "defaultroute6"
+				}
+				config += rcVar(name, r.gateway.String())
+				continue
+			}
+			lines = append(lines, bsdRouteCommand(r, b.name, "$int"))
+		}
+
+		files = append(files, NetconfFile{
+			Path:    fmt.Sprintf("/etc/ifconfig.%s", b.name),
+			Content: strings.Join(lines, "\n") + "\n",
+		})
+	}
+	if config != "" {
+		files = append(files, NetconfFile{Path: "/etc/rc.conf.d/network", Content: config})
+	}
+	return files
+}
+
+// bsdRouteCommand returns the command adding r once the interface, known to
+// the command as ifVar, is up.
+func bsdRouteCommand(r route, name, ifVar string) string {
+	command := "!route -q add"
+	if r.destination.IP.To4() == nil {
+		command += " -inet6"
+	}
+	command += fmt.Sprintf(" -net %s", r.destination.String())
+	if r.gateway != nil {
+		command += fmt.Sprintf(" %s", r.gateway)
+	} else {
+		command += fmt.Sprintf(" -interface %s", ifVar)
+	}
+	if r.metric != 0 {
+		log.Printf("Ignoring the metric of route %s on %q\n", r.destination.String(), name)
+	}
+	return command
+}
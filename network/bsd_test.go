@@ -0,0 +1,139 @@
+// Copyright 2015 CoreOS, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package network
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestRenderFreeBSDNetconf(t *testing.T) {
+	interfaces, err := ProcessDebianNetconf([]byte(debianNetconf))
+	if err != nil {
+		t.Fatalf("bad error: want %v, got %v", nil, err)
+	}
+
+	expect := NetconfFile{
+		Path: "/etc/rc.conf.d/network",
+		Content: `cloned_interfaces="lagg0 bridge0"
+vlans_bond0="10"
+ifconfig_lagg0_name="bond0"
+ifconfig_bond0="laggproto lacp laggport eth1 laggport eth2 DHCP ether 52:54:00:12:34:56"
+ifconfig_bond0_ipv6="inet6 accept_rtadv"
+ifconfig_bond0_10="inet 172.16.0.2 netmask 255.255.255.0"
+ifconfig_bridge0_name="br0"
+ifconfig_br0="addm eth3 up"
+ifconfig_eth0="inet 192.168.1.10 netmask 255.255.255.0 mtu 9000"
+ifconfig_eth0_ipv6="inet6 2001:db8::10 prefixlen 64"
+ifconfig_eth0_alias0="inet 192.168.2.10 netmask 255.255.255.0"
+ifconfig_eth1="up"
+ifconfig_eth2="up"
+ifconfig_eth3="up"
+defaultrouter="192.168.1.1"
+ipv6_defaultrouter="2001:db8::1"
+static_routes="cloudinit0"
+route_cloudinit0="-net 10.0.0.0/8 192.168.1.254"
+`,
+	}
+	if file := RenderFreeBSDNetconf(interfaces); !reflect.DeepEqual(expect, file) {
+		t.Fatalf("bad file: want %#v, got %#v", expect, file)
+	}
+}
+
+func TestRenderFreeBSDNetconfVLANs(t *testing.T) {
+	interfaces, err := ProcessDebianNetconf([]byte(`auto eth0
+iface eth0 inet manual
+
+auto vlan10
+iface vlan10 inet dhcp
+    vlan_raw_device eth0
+
+auto vlan20
+iface vlan20 inet manual
+    vlan_raw_device eth0
+`))
+	if err != nil {
+		t.Fatalf("bad error: want %v, got %v", nil, err)
+	}
+
+	expect := `vlans_eth0="vlan10 vlan20"
+ifconfig_eth0="up"
+create_args_vlan10="vlan 10"
+ifconfig_vlan10="DHCP"
+create_args_vlan20="vlan 20"
+ifconfig_vlan20="up"
+`
+	if content := RenderFreeBSDNetconf(interfaces).Content; content != expect {
+		t.Fatalf("bad content: want %q, got %q", expect, content)
+	}
+}
+
+func TestRenderOpenBSDNetconf(t *testing.T) {
+	interfaces, err := ProcessDebianNetconf([]byte(debianNetconf))
+	if err != nil {
+		t.Fatalf("bad error: want %v, got %v", nil, err)
+	}
+
+	expect := []NetconfFile{
+		{Path: "/etc/hostname.trunk0", Content: "trunkproto lacp\ntrunkport eth1\ntrunkport eth2\nlladdr 52:54:00:12:34:56\ndhcp\ninet6 autoconf\nup\n"},
+		{Path: "/etc/hostname.vlan0", Content: "vnetid 10\nparent trunk0\ninet 172.16.0.2 255.255.255.0\nup\n"},
+		{Path: "/etc/hostname.bridge0", Content: "add eth3\nup\n"},
+		{Path: "/etc/hostname.eth0", Content: "mtu 9000\ninet 192.168.1.10 255.255.255.0\ninet alias 192.168.2.10 255.255.255.0\ninet6 2001:db8::10 64\nup\n!route -q add -net 10.0.0.0/8 192.168.1.254\n"},
+		{Path: "/etc/hostname.eth1", Content: "up\n"},
+		{Path: "/etc/hostname.eth2", Content: "up\n"},
+		{Path: "/etc/hostname.eth3", Content: "up\n"},
+		{Path: "/etc/mygate", Content: "192.168.1.1\n2001:db8::1\n"},
+	}
+	if files := RenderOpenBSDNetconf(interfaces); !reflect.DeepEqual(expect, files) {
+		t.Fatalf("bad files: want %#v, got %#v", expect, files)
+	}
+}
+
+func TestRenderNetBSDNetconf(t *testing.T) {
+	interfaces, err := ProcessDebianNetconf([]byte(debianNetconf))
+	if err != nil {
+		t.Fatalf("bad error: want %v, got %v", nil, err)
+	}
+
+	expect := []NetconfFile{
+		{Path: "/etc/ifconfig.lagg0", Content: "create\nlaggproto lacp\nlaggport eth1\nlaggport eth2\nlink 52:54:00:12:34:56 active\nup\n!dhcpcd -q $int\n"},
+		{Path: "/etc/ifconfig.vlan0", Content: "create\nvlan 10 vlanif lagg0\ninet 172.16.0.2 netmask 255.255.255.0\nup\n"},
+		{Path: "/etc/ifconfig.bridge0", Content: "create\n!brconfig $int add eth3\nup\n"},
+		{Path: "/etc/ifconfig.eth0", Content: "mtu 9000\ninet 192.168.1.10 netmask 255.255.255.0\ninet 192.168.2.10 netmask 255.255.255.0 alias\ninet6 2001:db8::10 prefixlen 64 alias\nup\n!route -q add -net 10.0.0.0/8 192.168.1.254\n"},
+		{Path: "/etc/ifconfig.eth1", Content: "up\n"},
+		{Path: "/etc/ifconfig.eth2", Content: "up\n"},
+		{Path: "/etc/ifconfig.eth3", Content: "up\n"},
+		{Path: "/etc/rc.conf.d/network", Content: "defaultroute=\"192.168.1.1\"\ndefaultroute6=\"2001:db8::1\"\n"},
+	}
+	if files := RenderNetBSDNetconf(interfaces); !reflect.DeepEqual(expect, files) {
+		t.Fatalf("bad files: want %#v, got %#v", expect, files)
+	}
+}
+
+func TestLaggProtocol(t *testing.T) {
+	for _, tt := range []struct {
+		options  map[string]string
+		protocol string
+	}{
+		{options: map[string]string{}, protocol: "roundrobin"},
+		{options: map[string]string{"Mode": "active-backup"}, protocol: "failover"},
+		{options: map[string]string{"mode": "4"}, protocol: "lacp"},
+		{options: map[string]string{"Mode": "balance-tlb"}, protocol: "failover"},
+	} {
+		if protocol := laggProtocol(tt.options); protocol != tt.protocol {
+			t.Fatalf("bad protocol (%v): want %q, got %q", tt.options, tt.protocol, protocol)
+		}
+	}
+}
@@ -0,0 +1,233 @@
+// Copyright 2015 CoreOS, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package network
+
+import (
+	"fmt"
+	"log"
+	"net"
+	"strconv"
+	"strings"
+
+	"github.com/coreos/coreos-cloudinit/datasource/proc_cmdline"
+)
+
+// dracutIP is a single ip= kernel parameter in dracut syntax.
+type dracutIP struct {
+	iface    string
+	autoconf string
+	address  *net.IPNet
+	gateway  net.IP
+	dns      []net.IP
+	mtu      int
+}
+
+// allInterfaces configures every interface, for ip= parameters which name
+// none.
+type allInterfaces struct {
+	physicalInterface
+}
+
+func (a *allInterfaces) Filename() string {
+	return fmt.Sprintf("%02x-cmdline", a.configDepth)
+}
+
+// ProcessCmdlineNetconf converts the ip= and nameserver= kernel parameters,
+// in dracut syntax, to networkd units. Parameters naming the same interface
+// are merged.
+func ProcessCmdlineNetconf(config proc_cmdline.NetworkConfig) ([]InterfaceGenerator, error) {
+	log.Println("Processing kernel command line network config")
+
+	var nameservers []net.IP
+	for _, ns := range config.Nameservers {
+		ip := net.ParseIP(ns)
+		if ip == nil {
+			return nil, fmt.Errorf("could not parse %q as nameserver IP address", ns)
+		}
+		nameservers = append(nameservers, ip)
+	}
+
+	var names []string
+	configs := make(map[string]*configMethodStatic)
+	dhcp := make(map[string][2]bool)
+	for _, value := range config.IP {
+		ip, err := parseDracutIP(value)
+		if err != nil {
+			return nil, err
+		}
+
+		conf, ok := configs[ip.iface]
+		if !ok {
+			// Every interface gets its own copy of the nameservers, so
+			// that its dracut DNS servers are not appended to the others'.
+			conf = &configMethodStatic{nameservers: append([]net.IP(nil), nameservers...)}
+			configs[ip.iface] = conf
+			names = append(names, ip.iface)
+		}
+		if ip.address != nil {
+			conf.addresses = append(conf.addresses, *ip.address)
+		}
+		if ip.gateway != nil {
+			conf.routes = append(conf.routes, route{destination: defaultDestination(ip.gateway), gateway: ip.gateway})
+		}
+		conf.nameservers = append(conf.nameservers, ip.dns...)
+		if ip.mtu != 0 {
+			conf.mtu = ip.mtu
+		}
+
+		modes := dhcp[ip.iface]
+		switch ip.autoconf {
+		case "dhcp", "on", "any":
+			modes[0] = true
+		case "dhcp6":
+			modes[1] = true
+		}
+		dhcp[ip.iface] = modes
+	}
+
+	interfaces := make([]InterfaceGenerator, 0, len(names))
+	for _, name := range names {
+		conf := configs[name]
+		conf.dhcp = dhcpMode(dhcp[name][0], dhcp[name][1])
+		if name == "" {
+			interfaces = append(interfaces, &allInterfaces{physicalInterface{logicalInterface{name: "*", config: *conf}}})
+		} else {
+			interfaces = append(interfaces, &physicalInterface{logicalInterface{name: name, config: *conf}})
+		}
+	}
+
+	log.Printf("Processed %d interfaces from the kernel command line\n", len(interfaces))
+	return interfaces, nil
+}
+
+// parseDracutIP parses one of the forms of the ip= parameter:
+//
+//	ip=<autoconf>
+//	ip=<interface>:<autoconf>[:[<mtu>][:<macaddr>]]
+//	ip=<client-IP>:[<peer>]:<gateway-IP>:<netmask>:<client_hostname>:<interface>:<autoconf>[:[<mtu>][:<macaddr>]]
+//	ip=<client-IP>:[<peer>]:<gateway-IP>:<netmask>:<client_hostname>:<interface>:<autoconf>[:[<dns1>][:<dns2>]]
+//
+// IPv6 addresses are enclosed in brackets. The MAC address is not supported
+// and is ignored.
+func parseDracutIP(value string) (ip dracutIP, err error) {
+	fields := splitDracutFields(value)
+
+	switch {
+	case len(fields) == 1:
+		ip.autoconf = fields[0]
+	case net.ParseIP(strings.SplitN(fields[0], "/", 2)[0]) == nil:
+		ip.iface, ip.autoconf = fields[0], fields[1]
+		if ip.mtu, err = parseDracutMTU(fields[2:], value); err != nil {
+			return ip, err
+		}
+	case len(fields) < 7:
+		return ip, fmt.Errorf("could not parse ip=%s: too few fields", value)
+	default:
+		if ip.address, err = parseAddress(fields[0], fields[3]); err != nil {
+			return ip, fmt.Errorf("could not parse ip=%s: %v", value, err)
+		}
+		if fields[2] != "" {
+			if ip.gateway = net.ParseIP(fields[2]); ip.gateway == nil {
+				return ip, fmt.Errorf("could not parse %q as gateway IP address", fields[2])
+			}
+		}
+		ip.iface, ip.autoconf = fields[5], fields[6]
+		if len(fields) > 7 && fields[7] != "" && net.ParseIP(fields[7]) == nil {
+			if ip.mtu, err = parseDracutMTU(fields[7:], value); err != nil {
+				return ip, err
+			}
+			break
+		}
+		for _, field := range fields[7:] {
+			if dns := net.ParseIP(field); dns != nil {
+				ip.dns = append(ip.dns, dns)
+			} else if field != "" {
+				log.Printf("Ignoring the MAC address of ip=%s\n", value)
+				break
+			}
+		}
+	}
+
+	switch ip.autoconf {
+	case "", "none", "off", "dhcp", "on", "any", "dhcp6", "auto6":
+	default:
+		return ip, fmt.Errorf("unsupported autoconf method %q in ip=%s", ip.autoconf, value)
+	}
+	return
+}
+
+// parseDracutMTU parses the optional MTU and MAC address fields which follow
+// the autoconf method of ip=value.
+func parseDracutMTU(fields []string, value string) (mtu int, err error) {
+	if len(fields) > 0 && fields[0] != "" {
+		if mtu, err = strconv.Atoi(fields[0]); err != nil || mtu <= 0 {
+			return 0, fmt.Errorf("could not parse %q as MTU in ip=%s", fields[0], value)
+		}
+	}
+	if len(fields) > 1 && fields[1] != "" {
+		log.Printf("Ignoring the MAC address of ip=%s\n", value)
+	}
+	return mtu, nil
+}
+
+// parseAddress parses an IP address, which may carry a prefix length, and
+// its netmask, given either dotted or as a prefix length.
+func parseAddress(address, netmask string) (*net.IPNet, error) {
+	if strings.Contains(address, "/") {
+		ip, ipnet, err := net.ParseCIDR(address)
+		if err != nil {
+			return nil, err
+		}
+		return &net.IPNet{IP: ip, Mask: ipnet.Mask}, nil
+	}
+
+	ip := net.ParseIP(address)
+	if ip == nil {
+		return nil, fmt.Errorf("bad address %q", address)
+	}
+	bits := net.IPv6len * 8
+	if ip.To4() != nil {
+		ip, bits = ip.To4(), net.IPv4len*8
+	}
+
+	if prefix, err := strconv.Atoi(netmask); err == nil && prefix >= 0 && prefix <= bits {
+		return &net.IPNet{IP: ip, Mask: net.CIDRMask(prefix, bits)}, nil
+	}
+	if mask := net.ParseIP(netmask).To4(); mask != nil && bits == net.IPv4len*8 {
+		return &net.IPNet{IP: ip, Mask: net.IPMask(mask)}, nil
+	}
+	return nil, fmt.Errorf("bad netmask %q", netmask)
+}
+
+// splitDracutFields splits value on the colons which are not enclosed in
+// brackets, and removes the brackets.
+func splitDracutFields(value string) (fields []string) {
+	var field []rune
+	var bracketed bool
+	for _, r := range value {
+		switch {
+		case r == '[':
+			bracketed = true
+		case r == ']':
+			bracketed = false
+		case r == ':' && !bracketed:
+			fields = append(fields, string(field))
+			field = field[:0]
+		default:
+			field = append(field, r)
+		}
+	}
+	return append(fields, string(field))
+}
@@ -0,0 +1,146 @@
+// Copyright 2015 CoreOS, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package network
+
+import (
+	"testing"
+
+	"github.com/coreos/coreos-cloudinit/datasource/proc_cmdline"
+)
+
+func TestSplitDracutFields(t *testing.T) {
+	for _, tt := range []struct {
+		value  string
+		fields []string
+	}{
+		{value: "dhcp", fields: []string{"dhcp"}},
+		{value: "eth0:dhcp::", fields: []string{"eth0", "dhcp", "", ""}},
+		{value: "[2001:db8::5]::[2001:db8::1]:64::eth0:none", fields: []string{"2001:db8::5", "", "2001:db8::1", "64", "", "eth0", "none"}},
+	} {
+		fields := splitDracutFields(tt.value)
+		if len(fields) != len(tt.fields) {
+			t.Fatalf("bad fields (%q): want %q, got %q", tt.value, tt.fields, fields)
+		}
+		for i := range fields {
+			if fields[i] != tt.fields[i] {
+				t.Fatalf("bad fields (%q): want %q, got %q", tt.value, tt.fields, fields)
+			}
+		}
+	}
+}
+
+func TestProcessCmdlineNetconf(t *testing.T) {
+	for _, tt := range []struct {
+		config    proc_cmdline.NetworkConfig
+		filenames []string
+		networks  []string
+		err       string
+	}{
+		{
+			config:    proc_cmdline.NetworkConfig{IP: []string{"dhcp"}},
+			filenames: []string{"00-cmdline"},
+			networks:  []string{"[Match]\nName=*\n\n[Network]\nDHCP=ipv4\n"},
+		},
+		{
+			config: proc_cmdline.NetworkConfig{
+				IP: []string{
+					"192.0.2.5::192.0.2.1:255.255.255.0:host:eth0:none:192.0.2.53",
+					"[2001:db8::5]::[2001:db8::1]:64::eth0:dhcp6",
+					"eth1:dhcp:1500:52:54:00:12:34:56",
+				},
+				Nameservers: []string{"198.51.100.53"},
+			},
+			filenames: []string{"00-eth0", "00-eth1"},
+			networks: []string{
+				"[Match]\nName=eth0\n\n[Network]\nDHCP=ipv6\nDNS=198.51.100.53\nDNS=192.0.2.53\n" +
+					"\n[Address]\nAddress=192.0.2.5/24\n" +
+					"\n[Address]\nAddress=2001:db8::5/64\n" +
+					"\n[Route]\nDestination=0.0.0.0/0\nGateway=192.0.2.1\n" +
+					"\n[Route]\nDestination=::/0\nGateway=2001:db8::1\n",
+				"[Match]\nName=eth1\n\n[Network]\nDHCP=ipv4\nDNS=198.51.100.53\n\n[Link]\nMTUBytes=1500\n",
+			},
+		},
+		{
+			config: proc_cmdline.NetworkConfig{
+				IP: []string{
+					"192.0.2.5::192.0.2.1:255.255.255.0::eth0:none:192.0.2.53",
+					"198.51.100.5::198.51.100.1:255.255.255.0::eth1:none:198.51.100.54",
+				},
+				Nameservers: []string{"203.0.113.1", "203.0.113.2", "203.0.113.3"},
+			},
+			filenames: []string{"00-eth0", "00-eth1"},
+			networks: []string{
+				"[Match]\nName=eth0\n\n[Network]\nDNS=203.0.113.1\nDNS=203.0.113.2\nDNS=203.0.113.3\nDNS=192.0.2.53\n" +
+					"\n[Address]\nAddress=192.0.2.5/24\n" +
+					"\n[Route]\nDestination=0.0.0.0/0\nGateway=192.0.2.1\n",
+				"[Match]\nName=eth1\n\n[Network]\nDNS=203.0.113.1\nDNS=203.0.113.2\nDNS=203.0.113.3\nDNS=198.51.100.54\n" +
+					"\n[Address]\nAddress=198.51.100.5/24\n" +
+					"\n[Route]\nDestination=0.0.0.0/0\nGateway=198.51.100.1\n",
+			},
+		},
+		{
+			config:    proc_cmdline.NetworkConfig{IP: []string{"10.0.0.2/8:::::ens3:off:9000:52:54:00:12:34:56"}},
+			filenames: []string{"00-ens3"},
+			networks:  []string{"[Match]\nName=ens3\n\n[Network]\n\n[Address]\nAddress=10.0.0.2/8\n\n[Link]\nMTUBytes=9000\n"},
+		},
+		{
+			config:    proc_cmdline.NetworkConfig{IP: []string{"10.0.0.2/8:::::ens3:off"}},
+			filenames: []string{"00-ens3"},
+			networks:  []string{"[Match]\nName=ens3\n\n[Network]\n\n[Address]\nAddress=10.0.0.2/8\n"},
+		},
+		{
+			config: proc_cmdline.NetworkConfig{IP: []string{"eth0:ibft"}},
+			err:    `unsupported autoconf method "ibft" in ip=eth0:ibft`,
+		},
+		{
+			config: proc_cmdline.NetworkConfig{IP: []string{"eth0:dhcp:jumbo"}},
+			err:    `could not parse "jumbo" as MTU in ip=eth0:dhcp:jumbo`,
+		},
+		{
+			config: proc_cmdline.NetworkConfig{IP: []string{"192.0.2.5::192.0.2.1"}},
+			err:    "could not parse ip=192.0.2.5::192.0.2.1: too few fields",
+		},
+		{
+			config: proc_cmdline.NetworkConfig{IP: []string{"192.0.2.5::192.0.2.1:bad::eth0:none"}},
+			err:    `could not parse ip=192.0.2.5::192.0.2.1:bad::eth0:none: bad netmask "bad"`,
+		},
+		{
+			config: proc_cmdline.NetworkConfig{IP: []string{"dhcp"}, Nameservers: []string{"bad"}},
+			err:    `could not parse "bad" as nameserver IP address`,
+		},
+	} {
+		interfaces, err := ProcessCmdlineNetconf(tt.config)
+		if tt.err != "" {
+			if err == nil || err.Error() != tt.err {
+				t.Fatalf("bad error (%v): want %q, got %v", tt.config, tt.err, err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Fatalf("bad error (%v): want %v, got %v", tt.config, nil, err)
+		}
+		if len(interfaces) != len(tt.networks) {
+			t.Fatalf("bad number of interfaces (%v): want %d, got %d", tt.config, len(tt.networks), len(interfaces))
+		}
+		for i, iface := range interfaces {
+			if filename := iface.Filename(); filename != tt.filenames[i] {
+				t.Errorf("bad filename (%v #%d): want %q, got %q", tt.config, i, tt.filenames[i], filename)
+			}
+			if network := iface.Network(); network != tt.networks[i] {
+				t.Errorf("bad network (%v #%d): want %q, got %q", tt.config, i, tt.networks[i], network)
+			}
+		}
+	}
+}
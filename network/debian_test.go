@@ -43,6 +43,8 @@ func TestProcessDebianNetconf(t *testing.T) {
 		{"iface", true, -1},
 		{"auto eth1\nauto eth2", false, 0},
 		{"iface eth1 inet manual", false, 1},
+		{"iface eth1 inet dhcp\niface eth1 inet6 auto", false, 1},
+		{"iface eth1 inet6 static\naddress 2001:db8::2", true, -1},
 	} {
 		interfaces, err := ProcessDebianNetconf([]byte(tt.in))
 		failed := err != nil
@@ -54,3 +56,39 @@ func TestProcessDebianNetconf(t *testing.T) {
 		}
 	}
 }
+
+func TestProcessDebianNetconfInet6(t *testing.T) {
+	interfaces, err := ProcessDebianNetconf([]byte(`auto eth0
+iface eth0 inet static
+	address 192.168.1.10
+	netmask 255.255.255.0
+	gateway 192.168.1.1
+iface eth0 inet6 static
+	address 2001:db8::10
+	netmask 64
+	gateway 2001:db8::1
+	accept_ra 0
+
+iface eth1 inet dhcp
+iface eth1 inet6 dhcp
+
+iface eth2 inet6 auto
+`))
+	if err != nil {
+		t.Fatalf("bad error: want %v, got %v", nil, err)
+	}
+
+	expect := []string{
+		"[Match]\nName=eth0\n\n[Network]\nIPv6AcceptRA=no\n\n[Address]\nAddress=192.168.1.10/24\n\n[Address]\nAddress=2001:db8::10/64\n\n[Route]\nDestination=0.0.0.0/0\nGateway=192.168.1.1\n\n[Route]\nDestination=::/0\nGateway=2001:db8::1\n",
+		"[Match]\nName=eth1\n\n[Network]\nDHCP=yes\n",
+		"[Match]\nName=eth2\n\n[Network]\nIPv6AcceptRA=yes\n",
+	}
+	if len(interfaces) != len(expect) {
+		t.Fatalf("bad number of interfaces: want %d, got %d", len(expect), len(interfaces))
+	}
+	for i, iface := range interfaces {
+		if network := iface.Network(); network != expect[i] {
+			t.Errorf("bad network (#%d): want %q, got %q", i, expect[i], network)
+		}
+	}
+}
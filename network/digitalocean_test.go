@@ -180,8 +180,8 @@ func TestParseInterface(t *testing.T) {
 					}},
 					nameservers: []net.IP{},
 					routes: []route{route{
-						net.IPNet{IP: net.IPv4zero, Mask: net.IPMask(net.IPv4zero)},
-						net.ParseIP("5.6.7.8"),
+						destination: net.IPNet{IP: net.IPv4zero, Mask: net.IPMask(net.IPv4zero)},
+						gateway:     net.ParseIP("5.6.7.8"),
 					}},
 				},
 			},
@@ -252,8 +252,8 @@ func TestParseInterface(t *testing.T) {
 					}},
 					nameservers: []net.IP{},
 					routes: []route{route{
-						net.IPNet{IP: net.IPv6zero, Mask: net.IPMask(net.IPv6zero)},
-						net.ParseIP("fe00:1234::"),
+						destination: net.IPNet{IP: net.IPv6zero, Mask: net.IPMask(net.IPv6zero)},
+						gateway:     net.ParseIP("fe00:1234::"),
 					}},
 				},
 			},
@@ -315,9 +315,6 @@ func TestParseInterface(t *testing.T) {
 							destination: net.IPNet{IP: net.IPv4zero, Mask: net.IPMask(net.IPv4zero)},
 							gateway:     net.ParseIP("5.6.7.8"),
 						},
-						{
-							destination: net.IPNet{IP: net.IPv4zero, Mask: net.IPMask(net.IPv4zero)},
-						},
 					},
 				},
 			},
@@ -470,6 +467,37 @@ func TestProcessDigitalOceanNetconf(t *testing.T) {
 	}
 }
 
+func TestProcessDigitalOceanNetconfRendering(t *testing.T) {
+	ifaces, err := ProcessDigitalOceanNetconf(digitalocean.Metadata{
+		Interfaces: digitalocean.Interfaces{
+			Public: []digitalocean.Interface{
+				{
+					IPv4:       &digitalocean.Address{IPAddress: "192.0.2.5", Netmask: "255.255.255.0", Gateway: "192.0.2.1"},
+					IPv6:       &digitalocean.Address{IPAddress: "2604:a880:800:10::7a1:1", Cidr: 64, Gateway: "2604:a880:800:10::1"},
+					AnchorIPv4: &digitalocean.Address{IPAddress: "10.17.0.5", Netmask: "255.255.0.0", Gateway: "10.17.0.1"},
+					MAC:        "04:01:2a:0f:3b:01",
+				},
+			},
+		},
+	})
+	if err != nil {
+		t.Fatalf("bad error: want %v, got %v", nil, err)
+	}
+
+	network := "[Match]\nMACAddress=04:01:2a:0f:3b:01\n\n[Network]\n" +
+		"\n[Address]\nAddress=192.0.2.5/24\n" +
+		"\n[Address]\nAddress=2604:a880:800:10::7a1:1/64\n" +
+		"\n[Address]\nAddress=10.17.0.5/16\n" +
+		"\n[Route]\nDestination=0.0.0.0/0\nGateway=192.0.2.1\n" +
+		"\n[Route]\nDestination=::/0\nGateway=2604:a880:800:10::1\n"
+	if len(ifaces) != 1 {
+		t.Fatalf("bad number of interfaces: want %d, got %d", 1, len(ifaces))
+	}
+	if n := ifaces[0].Network(); n != network {
+		t.Fatalf("bad network: want %q, got %q", network, n)
+	}
+}
+
 func errorsEqual(a, b error) bool {
 	if a == nil && b == nil {
 		return true
@@ -0,0 +1,49 @@
+// Copyright 2015 CoreOS, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package network
+
+// DNS returns the nameservers and search domains of interfaces, in order and
+// without duplicates, for systems which do not take them from the config of
+// every interface.
+func DNS(interfaces []InterfaceGenerator) (nameservers, domains []string) {
+	seen := make(map[string]bool)
+	add := func(list []string, value string) []string {
+		if seen[value] {
+			return list
+		}
+		seen[value] = true
+		return append(list, value)
+	}
+	for _, iface := range interfaces {
+		i := logicalOf(iface)
+		if i == nil {
+			continue
+		}
+		switch c := i.config.(type) {
+		case configMethodStatic:
+			for _, nameserver := range c.nameservers {
+				nameservers = add(nameservers, nameserver.String())
+			}
+			for _, domain := range c.domains {
+				domains = add(domains, domain)
+			}
+		case configMethodDHCP:
+			for _, domain := range c.domains {
+				domains = add(domains, domain)
+			}
+		}
+	}
+	return
+}
@@ -0,0 +1,42 @@
+// Copyright 2015 CoreOS, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package network
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestDNS(t *testing.T) {
+	interfaces, err := ProcessDebianNetconf([]byte(debianNetconf + `
+auto eth4
+iface eth4 inet static
+    address 10.0.0.2
+    netmask 255.0.0.0
+    dns-nameservers 8.8.8.8 8.8.4.4
+    dns-search example.org
+`))
+	if err != nil {
+		t.Fatalf("bad error: want %v, got %v", nil, err)
+	}
+
+	nameservers, domains := DNS(interfaces)
+	if expect := []string{"192.168.1.2", "8.8.8.8", "8.8.4.4"}; !reflect.DeepEqual(expect, nameservers) {
+		t.Fatalf("bad nameservers: want %q, got %q", expect, nameservers)
+	}
+	if expect := []string{"example.com", "example.org"}; !reflect.DeepEqual(expect, domains) {
+		t.Fatalf("bad domains: want %q, got %q", expect, domains)
+	}
+}
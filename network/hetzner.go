@@ -0,0 +1,105 @@
+// Copyright 2015 CoreOS, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package network
+
+import (
+	"fmt"
+	"log"
+	"net"
+
+	"github.com/coreos/coreos-cloudinit/datasource/metadata/hetzner"
+)
+
+func ProcessHetznerNetconf(config hetzner.NetworkConfig) ([]InterfaceGenerator, error) {
+	log.Println("Processing Hetzner network config")
+
+	generators := make([]InterfaceGenerator, 0, len(config.Config))
+	for _, iface := range config.Config {
+		if iface.Type != "physical" {
+			log.Printf("Skipping interface %q of unsupported type %q\n", iface.Name, iface.Type)
+			continue
+		}
+		generator, err := parseHetznerInterface(iface)
+		if err != nil {
+			return nil, err
+		}
+		generators = append(generators, &physicalInterface{*generator})
+	}
+	log.Printf("Parsed %d network interfaces\n", len(generators))
+
+	log.Println("Processed Hetzner network config")
+	return generators, nil
+}
+
+func parseHetznerInterface(iface hetzner.Interface) (*logicalInterface, error) {
+	config := configMethodStatic{
+		addresses:   []net.IPNet{},
+		nameservers: []net.IP{},
+		routes:      []route{},
+	}
+
+	var dhcp4, dhcp6 bool
+	for _, subnet := range iface.Subnets {
+		for _, ns := range subnet.DNSNameservers {
+			ip := net.ParseIP(ns)
+			if ip == nil {
+				return nil, fmt.Errorf("could not parse %q as nameserver IP address", ns)
+			}
+			config.nameservers = append(config.nameservers, ip)
+		}
+
+		switch subnet.Type {
+		case "dhcp", "dhcp4":
+			dhcp4 = true
+		case "dhcp6":
+			dhcp6 = true
+		case "static", "static6":
+			ip, network, err := net.ParseCIDR(subnet.Address)
+			if err != nil {
+				return nil, fmt.Errorf("could not parse %q as address: %v", subnet.Address, err)
+			}
+			config.addresses = append(config.addresses, net.IPNet{IP: ip, Mask: network.Mask})
+
+			if subnet.Gateway != "" {
+				gateway := net.ParseIP(subnet.Gateway)
+				if gateway == nil {
+					return nil, fmt.Errorf("could not parse %q as gateway", subnet.Gateway)
+				}
+				config.routes = append(config.routes, route{
+					destination: defaultDestination(ip),
+					gateway:     gateway,
+				})
+			}
+		default:
+			return nil, fmt.Errorf("unsupported subnet type %q", subnet.Type)
+		}
+	}
+	config.dhcp = dhcpMode(dhcp4, dhcp6)
+
+	var hwaddr net.HardwareAddr
+	if iface.MACAddress != "" {
+		var err error
+		if hwaddr, err = net.ParseMAC(iface.MACAddress); err != nil {
+			return nil, err
+		}
+	}
+
+	return &logicalInterface{
+		name:     iface.Name,
+		hwaddr:   hwaddr,
+		config:   config,
+		children: []networkInterface{},
+	}, nil
+}
@@ -0,0 +1,92 @@
+// Copyright 2015 CoreOS, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package network
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/coreos/coreos-cloudinit/datasource/metadata/hetzner"
+)
+
+func TestProcessHetznerNetconf(t *testing.T) {
+	for i, tt := range []struct {
+		config   hetzner.NetworkConfig
+		networks []string
+		err      error
+	}{
+		{
+			config: hetzner.NetworkConfig{
+				Version: 1,
+				Config: []hetzner.Interface{
+					{
+						Type:       "physical",
+						Name:       "eth0",
+						MACAddress: "96:00:00:00:00:01",
+						Subnets: []hetzner.Subnet{
+							{Type: "dhcp", DNSNameservers: []string{"185.12.64.1"}},
+							{Type: "static", Address: "2a01:4f8:c17:1::1/64", Gateway: "fe80::1"},
+						},
+					},
+					{Type: "nameserver"},
+				},
+			},
+			networks: []string{"[Match]\nName=eth0\nMACAddress=96:00:00:00:00:01\n\n[Network]\nDHCP=ipv4\nDNS=185.12.64.1\n\n[Address]\nAddress=2a01:4f8:c17:1::1/64\n\n[Route]\nDestination=::/0\nGateway=fe80::1\n"},
+		},
+		{
+			config: hetzner.NetworkConfig{
+				Config: []hetzner.Interface{
+					{Type: "physical", Name: "eth0", Subnets: []hetzner.Subnet{{Type: "dhcp4"}, {Type: "dhcp6"}}},
+				},
+			},
+			networks: []string{"[Match]\nName=eth0\n\n[Network]\nDHCP=yes\n"},
+		},
+		{
+			config: hetzner.NetworkConfig{
+				Config: []hetzner.Interface{
+					{Type: "physical", Name: "eth0", Subnets: []hetzner.Subnet{{Type: "ipv6_slaac"}}},
+				},
+			},
+			err: errors.New(`unsupported subnet type "ipv6_slaac"`),
+		},
+		{
+			config: hetzner.NetworkConfig{
+				Config: []hetzner.Interface{
+					{Type: "physical", Name: "eth0", Subnets: []hetzner.Subnet{{Type: "static", Address: "bad"}}},
+				},
+			},
+			err: errors.New(`could not parse "bad" as address: invalid CIDR address: bad`),
+		},
+	} {
+		interfaces, err := ProcessHetznerNetconf(tt.config)
+		if !errorsEqual(tt.err, err) {
+			t.Errorf("bad error (#%d): want %v, got %v", i, tt.err, err)
+			continue
+		}
+		var networks []string
+		for _, iface := range interfaces {
+			networks = append(networks, iface.Network())
+		}
+		if len(networks) != len(tt.networks) {
+			t.Errorf("bad number of interfaces (#%d): want %d, got %d", i, len(tt.networks), len(networks))
+			continue
+		}
+		for j := range networks {
+			if networks[j] != tt.networks[j] {
+				t.Errorf("bad network (#%d, %d): want %q, got %q", i, j, tt.networks[j], networks[j])
+			}
+		}
+	}
+}
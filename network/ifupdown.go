@@ -0,0 +1,282 @@
+// Copyright 2015 CoreOS, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package network
+
+import (
+	"fmt"
+	"log"
+	"net"
+	"strings"
+)
+
+// ifupdownBridgeOptions maps the networkd [Bridge] options to ifupdown.
+var ifupdownBridgeOptions = map[string]string{
+	"STP":             "bridge_stp",
+	"ForwardDelaySec": "bridge_fd",
+	"Priority":        "bridge_bridgeprio",
+}
+
+// RenderDebianNetconf renders interfaces as a Debian network config, which
+// ProcessDebianNetconf parses back. Every interface is brought up at boot.
+// Interfaces without a name, which ifupdown cannot match, are skipped.
+func RenderDebianNetconf(interfaces []InterfaceGenerator) string {
+	parents := make(map[string]string)
+	for _, iface := range interfaces {
+		if ni, ok := iface.(networkInterface); ok {
+			for _, child := range ni.Children() {
+				if _, ok := child.(*vlanInterface); ok {
+					parents[child.Name()] = iface.Name()
+				}
+			}
+		}
+	}
+
+	var stanzas []string
+	for _, iface := range interfaces {
+		i := logicalOf(iface)
+		if i == nil {
+			continue
+		}
+		if i.name == "" || i.name == "*" {
+			log.Printf("Skipping interface %q, which ifupdown cannot match\n", iface.Filename())
+			continue
+		}
+
+		var options []string
+		switch t := iface.(type) {
+		case *bondInterface:
+			options = append(options, fmt.Sprintf("bond-slaves %s", strings.Join(t.slaves, " ")))
+			for _, name := range sortedKeys(t.options) {
+				if option, ok := kernelBondOptions[name]; ok {
+					options = append(options, fmt.Sprintf("bond-%s %s", strings.Replace(option.name, "_", "-", -1), kernelBondValue(t.options[name], option.duration)))
+				}
+			}
+		case *bridgeInterface:
+			ports := "none"
+			if len(t.ports) > 0 {
+				ports = strings.Join(t.ports, " ")
+			}
+			options = append(options, fmt.Sprintf("bridge_ports %s", ports))
+			for _, name := range sortedKeys(t.options) {
+				if option, ok := ifupdownBridgeOptions[name]; ok {
+					value := t.options[name]
+					if option == "bridge_stp" {
+						value = map[bool]string{true: "on", false: "off"}[value == "yes"]
+					}
+					options = append(options, fmt.Sprintf("%s %s", option, value))
+				}
+			}
+		case *vlanInterface:
+			parent := t.rawDevice
+			if p, ok := parents[i.name]; ok {
+				parent = p
+			}
+			if parent != "" {
+				options = append(options, fmt.Sprintf("vlan_raw_device %s", parent))
+			}
+		}
+
+		hwaddr := i.hwaddr
+		if _, ok := iface.(*physicalInterface); ok {
+			// The MAC address of a physical interface only matches it.
+			hwaddr = nil
+		}
+		stanzas = append(stanzas, fmt.Sprintf("auto %s\n", i.name)+ifupdownStanzas(i.name, i.config, hwaddr, options))
+	}
+	return strings.Join(stanzas, "\n")
+}
+
+// ifupdownStanzas renders the inet and inet6 stanzas of an interface. Options
+// common to both families go to the first stanza, along with the given
+// options, and every address but the first of each family to its own stanza.
+func ifupdownStanzas(name string, conf configMethod, hwaddr net.HardwareAddr, options []string) string {
+	var static configMethodStatic
+	switch c := conf.(type) {
+	case configMethodStatic:
+		static = c
+	case configMethodDHCP:
+		static = configMethodStatic{
+			hwaddress:   c.hwaddress,
+			domains:     c.domains,
+			mtu:         c.mtu,
+			dhcpOptions: c.dhcpOptions,
+			dhcp:        "ipv4",
+		}
+	default:
+		return ifupdownStanza(name, "inet", "manual", options)
+	}
+
+	if static.hwaddress != nil {
+		hwaddr = static.hwaddress
+	}
+	if hwaddr != nil {
+		options = append(options, fmt.Sprintf("hwaddress ether %s", hwaddr))
+	}
+	if static.mtu != 0 {
+		options = append(options, fmt.Sprintf("mtu %d", static.mtu))
+	}
+	if len(static.nameservers) > 0 {
+		var nameservers []string
+		for _, nameserver := range static.nameservers {
+			nameservers = append(nameservers, nameserver.String())
+		}
+		options = append(options, fmt.Sprintf("dns-nameservers %s", strings.Join(nameservers, " ")))
+	}
+	if len(static.domains) > 0 {
+		options = append(options, fmt.Sprintf("dns-search %s", strings.Join(static.domains, " ")))
+	}
+
+	var v4, v6 []net.IPNet
+	for _, address := range static.addresses {
+		if address.IP.To4() != nil {
+			v4 = append(v4, address)
+		} else {
+			v6 = append(v6, address)
+		}
+	}
+	var gateway4, gateway6 *route
+	var postUp []string
+	for n, r := range static.routes {
+		ones, _ := r.destination.Mask.Size()
+		v4 := r.destination.IP.To4() != nil
+		switch {
+		case ones == 0 && r.gateway != nil && !r.onLink && v4 && gateway4 == nil:
+			gateway4 = &static.routes[n]
+		case ones == 0 && r.gateway != nil && !r.onLink && !v4 && gateway6 == nil:
+			gateway6 = &static.routes[n]
+		default:
+			command := "post-up ip"
+			if !v4 {
+				command += " -6"
+			}
+			command += fmt.Sprintf(" route add %s", r.destination.String())
+			if r.gateway != nil {
+				command += fmt.Sprintf(" via %s", r.gateway)
+			}
+			command += fmt.Sprintf(" dev %s", name)
+			if r.onLink {
+				command += " onlink"
+			}
+			if r.metric != 0 {
+				command += fmt.Sprintf(" metric %d", r.metric)
+			}
+			postUp = append(postUp, command)
+		}
+	}
+
+	dhcp4, dhcp6 := dhcpFamilies(static.dhcp)
+	var stanzas []string
+	appendStanza := func(family, method string, stanzaOptions ...string) {
+		if len(stanzas) == 0 {
+			stanzaOptions = append(stanzaOptions, options...)
+		}
+		stanzas = append(stanzas, ifupdownStanza(name, family, method, stanzaOptions))
+	}
+
+	switch {
+	case dhcp4:
+		var dhcpOptions []string
+		if static.dhcpOptions.routeMetric != 0 {
+			dhcpOptions = append(dhcpOptions, fmt.Sprintf("metric %d", static.dhcpOptions.routeMetric))
+		}
+		appendStanza("inet", "dhcp", dhcpOptions...)
+		if gateway4 != nil {
+			postUp = append([]string{fmt.Sprintf("post-up ip route add default via %s dev %s", gateway4.gateway, name)}, postUp...)
+		}
+	case len(v4) > 0:
+		addressOptions := []string{fmt.Sprintf("address %s", v4[0].IP), fmt.Sprintf("netmask %s", ifupdownNetmask(v4[0].Mask))}
+		if gateway4 != nil {
+			addressOptions = append(addressOptions, fmt.Sprintf("gateway %s", gateway4.gateway))
+			if gateway4.metric != 0 {
+				addressOptions = append(addressOptions, fmt.Sprintf("metric %d", gateway4.metric))
+			}
+		}
+		appendStanza("inet", "static", append(addressOptions, postUp...)...)
+		postUp = nil
+		v4 = v4[1:]
+	}
+	for _, address := range v4 {
+		appendStanza("inet", "static", fmt.Sprintf("address %s", address.IP), fmt.Sprintf("netmask %s", ifupdownNetmask(address.Mask)))
+	}
+
+	var acceptRA []string
+	switch static.acceptRA {
+	case "yes":
+		acceptRA = []string{"accept_ra 1"}
+	case "no":
+		acceptRA = []string{"accept_ra 0"}
+	}
+	switch {
+	case len(v6) > 0:
+		addressOptions := []string{fmt.Sprintf("address %s", v6[0].String())}
+		if gateway6 != nil {
+			addressOptions = append(addressOptions, fmt.Sprintf("gateway %s", gateway6.gateway))
+			if gateway6.metric != 0 {
+				addressOptions = append(addressOptions, fmt.Sprintf("metric %d", gateway6.metric))
+			}
+		}
+		appendStanza("inet6", "static", append(addressOptions, acceptRA...)...)
+		v6 = v6[1:]
+		gateway6 = nil
+		if dhcp6 {
+			log.Printf("Ignoring DHCPv6 on %q, which has static IPv6 addresses\n", name)
+		}
+	case dhcp6 && static.acceptRA == "yes":
+		appendStanza("inet6", "auto", "dhcp 1")
+	case dhcp6:
+		appendStanza("inet6", "dhcp", acceptRA...)
+	case static.acceptRA == "yes":
+		appendStanza("inet6", "auto")
+	}
+	for _, address := range v6 {
+		appendStanza("inet6", "static", fmt.Sprintf("address %s", address.String()))
+	}
+	if gateway6 != nil {
+		command := fmt.Sprintf("post-up ip -6 route add default via %s dev %s", gateway6.gateway, name)
+		if gateway6.metric != 0 {
+			command += fmt.Sprintf(" metric %d", gateway6.metric)
+		}
+		postUp = append(postUp, command)
+	}
+
+	if len(stanzas) == 0 {
+		appendStanza("inet", "manual")
+	}
+	if len(postUp) > 0 {
+		// Routes which are not attached to an IPv4 address are added once
+		// the interface is up.
+		stanzas[0] += ifupdownOptions(postUp)
+	}
+	return strings.Join(stanzas, "")
+}
+
+func ifupdownStanza(name, family, method string, options []string) string {
+	return fmt.Sprintf("iface %s %s %s\n", name, family, method) + ifupdownOptions(options)
+}
+
+func ifupdownOptions(options []string) (config string) {
+	for _, option := range options {
+		config += fmt.Sprintf("    %s\n", option)
+	}
+	return
+}
+
+// ifupdownNetmask returns the dotted form of an IPv4 netmask.
+func ifupdownNetmask(mask net.IPMask) string {
+	if len(mask) == net.IPv6len {
+		mask = mask[12:]
+	}
+	return net.IP(mask).String()
+}
@@ -0,0 +1,173 @@
+// Copyright 2015 CoreOS, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package network
+
+import (
+	"net"
+	"testing"
+)
+
+const debianNetconf = `auto lo
+iface lo inet loopback
+
+auto eth0
+iface eth0 inet static
+    address 192.168.1.10
+    netmask 255.255.255.0
+    gateway 192.168.1.1
+    metric 100
+    mtu 9000
+    dns-nameservers 192.168.1.2 8.8.8.8
+    dns-search example.com
+    post-up route add -net 10.0.0.0 netmask 255.0.0.0 gw 192.168.1.254
+iface eth0 inet static
+    address 192.168.2.10
+    netmask 255.255.255.0
+iface eth0 inet6 static
+    address 2001:db8::10/64
+    gateway 2001:db8::1
+    accept_ra 0
+
+auto bond0
+iface bond0 inet dhcp
+    bond-slaves eth1 eth2
+    bond-mode 4
+    bond-miimon 100
+    bond-lacp-rate fast
+    hwaddress ether 52:54:00:12:34:56
+    metric 200
+iface bond0 inet6 auto
+    dhcp 1
+
+auto bond0.10
+iface bond0.10 inet static
+    address 172.16.0.2
+    netmask 255.255.255.0
+    vlan_raw_device bond0
+
+auto br0
+iface br0 inet manual
+    bridge_ports eth3
+    bridge_stp off
+    bridge_fd 0
+`
+
+func TestRenderDebianNetconf(t *testing.T) {
+	interfaces, err := ProcessDebianNetconf([]byte(debianNetconf))
+	if err != nil {
+		t.Fatalf("bad error: want %v, got %v", nil, err)
+	}
+
+	expect := `auto bond0
+iface bond0 inet dhcp
+    metric 200
+    bond-slaves eth1 eth2
+    bond-lacp-rate fast
+    bond-miimon 100
+    bond-mode 4
+    hwaddress ether 52:54:00:12:34:56
+iface bond0 inet6 auto
+    dhcp 1
+
+auto bond0.10
+iface bond0.10 inet static
+    address 172.16.0.2
+    netmask 255.255.255.0
+    vlan_raw_device bond0
+
+auto br0
+iface br0 inet manual
+    bridge_ports eth3
+    bridge_fd 0
+    bridge_stp off
+
+auto eth0
+iface eth0 inet static
+    address 192.168.1.10
+    netmask 255.255.255.0
+    gateway 192.168.1.1
+    metric 100
+    post-up ip route add 10.0.0.0/8 via 192.168.1.254 dev eth0
+    mtu 9000
+    dns-nameservers 192.168.1.2 8.8.8.8
+    dns-search example.com
+iface eth0 inet static
+    address 192.168.2.10
+    netmask 255.255.255.0
+iface eth0 inet6 static
+    address 2001:db8::10/64
+    gateway 2001:db8::1
+    accept_ra 0
+
+auto eth1
+iface eth1 inet manual
+
+auto eth2
+iface eth2 inet manual
+
+auto eth3
+iface eth3 inet manual
+`
+	if config := RenderDebianNetconf(interfaces); config != expect {
+		t.Fatalf("bad config: want %q, got %q", expect, config)
+	}
+}
+
+func TestRenderDebianNetconfRoundTrip(t *testing.T) {
+	interfaces, err := ProcessDebianNetconf([]byte(debianNetconf))
+	if err != nil {
+		t.Fatalf("bad error: want %v, got %v", nil, err)
+	}
+	config := RenderDebianNetconf(interfaces)
+	parsed, err := ProcessDebianNetconf([]byte(config))
+	if err != nil {
+		t.Fatalf("bad error parsing %q: want %v, got %v", config, nil, err)
+	}
+
+	expect := make([]expectedInterface, len(interfaces))
+	for i, iface := range interfaces {
+		expect[i] = expectedInterface{iface.Filename(), iface.Netdev(), iface.Link(), iface.Network()}
+	}
+	checkInterfaces(t, "round trip", parsed, expect)
+}
+
+func TestRenderDebianNetconfNetworkConfig(t *testing.T) {
+	interfaces := []InterfaceGenerator{
+		&physicalInterface{logicalInterface{
+			name: "eth0",
+			config: configMethodStatic{
+				addresses: []net.IPNet{{IP: net.ParseIP("192.0.2.10"), Mask: net.CIDRMask(120, 128)}},
+				routes: []route{
+					{destination: net.IPNet{IP: net.ParseIP("198.51.100.0"), Mask: net.CIDRMask(24, 32)}, metric: 10},
+					{destination: net.IPNet{IP: net.IPv6zero, Mask: net.CIDRMask(0, 128)}, gateway: net.ParseIP("2001:db8::1")},
+				},
+				dhcp: "ipv6",
+			},
+		}},
+		&physicalInterface{logicalInterface{name: "", hwaddr: net.HardwareAddr{0, 1, 2, 3, 4, 5}, config: configMethodDHCP{}}},
+	}
+
+	expect := `auto eth0
+iface eth0 inet static
+    address 192.0.2.10
+    netmask 255.255.255.0
+    post-up ip route add 198.51.100.0/24 dev eth0 metric 10
+    post-up ip -6 route add default via 2001:db8::1 dev eth0
+iface eth0 inet6 dhcp
+`
+	if config := RenderDebianNetconf(interfaces); config != expect {
+		t.Fatalf("bad config: want %q, got %q", expect, config)
+	}
+}
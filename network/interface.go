@@ -66,22 +66,66 @@ func (i *logicalInterface) Network() string {
 			config += fmt.Sprintf("VLAN=%s\n", iface.name)
 		case *bondInterface:
 			config += fmt.Sprintf("Bond=%s\n", iface.name)
+		case *bridgeInterface:
+			config += fmt.Sprintf("Bridge=%s\n", iface.name)
 		}
 	}
 
+	var mtu int
+	var options dhcpOptions
 	switch conf := i.config.(type) {
 	case configMethodStatic:
+		if conf.dhcp != "" {
+			config += fmt.Sprintf("DHCP=%s\n", conf.dhcp)
+		}
+		if conf.acceptRA != "" {
+			config += fmt.Sprintf("IPv6AcceptRA=%s\n", conf.acceptRA)
+		}
 		for _, nameserver := range conf.nameservers {
 			config += fmt.Sprintf("DNS=%s\n", nameserver)
 		}
+		if len(conf.domains) > 0 {
+			config += fmt.Sprintf("Domains=%s\n", strings.Join(conf.domains, " "))
+		}
 		for _, addr := range conf.addresses {
 			config += fmt.Sprintf("\n[Address]\nAddress=%s\n", addr.String())
 		}
 		for _, route := range conf.routes {
-			config += fmt.Sprintf("\n[Route]\nDestination=%s\nGateway=%s\n", route.destination.String(), route.gateway)
+			config += fmt.Sprintf("\n[Route]\nDestination=%s\n", route.destination.String())
+			if route.gateway != nil {
+				config += fmt.Sprintf("Gateway=%s\n", route.gateway)
+			}
+			if route.onLink {
+				config += "GatewayOnLink=yes\n"
+			}
+			if route.metric != 0 {
+				config += fmt.Sprintf("Metric=%d\n", route.metric)
+			}
 		}
+		mtu, options = conf.mtu, conf.dhcpOptions
 	case configMethodDHCP:
-		config += "DHCP=true\n"
+		// DHCP configs are IPv4 only; IPv6 DHCP is set on static configs.
+		config += fmt.Sprintf("DHCP=%s\n", dhcpMode(true, false))
+		if len(conf.domains) > 0 {
+			config += fmt.Sprintf("Domains=%s\n", strings.Join(conf.domains, " "))
+		}
+		mtu, options = conf.mtu, conf.dhcpOptions
+	}
+
+	if options != (dhcpOptions{}) {
+		config += "\n[DHCP]\n"
+		if options.useDNS != "" {
+			config += fmt.Sprintf("UseDNS=%s\n", options.useDNS)
+		}
+		if options.useHostname != "" {
+			config += fmt.Sprintf("UseHostname=%s\n", options.useHostname)
+		}
+		if options.routeMetric != 0 {
+			config += fmt.Sprintf("RouteMetric=%d\n", options.routeMetric)
+		}
+	}
+	if mtu != 0 {
+		config += fmt.Sprintf("\n[Link]\nMTUBytes=%d\n", mtu)
 	}
 
 	return config
@@ -123,6 +167,27 @@ func (p *physicalInterface) Type() string {
 	return "physical"
 }
 
+// Link names the interface with the given MAC address, so that it has the
+// same name whatever the naming scheme of the system.
+func (p *physicalInterface) Link() string {
+	if p.name == "" || p.hwaddr == nil {
+		return ""
+	}
+	return fmt.Sprintf("[Match]\nMACAddress=%s\n\n[Link]\nName=%s\n", p.hwaddr, p.name)
+}
+
+// Renames returns the names the .link units of interfaces give to the
+// interfaces with each MAC address.
+func Renames(interfaces []InterfaceGenerator) map[string]string {
+	renames := make(map[string]string)
+	for _, iface := range interfaces {
+		if p, ok := iface.(*physicalInterface); ok && p.Link() != "" {
+			renames[p.hwaddr.String()] = p.name
+		}
+	}
+	return renames
+}
+
 type bondInterface struct {
 	logicalInterface
 	slaves  []string
@@ -182,6 +247,32 @@ func (v *vlanInterface) Type() string {
 	return "vlan"
 }
 
+type bridgeInterface struct {
+	logicalInterface
+	ports   []string
+	options map[string]string
+}
+
+func (b *bridgeInterface) Netdev() string {
+	config := fmt.Sprintf("[NetDev]\nKind=bridge\nName=%s\n", b.name)
+	if b.hwaddr != nil {
+		config += fmt.Sprintf("MACAddress=%s\n", b.hwaddr.String())
+	}
+
+	if len(b.options) > 0 {
+		config += "\n[Bridge]\n"
+		for _, name := range sortedKeys(b.options) {
+			config += fmt.Sprintf("%s=%s\n", name, b.options[name])
+		}
+	}
+
+	return config
+}
+
+func (b *bridgeInterface) Type() string {
+	return "bridge"
+}
+
 func buildInterfaces(stanzas []*stanzaInterface) []InterfaceGenerator {
 	interfaceMap := createInterfaces(stanzas)
 	linkAncestors(interfaceMap)
@@ -227,6 +318,40 @@ func createInterfaces(stanzas []*stanzaInterface) map[string]networkInterface {
 				}
 			}
 
+		case interfaceBridge:
+			var ports []string
+			for _, port := range iface.options["bridge_ports"] {
+				if port != "none" {
+					ports = append(ports, port)
+				}
+			}
+			params := make(map[string]string)
+			for _, k := range []string{"bridge_stp", "bridge_fd", "bridge_bridgeprio"} {
+				if v, ok := iface.options[k]; ok && len(v) > 0 {
+					params[k] = v[0]
+				}
+			}
+			interfaceMap[iface.name] = &bridgeInterface{
+				logicalInterface{
+					name:     iface.name,
+					config:   iface.configMethod,
+					children: []networkInterface{},
+				},
+				ports,
+				parseBridgeParameters(params),
+			}
+			for _, port := range ports {
+				if _, ok := interfaceMap[port]; !ok {
+					interfaceMap[port] = &physicalInterface{
+						logicalInterface{
+							name:     port,
+							config:   configMethodManual{},
+							children: []networkInterface{},
+						},
+					}
+				}
+			}
+
 		case interfacePhysical:
 			if _, ok := iface.configMethod.(configMethodLoopback); ok {
 				continue
@@ -274,28 +399,37 @@ func linkAncestors(interfaceMap map[string]networkInterface) {
 		switch i := iface.(type) {
 		case *vlanInterface:
 			if parent, ok := interfaceMap[i.rawDevice]; ok {
-				switch p := parent.(type) {
-				case *physicalInterface:
-					p.children = append(p.children, iface)
-				case *bondInterface:
-					p.children = append(p.children, iface)
-				}
+				addChild(parent, iface)
 			}
 		case *bondInterface:
 			for _, slave := range i.slaves {
 				if parent, ok := interfaceMap[slave]; ok {
-					switch p := parent.(type) {
-					case *physicalInterface:
-						p.children = append(p.children, iface)
-					case *bondInterface:
-						p.children = append(p.children, iface)
-					}
+					addChild(parent, iface)
+				}
+			}
+		case *bridgeInterface:
+			for _, port := range i.ports {
+				if parent, ok := interfaceMap[port]; ok {
+					addChild(parent, iface)
 				}
 			}
 		}
 	}
 }
 
+func addChild(parent, child networkInterface) {
+	switch p := parent.(type) {
+	case *physicalInterface:
+		p.children = append(p.children, child)
+	case *bondInterface:
+		p.children = append(p.children, child)
+	case *vlanInterface:
+		p.children = append(p.children, child)
+	case *bridgeInterface:
+		p.children = append(p.children, child)
+	}
+}
+
 func markConfigDepths(interfaceMap map[string]networkInterface) {
 	rootInterfaceMap := make(map[string]networkInterface)
 	for k, v := range interfaceMap {
@@ -308,14 +442,22 @@ func markConfigDepths(interfaceMap map[string]networkInterface) {
 		}
 	}
 	for _, iface := range rootInterfaceMap {
-		setDepth(iface)
+		setDepth(iface, make(map[networkInterface]bool))
 	}
 }
 
-func setDepth(iface networkInterface) int {
+func setDepth(iface networkInterface, ancestors map[networkInterface]bool) int {
+	// Interfaces which are their own ancestors, which Validate reports, are
+	// not followed around.
+	if ancestors[iface] {
+		return 0
+	}
+	ancestors[iface] = true
+	defer delete(ancestors, iface)
+
 	maxDepth := 0
 	for _, child := range iface.Children() {
-		if depth := setDepth(child); depth > maxDepth {
+		if depth := setDepth(child, ancestors); depth > maxDepth {
 			maxDepth = depth
 		}
 	}
@@ -323,6 +465,43 @@ func setDepth(iface networkInterface) int {
 	return (maxDepth + 1)
 }
 
+// defaultDestination returns the default route destination for the address
+// family of ip.
+func defaultDestination(ip net.IP) net.IPNet {
+	if ip.To4() != nil {
+		return net.IPNet{IP: net.IPv4zero, Mask: net.IPMask(net.IPv4zero)}
+	}
+	return net.IPNet{IP: net.IPv6zero, Mask: net.IPMask(net.IPv6zero)}
+}
+
+// dhcpFamilies returns whether the networkd DHCP= setting mode enables DHCP
+// for IPv4 and IPv6.
+func dhcpFamilies(mode string) (ipv4, ipv6 bool) {
+	switch mode {
+	case "yes", "true":
+		return true, true
+	case "ipv4":
+		return true, false
+	case "ipv6":
+		return false, true
+	}
+	return false, false
+}
+
+// dhcpMode returns the value of the networkd DHCP= setting enabling DHCP for
+// the given address families, or an empty string if neither is enabled.
+func dhcpMode(ipv4, ipv6 bool) string {
+	switch {
+	case ipv4 && ipv6:
+		return "yes"
+	case ipv4:
+		return "ipv4"
+	case ipv6:
+		return "ipv6"
+	}
+	return ""
+}
+
 func sortedKeys(m map[string]string) (keys []string) {
 	for key := range m {
 		keys = append(keys, key)
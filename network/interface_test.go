@@ -37,6 +37,16 @@ func TestInterfaceGenerators(t *testing.T) {
 				hwaddr: net.HardwareAddr([]byte{0, 1, 2, 3, 4, 5}),
 			}},
 		},
+		{
+			name:    "testname",
+			link:    "[Match]\nMACAddress=00:01:02:03:04:05\n\n[Link]\nName=testname\n",
+			network: "[Match]\nName=testname\nMACAddress=00:01:02:03:04:05\n\n[Network]\n",
+			kind:    "physical",
+			iface: &physicalInterface{logicalInterface{
+				name:   "testname",
+				hwaddr: net.HardwareAddr([]byte{0, 1, 2, 3, 4, 5}),
+			}},
+		},
 		{
 			name:    "testname",
 			network: "[Match]\nName=testname\n\n[Network]\nBond=testbond1\nVLAN=testvlan1\nVLAN=testvlan2\n",
@@ -53,7 +63,7 @@ func TestInterfaceGenerators(t *testing.T) {
 		{
 			name:    "testname",
 			netdev:  "[NetDev]\nKind=bond\nName=testname\n\n[Bond]\n",
-			network: "[Match]\nName=testname\n\n[Network]\nBond=testbond1\nVLAN=testvlan1\nVLAN=testvlan2\nDHCP=true\n",
+			network: "[Match]\nName=testname\n\n[Network]\nBond=testbond1\nVLAN=testvlan1\nVLAN=testvlan2\nDHCP=ipv4\n",
 			kind:    "bond",
 			iface: &bondInterface{logicalInterface: logicalInterface{
 				name:   "testname",
@@ -65,6 +75,35 @@ func TestInterfaceGenerators(t *testing.T) {
 				},
 			}},
 		},
+		{
+			name:    "testname",
+			network: "[Match]\nName=testname\n\n[Network]\nBridge=testbridge1\n",
+			kind:    "physical",
+			iface: &physicalInterface{logicalInterface{
+				name: "testname",
+				children: []networkInterface{
+					&bridgeInterface{logicalInterface: logicalInterface{name: "testbridge1"}},
+				},
+			}},
+		},
+		{
+			name:    "testname",
+			netdev:  "[NetDev]\nKind=bridge\nName=testname\n",
+			network: "[Match]\nName=testname\n\n[Network]\nDHCP=ipv4\n",
+			kind:    "bridge",
+			iface:   &bridgeInterface{logicalInterface{name: "testname", config: configMethodDHCP{}}, []string{"eth0"}, nil},
+		},
+		{
+			name:    "testname",
+			netdev:  "[NetDev]\nKind=bridge\nName=testname\nMACAddress=00:01:02:03:04:05\n\n[Bridge]\nForwardDelaySec=4\nSTP=yes\n",
+			network: "[Match]\nName=testname\nMACAddress=00:01:02:03:04:05\n\n[Network]\n",
+			kind:    "bridge",
+			iface: &bridgeInterface{
+				logicalInterface{name: "testname", hwaddr: net.HardwareAddr([]byte{0, 1, 2, 3, 4, 5})},
+				nil,
+				map[string]string{"STP": "yes", "ForwardDelaySec": "4"},
+			},
+		},
 		{
 			name:    "testname",
 			netdev:  "[NetDev]\nKind=vlan\nName=testname\n\n[VLAN]\nId=1\n",
@@ -82,7 +121,7 @@ func TestInterfaceGenerators(t *testing.T) {
 		{
 			name:    "testname",
 			netdev:  "[NetDev]\nKind=vlan\nName=testname\nMACAddress=00:01:02:03:04:05\n\n[VLAN]\nId=1\n",
-			network: "[Match]\nName=testname\n\n[Network]\nDHCP=true\n",
+			network: "[Match]\nName=testname\n\n[Network]\nDHCP=ipv4\n",
 			kind:    "vlan",
 			iface:   &vlanInterface{logicalInterface{name: "testname", config: configMethodDHCP{hwaddress: net.HardwareAddr([]byte{0, 1, 2, 3, 4, 5})}}, 1, ""},
 		},
@@ -100,6 +139,40 @@ func TestInterfaceGenerators(t *testing.T) {
 				},
 			}},
 		},
+		{
+			name: "testname",
+			network: "[Match]\nName=testname\n\n[Network]\nDHCP=ipv6\nDNS=8.8.8.8\nDomains=example.com example.net\n" +
+				"\n[Address]\nAddress=192.168.1.100/24\n" +
+				"\n[Route]\nDestination=0.0.0.0/0\nGateway=1.2.3.4\nGatewayOnLink=yes\nMetric=100\n" +
+				"\n[DHCP]\nUseDNS=false\nRouteMetric=200\n" +
+				"\n[Link]\nMTUBytes=9000\n",
+			kind: "physical",
+			iface: &physicalInterface{logicalInterface{
+				name: "testname",
+				config: configMethodStatic{
+					addresses:   []net.IPNet{{IP: []byte{192, 168, 1, 100}, Mask: []byte{255, 255, 255, 0}}},
+					nameservers: []net.IP{[]byte{8, 8, 8, 8}},
+					routes:      []route{{destination: net.IPNet{IP: []byte{0, 0, 0, 0}, Mask: []byte{0, 0, 0, 0}}, gateway: []byte{1, 2, 3, 4}, metric: 100, onLink: true}},
+					dhcp:        "ipv6",
+					domains:     []string{"example.com", "example.net"},
+					mtu:         9000,
+					dhcpOptions: dhcpOptions{useDNS: "false", routeMetric: 200},
+				},
+			}},
+		},
+		{
+			name:    "testname",
+			network: "[Match]\nName=testname\n\n[Network]\nDHCP=ipv4\nDomains=example.com\n\n[DHCP]\nUseHostname=false\n\n[Link]\nMTUBytes=1400\n",
+			kind:    "physical",
+			iface: &physicalInterface{logicalInterface{
+				name: "testname",
+				config: configMethodDHCP{
+					domains:     []string{"example.com"},
+					mtu:         1400,
+					dhcpOptions: dhcpOptions{useHostname: "false"},
+				},
+			}},
+		},
 	} {
 		if name := tt.iface.Name(); name != tt.name {
 			t.Fatalf("bad name (%q): want %q, got %q", tt.iface, tt.name, name)
@@ -241,6 +314,78 @@ func TestBuildInterfacesBlindVLAN(t *testing.T) {
 	}
 }
 
+func TestBuildInterfacesBridge(t *testing.T) {
+	stanzas := []*stanzaInterface{
+		{
+			name:         "br0",
+			kind:         interfaceBridge,
+			auto:         false,
+			configMethod: configMethodDHCP{},
+			options: map[string][]string{
+				"bridge_ports":      []string{"eth0", "eth1"},
+				"bridge_stp":        []string{"off"},
+				"bridge_fd":         []string{"0"},
+				"bridge_bridgeprio": []string{"100"},
+				"bridge_maxwait":    []string{"5"},
+			},
+		},
+		{
+			name:         "br1",
+			kind:         interfaceBridge,
+			auto:         false,
+			configMethod: configMethodManual{},
+			options: map[string][]string{
+				"bridge_ports": []string{"none"},
+			},
+		},
+	}
+	interfaces := buildInterfaces(stanzas)
+	br0 := &bridgeInterface{
+		logicalInterface{
+			name:        "br0",
+			config:      configMethodDHCP{},
+			children:    []networkInterface{},
+			configDepth: 0,
+		},
+		[]string{"eth0", "eth1"},
+		map[string]string{
+			"STP":             "no",
+			"ForwardDelaySec": "0",
+			"Priority":        "100",
+		},
+	}
+	br1 := &bridgeInterface{
+		logicalInterface{
+			name:        "br1",
+			config:      configMethodManual{},
+			children:    []networkInterface{},
+			configDepth: 0,
+		},
+		nil,
+		map[string]string{},
+	}
+	eth0 := &physicalInterface{
+		logicalInterface{
+			name:        "eth0",
+			config:      configMethodManual{},
+			children:    []networkInterface{br0},
+			configDepth: 1,
+		},
+	}
+	eth1 := &physicalInterface{
+		logicalInterface{
+			name:        "eth1",
+			config:      configMethodManual{},
+			children:    []networkInterface{br0},
+			configDepth: 1,
+		},
+	}
+	expect := []InterfaceGenerator{br0, br1, eth0, eth1}
+	if !reflect.DeepEqual(interfaces, expect) {
+		t.Fatalf("bad interfaces: want %#v, got %#v", expect, interfaces)
+	}
+}
+
 func TestBuildInterfaces(t *testing.T) {
 	stanzas := []*stanzaInterface{
 		&stanzaInterface{
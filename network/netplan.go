@@ -0,0 +1,547 @@
+// Copyright 2015 CoreOS, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package network
+
+import (
+	"fmt"
+	"log"
+	"net"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/coreos/coreos-cloudinit/config"
+)
+
+// netDevice is a device described by a version 1 or version 2 network
+// config, both of which are parsed into this common form before rendering.
+type netDevice struct {
+	id      string
+	kind    interfaceKind
+	name    string
+	hwaddr  net.HardwareAddr
+	config  configMethodStatic
+	members []string
+	options map[string]string
+	vlanID  int
+	link    string
+}
+
+// bondParameter is the networkd [Bond] option corresponding to a bond
+// parameter. Durations given without a unit are in milliseconds.
+type bondParameter struct {
+	option   string
+	duration bool
+}
+
+// bondParameters maps the bond parameters of version 1 (the ifupdown names,
+// without the "bond-" prefix) and version 2 network configs to networkd.
+var bondParameters = map[string]bondParameter{
+	"mode":                    {"Mode", false},
+	"lacp-rate":               {"LACPTransmitRate", false},
+	"miimon":                  {"MIIMonitorSec", true},
+	"mii-monitor-interval":    {"MIIMonitorSec", true},
+	"updelay":                 {"UpDelaySec", true},
+	"up-delay":                {"UpDelaySec", true},
+	"downdelay":               {"DownDelaySec", true},
+	"down-delay":              {"DownDelaySec", true},
+	"xmit-hash-policy":        {"TransmitHashPolicy", false},
+	"transmit-hash-policy":    {"TransmitHashPolicy", false},
+	"ad-select":               {"AdSelect", false},
+	"min-links":               {"MinLinks", false},
+	"arp-interval":            {"ARPIntervalSec", true},
+	"primary-reselect":        {"PrimaryReselectPolicy", false},
+	"primary-reselect-policy": {"PrimaryReselectPolicy", false},
+	"fail-over-mac":           {"FailOverMACPolicy", false},
+	"fail-over-mac-policy":    {"FailOverMACPolicy", false},
+}
+
+// bridgeParameters maps the bridge parameters of Debian and version 1 (the
+// ifupdown names, without the "bridge_" prefix) and version 2 network configs
+// to networkd [Bridge] options.
+var bridgeParameters = map[string]string{
+	"stp":           "STP",
+	"fd":            "ForwardDelaySec",
+	"forward-delay": "ForwardDelaySec",
+	"bridgeprio":    "Priority",
+	"priority":      "Priority",
+}
+
+// ProcessNetworkConfig renders a network config given in version 1 of the
+// cloud-init network config or in version 2 (netplan).
+func ProcessNetworkConfig(netconf config.Network) ([]InterfaceGenerator, error) {
+	log.Printf("Processing version %d network config\n", netconf.Version)
+
+	var devices []*netDevice
+	var err error
+	switch netconf.Version {
+	case 1:
+		devices, err = parseNetworkConfigV1(netconf.Config)
+	case 2:
+		devices, err = parseNetworkConfigV2(netconf)
+	default:
+		err = fmt.Errorf("unsupported network config version %d", netconf.Version)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	interfaces := buildDevices(devices)
+	log.Printf("Parsed %d network interfaces\n", len(interfaces))
+
+	log.Println("Processed network config")
+	return interfaces, nil
+}
+
+func parseNetworkConfigV1(entries []config.NetworkConfigEntry) ([]*netDevice, error) {
+	var devices []*netDevice
+	var nameservers []net.IP
+	var domains []string
+	var routes []route
+	for _, entry := range entries {
+		switch entry.Type {
+		case "physical", "bond", "bridge", "vlan":
+			device, err := parseDeviceV1(entry)
+			if err != nil {
+				return nil, err
+			}
+			devices = append(devices, device)
+		case "nameserver":
+			ips, err := parseNameserverAddresses(entry.Address)
+			if err != nil {
+				return nil, err
+			}
+			nameservers = append(nameservers, ips...)
+			domains = append(domains, entry.Search...)
+		case "route":
+			r, err := parseRoute(entry.Destination, entry.Gateway)
+			if err != nil {
+				return nil, err
+			}
+			r.metric = entry.Metric
+			routes = append(routes, r)
+		default:
+			log.Printf("Skipping network config entry %q of unsupported type %q\n", entry.Name, entry.Type)
+		}
+	}
+
+	// Global nameservers, search domains and routes apply to the devices
+	// which are configured, routes to the one reaching their gateway.
+	for _, device := range devices {
+		if isConfigured(device.config) {
+			device.config.nameservers = append(device.config.nameservers, nameservers...)
+			device.config.domains = append(device.config.domains, domains...)
+		}
+	}
+	for _, r := range routes {
+		device := gatewayDevice(devices, r.gateway)
+		if device == nil {
+			return nil, fmt.Errorf("no interface to route %s via %s", r.destination.String(), r.gateway)
+		}
+		device.config.routes = append(device.config.routes, r)
+	}
+
+	return devices, nil
+}
+
+func parseDeviceV1(entry config.NetworkConfigEntry) (*netDevice, error) {
+	device := &netDevice{
+		id:     entry.Name,
+		name:   entry.Name,
+		config: newStaticConfig(),
+	}
+	device.config.mtu = entry.MTU
+	if entry.MACAddress != "" {
+		var err error
+		if device.hwaddr, err = net.ParseMAC(entry.MACAddress); err != nil {
+			return nil, err
+		}
+	}
+
+	switch entry.Type {
+	case "physical":
+		device.kind = interfacePhysical
+	case "bond":
+		device.kind = interfaceBond
+		device.members = entry.BondInterfaces
+		params := make(map[string]string, len(entry.Params))
+		for k, v := range entry.Params {
+			k = strings.Replace(k, "_", "-", -1)
+			params[strings.TrimPrefix(k, "bond-")] = v
+		}
+		device.options = parseBondParameters(params)
+	case "bridge":
+		device.kind = interfaceBridge
+		device.members = entry.BridgeInterfaces
+		device.options = parseBridgeParameters(entry.Params)
+	case "vlan":
+		device.kind = interfaceVLAN
+		device.vlanID = entry.VLANID
+		device.link = entry.VLANLink
+	}
+
+	var dhcp4, dhcp6 bool
+	for _, subnet := range entry.Subnets {
+		switch subnet.Type {
+		case "dhcp", "dhcp4":
+			dhcp4 = true
+		case "dhcp6":
+			dhcp6 = true
+		case "static", "static6":
+			address, err := parseAddress(subnet.Address, subnet.Netmask)
+			if err != nil {
+				return nil, fmt.Errorf("could not parse %q as address of %q: %v", subnet.Address, entry.Name, err)
+			}
+			device.config.addresses = append(device.config.addresses, *address)
+
+			if subnet.Gateway != "" {
+				r, err := parseRoute("default", subnet.Gateway)
+				if err != nil {
+					return nil, err
+				}
+				device.config.routes = append(device.config.routes, r)
+			}
+		case "manual":
+		default:
+			return nil, fmt.Errorf("unsupported subnet type %q", subnet.Type)
+		}
+
+		nameservers, err := parseNameserverAddresses(subnet.DNSNameservers)
+		if err != nil {
+			return nil, err
+		}
+		device.config.nameservers = append(device.config.nameservers, nameservers...)
+		device.config.domains = append(device.config.domains, subnet.DNSSearch...)
+
+		for _, sr := range subnet.Routes {
+			destination := sr.Network
+			if sr.Netmask != "" {
+				network, err := parseAddress(sr.Network, sr.Netmask)
+				if err != nil {
+					return nil, fmt.Errorf("could not parse %q as route destination: %v", sr.Network, err)
+				}
+				destination = network.String()
+			}
+			r, err := parseRoute(destination, sr.Gateway)
+			if err != nil {
+				return nil, err
+			}
+			r.metric = sr.Metric
+			device.config.routes = append(device.config.routes, r)
+		}
+	}
+	device.config.dhcp = dhcpMode(dhcp4, dhcp6)
+
+	return device, nil
+}
+
+func parseNetworkConfigV2(netconf config.Network) ([]*netDevice, error) {
+	var devices []*netDevice
+	for _, section := range []struct {
+		kind    interfaceKind
+		devices map[string]config.NetworkDevice
+	}{
+		{interfacePhysical, netconf.Ethernets},
+		{interfaceBond, netconf.Bonds},
+		{interfaceBridge, netconf.Bridges},
+		{interfaceVLAN, netconf.VLANs},
+	} {
+		for _, id := range sortedDevices(section.devices) {
+			device, err := parseDeviceV2(id, section.kind, section.devices[id])
+			if err != nil {
+				return nil, err
+			}
+			devices = append(devices, device)
+		}
+	}
+	return devices, nil
+}
+
+func parseDeviceV2(id string, kind interfaceKind, dev config.NetworkDevice) (*netDevice, error) {
+	device := &netDevice{
+		id:     id,
+		kind:   kind,
+		name:   id,
+		config: newStaticConfig(),
+	}
+	device.config.mtu = dev.MTU
+
+	switch kind {
+	case interfacePhysical:
+		// The ID of an ethernet only names it when it is not matched by
+		// any other property.
+		switch {
+		case dev.SetName != "":
+			device.name = dev.SetName
+		case dev.Match.Name != "":
+			device.name = dev.Match.Name
+		case dev.Match.MACAddress != "":
+			device.name = ""
+		}
+		if dev.Match.MACAddress != "" {
+			var err error
+			if device.hwaddr, err = net.ParseMAC(dev.Match.MACAddress); err != nil {
+				return nil, err
+			}
+		}
+	case interfaceBond:
+		device.members = dev.Interfaces
+		device.options = parseBondParameters(dev.Parameters)
+	case interfaceBridge:
+		device.members = dev.Interfaces
+		device.options = parseBridgeParameters(dev.Parameters)
+	case interfaceVLAN:
+		device.vlanID = dev.ID
+		device.link = dev.Link
+	}
+
+	for _, a := range dev.Addresses {
+		address, err := parseAddress(a, "")
+		if err != nil {
+			return nil, fmt.Errorf("could not parse %q as address of %q: %v", a, id, err)
+		}
+		device.config.addresses = append(device.config.addresses, *address)
+	}
+	for _, gateway := range []string{dev.Gateway4, dev.Gateway6} {
+		if gateway == "" {
+			continue
+		}
+		r, err := parseRoute("default", gateway)
+		if err != nil {
+			return nil, err
+		}
+		device.config.routes = append(device.config.routes, r)
+	}
+	for _, dr := range dev.Routes {
+		r, err := parseRoute(dr.To, dr.Via)
+		if err != nil {
+			return nil, err
+		}
+		r.metric = dr.Metric
+		r.onLink = dr.OnLink
+		device.config.routes = append(device.config.routes, r)
+	}
+
+	nameservers, err := parseNameserverAddresses(dev.Nameservers.Addresses)
+	if err != nil {
+		return nil, err
+	}
+	device.config.nameservers = nameservers
+	device.config.domains = dev.Nameservers.Search
+	device.config.dhcp = dhcpMode(dev.DHCP4, dev.DHCP6)
+
+	// networkd shares its DHCP settings between both families, so the
+	// DHCPv4 overrides win over the DHCPv6 ones.
+	for _, overrides := range []config.NetworkDHCPOverrides{dev.DHCP6Overrides, dev.DHCP4Overrides} {
+		if overrides.UseDNS != "" {
+			device.config.dhcpOptions.useDNS = overrides.UseDNS
+		}
+		if overrides.UseHostname != "" {
+			device.config.dhcpOptions.useHostname = overrides.UseHostname
+		}
+		if overrides.RouteMetric != 0 {
+			device.config.dhcpOptions.routeMetric = overrides.RouteMetric
+		}
+	}
+
+	return device, nil
+}
+
+// buildDevices renders devices. Bond and bridge members and VLAN parents
+// which are not described themselves are brought up without configuration.
+func buildDevices(devices []*netDevice) []InterfaceGenerator {
+	interfaceMap := make(map[string]networkInterface)
+	for _, device := range devices {
+		iface := logicalInterface{
+			name:     device.name,
+			hwaddr:   device.hwaddr,
+			config:   device.config,
+			children: []networkInterface{},
+		}
+		switch device.kind {
+		case interfaceBond:
+			interfaceMap[device.id] = &bondInterface{iface, device.members, device.options}
+		case interfaceBridge:
+			interfaceMap[device.id] = &bridgeInterface{iface, device.members, device.options}
+		case interfaceVLAN:
+			interfaceMap[device.id] = &vlanInterface{iface, device.vlanID, device.link}
+		default:
+			interfaceMap[device.id] = &physicalInterface{iface}
+		}
+	}
+	for _, device := range devices {
+		parents := device.members
+		if device.link != "" {
+			parents = append(parents, device.link)
+		}
+		for _, parent := range parents {
+			if _, ok := interfaceMap[parent]; !ok {
+				interfaceMap[parent] = &physicalInterface{
+					logicalInterface{
+						name:     parent,
+						config:   configMethodManual{},
+						children: []networkInterface{},
+					},
+				}
+			}
+		}
+	}
+	linkAncestors(interfaceMap)
+	markConfigDepths(interfaceMap)
+
+	interfaces := make([]InterfaceGenerator, 0, len(interfaceMap))
+	for _, name := range sortedInterfaces(interfaceMap) {
+		interfaces = append(interfaces, interfaceMap[name])
+	}
+	return interfaces
+}
+
+// parseBondParameters converts bond parameters to networkd options, skipping
+// the ones networkd has no equivalent for.
+func parseBondParameters(params map[string]string) map[string]string {
+	options := make(map[string]string)
+	for _, name := range sortedKeys(params) {
+		value := params[name]
+		param, ok := bondParameters[name]
+		if !ok {
+			log.Printf("Skipping unsupported bond parameter %q\n", name)
+			continue
+		}
+		if n, err := strconv.Atoi(value); err == nil {
+			switch {
+			case param.duration:
+				value += "ms"
+			case param.option == "Mode":
+				if mode, ok := bondModes[n]; ok {
+					value = mode
+				}
+			}
+		}
+		options[param.option] = value
+	}
+	return options
+}
+
+// parseBridgeParameters converts bridge parameters to networkd options,
+// skipping the ones networkd has no equivalent for.
+func parseBridgeParameters(params map[string]string) map[string]string {
+	options := make(map[string]string)
+	for _, name := range sortedKeys(params) {
+		value := params[name]
+		param := strings.Replace(name, "_", "-", -1)
+		param = strings.TrimPrefix(param, "bridge-")
+		option, ok := bridgeParameters[param]
+		if !ok {
+			log.Printf("Skipping unsupported bridge parameter %q\n", name)
+			continue
+		}
+		if option == "STP" {
+			switch strings.ToLower(value) {
+			case "on", "yes", "true", "1":
+				value = "yes"
+			case "off", "no", "false", "0":
+				value = "no"
+			}
+		}
+		options[option] = value
+	}
+	return options
+}
+
+// parseRoute parses a route to destination, which is either a network, a
+// single address or "default", via gateway.
+func parseRoute(destination, gateway string) (r route, err error) {
+	if gateway != "" {
+		if r.gateway = net.ParseIP(gateway); r.gateway == nil {
+			return r, fmt.Errorf("could not parse %q as gateway", gateway)
+		}
+	}
+
+	switch {
+	case destination == "default":
+		if r.gateway == nil {
+			return r, fmt.Errorf("default route without a gateway")
+		}
+		r.destination = defaultDestination(r.gateway)
+	case strings.Contains(destination, "/"):
+		_, network, err := net.ParseCIDR(destination)
+		if err != nil {
+			return r, fmt.Errorf("could not parse %q as route destination: %v", destination, err)
+		}
+		r.destination = *network
+	default:
+		ip := net.ParseIP(destination)
+		if ip == nil {
+			return r, fmt.Errorf("could not parse %q as route destination", destination)
+		}
+		if ip.To4() != nil {
+			r.destination = net.IPNet{IP: ip.To4(), Mask: net.CIDRMask(32, 32)}
+		} else {
+			r.destination = net.IPNet{IP: ip, Mask: net.CIDRMask(128, 128)}
+		}
+	}
+	return r, nil
+}
+
+func parseNameserverAddresses(addresses []string) ([]net.IP, error) {
+	nameservers := make([]net.IP, 0, len(addresses))
+	for _, address := range addresses {
+		ip := net.ParseIP(address)
+		if ip == nil {
+			return nil, fmt.Errorf("could not parse %q as nameserver IP address", address)
+		}
+		nameservers = append(nameservers, ip)
+	}
+	return nameservers, nil
+}
+
+// gatewayDevice returns the device whose addresses reach gateway, or else
+// the first one configured by DHCP.
+func gatewayDevice(devices []*netDevice, gateway net.IP) *netDevice {
+	for _, device := range devices {
+		for _, address := range device.config.addresses {
+			network := net.IPNet{IP: address.IP.Mask(address.Mask), Mask: address.Mask}
+			if network.Contains(gateway) {
+				return device
+			}
+		}
+	}
+	for _, device := range devices {
+		if device.config.dhcp != "" {
+			return device
+		}
+	}
+	return nil
+}
+
+func isConfigured(config configMethodStatic) bool {
+	return len(config.addresses) > 0 || config.dhcp != ""
+}
+
+func newStaticConfig() configMethodStatic {
+	return configMethodStatic{
+		addresses:   []net.IPNet{},
+		nameservers: []net.IP{},
+		routes:      []route{},
+	}
+}
+
+func sortedDevices(m map[string]config.NetworkDevice) (keys []string) {
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return
+}
@@ -0,0 +1,242 @@
+// Copyright 2015 CoreOS, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package network
+
+import (
+	"testing"
+
+	"github.com/coreos/coreos-cloudinit/config"
+)
+
+type expectedInterface struct {
+	filename string
+	netdev   string
+	link     string
+	network  string
+}
+
+func checkInterfaces(t *testing.T, name string, interfaces []InterfaceGenerator, expect []expectedInterface) {
+	if len(interfaces) != len(expect) {
+		t.Fatalf("bad number of interfaces (%s): want %d, got %d", name, len(expect), len(interfaces))
+	}
+	for i, iface := range interfaces {
+		if filename := iface.Filename(); filename != expect[i].filename {
+			t.Errorf("bad filename (%s #%d): want %q, got %q", name, i, expect[i].filename, filename)
+		}
+		if netdev := iface.Netdev(); netdev != expect[i].netdev {
+			t.Errorf("bad netdev (%s #%d): want %q, got %q", name, i, expect[i].netdev, netdev)
+		}
+		if link := iface.Link(); link != expect[i].link {
+			t.Errorf("bad link (%s #%d): want %q, got %q", name, i, expect[i].link, link)
+		}
+		if network := iface.Network(); network != expect[i].network {
+			t.Errorf("bad network (%s #%d): want %q, got %q", name, i, expect[i].network, network)
+		}
+	}
+}
+
+func TestProcessNetworkConfigV1(t *testing.T) {
+	interfaces, err := ProcessNetworkConfig(config.Network{
+		Version: 1,
+		Config: []config.NetworkConfigEntry{
+			{
+				Type:       "physical",
+				Name:       "eth0",
+				MACAddress: "52:54:00:12:34:00",
+				MTU:        9000,
+				Subnets: []config.NetworkSubnet{
+					{
+						Type:           "static",
+						Address:        "192.168.1.10",
+						Netmask:        "255.255.255.0",
+						Gateway:        "192.168.1.1",
+						DNSNameservers: []string{"192.168.1.2"},
+						DNSSearch:      []string{"example.com"},
+						Routes:         []config.NetworkSubnetRoute{{Network: "10.0.0.0", Netmask: "8", Gateway: "192.168.1.254", Metric: 100}},
+					},
+					{Type: "dhcp6"},
+				},
+			},
+			{Type: "physical", Name: "eth1"},
+			{Type: "physical", Name: "eth2"},
+			{
+				Type:           "bond",
+				Name:           "bond0",
+				BondInterfaces: []string{"eth1", "eth2"},
+				Params:         map[string]string{"bond-mode": "4", "bond-miimon": "100", "bond_xmit_hash_policy": "layer3+4", "bond-primary": "eth1"},
+				Subnets:        []config.NetworkSubnet{{Type: "dhcp"}},
+			},
+			{
+				Type:     "vlan",
+				Name:     "bond0.100",
+				VLANLink: "bond0",
+				VLANID:   100,
+				Subnets:  []config.NetworkSubnet{{Type: "static", Address: "172.16.0.2/24"}},
+			},
+			{
+				Type:             "bridge",
+				Name:             "br0",
+				BridgeInterfaces: []string{"eth3"},
+				Params:           map[string]string{"bridge_stp": "off", "bridge_fd": "0"},
+			},
+			{Type: "nameserver", Address: []string{"8.8.8.8"}, Search: []string{"example.net"}},
+			{Type: "route", Destination: "172.17.0.0/16", Gateway: "172.16.0.1", Metric: 50},
+		},
+	})
+	if err != nil {
+		t.Fatalf("bad error: want %v, got %v", nil, err)
+	}
+
+	checkInterfaces(t, "v1", interfaces, []expectedInterface{
+		{
+			filename: "01-bond0",
+			netdev:   "[NetDev]\nKind=bond\nName=bond0\n\n[Bond]\nMIIMonitorSec=100ms\nMode=802.3ad\nTransmitHashPolicy=layer3+4\n",
+			network:  "[Match]\nName=bond0\n\n[Network]\nVLAN=bond0.100\nDHCP=ipv4\nDNS=8.8.8.8\nDomains=example.net\n",
+		},
+		{
+			filename: "00-bond0.100",
+			netdev:   "[NetDev]\nKind=vlan\nName=bond0.100\n\n[VLAN]\nId=100\n",
+			network:  "[Match]\nName=bond0.100\n\n[Network]\nDNS=8.8.8.8\nDomains=example.net\n\n[Address]\nAddress=172.16.0.2/24\n\n[Route]\nDestination=172.17.0.0/16\nGateway=172.16.0.1\nMetric=50\n",
+		},
+		{
+			filename: "00-br0",
+			netdev:   "[NetDev]\nKind=bridge\nName=br0\n\n[Bridge]\nForwardDelaySec=0\nSTP=no\n",
+			network:  "[Match]\nName=br0\n\n[Network]\n",
+		},
+		{
+			filename: "00-eth0",
+			link:     "[Match]\nMACAddress=52:54:00:12:34:00\n\n[Link]\nName=eth0\n",
+			network: "[Match]\nName=eth0\nMACAddress=52:54:00:12:34:00\n\n[Network]\nDHCP=ipv6\nDNS=192.168.1.2\nDNS=8.8.8.8\nDomains=example.com example.net\n" +
+				"\n[Address]\nAddress=192.168.1.10/24\n" +
+				"\n[Route]\nDestination=0.0.0.0/0\nGateway=192.168.1.1\n" +
+				"\n[Route]\nDestination=10.0.0.0/8\nGateway=192.168.1.254\nMetric=100\n" +
+				"\n[Link]\nMTUBytes=9000\n",
+		},
+		{
+			filename: "02-eth1",
+			network:  "[Match]\nName=eth1\n\n[Network]\nBond=bond0\n",
+		},
+		{
+			filename: "02-eth2",
+			network:  "[Match]\nName=eth2\n\n[Network]\nBond=bond0\n",
+		},
+		{
+			filename: "01-eth3",
+			network:  "[Match]\nName=eth3\n\n[Network]\nBridge=br0\n",
+		},
+	})
+}
+
+func TestProcessNetworkConfigV2(t *testing.T) {
+	interfaces, err := ProcessNetworkConfig(config.Network{
+		Version: 2,
+		Ethernets: map[string]config.NetworkDevice{
+			"lan": {
+				Match:          config.NetworkMatch{MACAddress: "52:54:00:12:34:00"},
+				SetName:        "lan0",
+				MTU:            1500,
+				DHCP4:          true,
+				DHCP4Overrides: config.NetworkDHCPOverrides{UseDNS: "false", RouteMetric: 100},
+				DHCP6Overrides: config.NetworkDHCPOverrides{UseHostname: "false", RouteMetric: 200},
+				Nameservers:    config.NetworkNameservers{Addresses: []string{"8.8.8.8"}, Search: []string{"example.com"}},
+			},
+			"wan": {
+				Match:     config.NetworkMatch{MACAddress: "52:54:00:12:34:01"},
+				Addresses: []string{"203.0.113.10/24", "2001:db8::10/64"},
+				Gateway4:  "203.0.113.1",
+				Gateway6:  "2001:db8::1",
+				Routes:    []config.NetworkRoute{{To: "198.51.100.0/24", Via: "203.0.113.254"}, {To: "203.0.113.128", Via: "192.0.2.1", Metric: 10, OnLink: true}},
+			},
+			"eno1": {},
+			"eno2": {},
+		},
+		Bonds: map[string]config.NetworkDevice{
+			"bond0": {
+				Interfaces: []string{"eno1", "eno2"},
+				Parameters: map[string]string{"mode": "active-backup", "mii-monitor-interval": "1s", "gratuitous-arp": "5"},
+			},
+		},
+		Bridges: map[string]config.NetworkDevice{
+			"br0": {
+				Interfaces: []string{"bond0"},
+				Parameters: map[string]string{"stp": "true", "forward-delay": "4", "priority": "32768", "ageing-time": "50"},
+				DHCP4:      true,
+				DHCP6:      true,
+			},
+		},
+		VLANs: map[string]config.NetworkDevice{
+			"vlan10": {ID: 10, Link: "lan", Addresses: []string{"10.0.10.2/24"}},
+		},
+	})
+	if err != nil {
+		t.Fatalf("bad error: want %v, got %v", nil, err)
+	}
+
+	checkInterfaces(t, "v2", interfaces, []expectedInterface{
+		{
+			filename: "01-bond0",
+			netdev:   "[NetDev]\nKind=bond\nName=bond0\n\n[Bond]\nMIIMonitorSec=1s\nMode=active-backup\n",
+			network:  "[Match]\nName=bond0\n\n[Network]\nBridge=br0\n",
+		},
+		{
+			filename: "00-br0",
+			netdev:   "[NetDev]\nKind=bridge\nName=br0\n\n[Bridge]\nForwardDelaySec=4\nPriority=32768\nSTP=yes\n",
+			network:  "[Match]\nName=br0\n\n[Network]\nDHCP=yes\n",
+		},
+		{
+			filename: "02-eno1",
+			network:  "[Match]\nName=eno1\n\n[Network]\nBond=bond0\n",
+		},
+		{
+			filename: "02-eno2",
+			network:  "[Match]\nName=eno2\n\n[Network]\nBond=bond0\n",
+		},
+		{
+			filename: "01-lan0",
+			link:     "[Match]\nMACAddress=52:54:00:12:34:00\n\n[Link]\nName=lan0\n",
+			network: "[Match]\nName=lan0\nMACAddress=52:54:00:12:34:00\n\n[Network]\nVLAN=vlan10\nDHCP=ipv4\nDNS=8.8.8.8\nDomains=example.com\n" +
+				"\n[DHCP]\nUseDNS=false\nUseHostname=false\nRouteMetric=100\n" +
+				"\n[Link]\nMTUBytes=1500\n",
+		},
+		{
+			filename: "00-vlan10",
+			netdev:   "[NetDev]\nKind=vlan\nName=vlan10\n\n[VLAN]\nId=10\n",
+			network:  "[Match]\nName=vlan10\n\n[Network]\n\n[Address]\nAddress=10.0.10.2/24\n",
+		},
+		{
+			filename: "00-52:54:00:12:34:01",
+			network:  "[Match]\nMACAddress=52:54:00:12:34:01\n\n[Network]\n\n[Address]\nAddress=203.0.113.10/24\n\n[Address]\nAddress=2001:db8::10/64\n\n[Route]\nDestination=0.0.0.0/0\nGateway=203.0.113.1\n\n[Route]\nDestination=::/0\nGateway=2001:db8::1\n\n[Route]\nDestination=198.51.100.0/24\nGateway=203.0.113.254\n\n[Route]\nDestination=203.0.113.128/32\nGateway=192.0.2.1\nGatewayOnLink=yes\nMetric=10\n",
+		},
+	})
+}
+
+func TestProcessNetworkConfigErrors(t *testing.T) {
+	for i, netconf := range []config.Network{
+		{},
+		{Version: 3},
+		{Version: 1, Config: []config.NetworkConfigEntry{{Type: "physical", Name: "eth0", MACAddress: "bad"}}},
+		{Version: 1, Config: []config.NetworkConfigEntry{{Type: "physical", Name: "eth0", Subnets: []config.NetworkSubnet{{Type: "static", Address: "10.0.0.1"}}}}},
+		{Version: 1, Config: []config.NetworkConfigEntry{{Type: "physical", Name: "eth0", Subnets: []config.NetworkSubnet{{Type: "ipv6_slaac"}}}}},
+		{Version: 1, Config: []config.NetworkConfigEntry{{Type: "nameserver", Address: []string{"bad"}}}},
+		{Version: 1, Config: []config.NetworkConfigEntry{{Type: "route", Destination: "10.0.0.0/8", Gateway: "192.168.1.1"}}},
+		{Version: 2, Ethernets: map[string]config.NetworkDevice{"eth0": {Addresses: []string{"10.0.0.1"}}}},
+		{Version: 2, Ethernets: map[string]config.NetworkDevice{"eth0": {Routes: []config.NetworkRoute{{To: "default"}}}}},
+		{Version: 2, Ethernets: map[string]config.NetworkDevice{"eth0": {Gateway4: "bad"}}},
+	} {
+		if _, err := ProcessNetworkConfig(netconf); err == nil {
+			t.Errorf("bad error (#%d): want non-nil, got %v", i, err)
+		}
+	}
+}
@@ -0,0 +1,352 @@
+// Copyright 2015 CoreOS, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package network
+
+import (
+	"crypto/sha1"
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// NMConnection is a NetworkManager keyfile describing the connection of one
+// interface.
+type NMConnection struct {
+	ID       string
+	Filename string
+	Content  string
+}
+
+// kernelBondOption is the kernel bonding option, as used by NetworkManager and
+// ifupdown, of a bond option. Durations are given to the kernel in
+// milliseconds.
+type kernelBondOption struct {
+	name     string
+	duration bool
+}
+
+// kernelBondOptions maps the networkd [Bond] options, and the ifupdown names
+// kept by the Debian converter, to kernel bonding options.
+var kernelBondOptions = map[string]kernelBondOption{
+	"Mode":                  {"mode", false},
+	"mode":                  {"mode", false},
+	"LACPTransmitRate":      {"lacp_rate", false},
+	"lacp-rate":             {"lacp_rate", false},
+	"MIIMonitorSec":         {"miimon", true},
+	"miimon":                {"miimon", false},
+	"UpDelaySec":            {"updelay", true},
+	"DownDelaySec":          {"downdelay", true},
+	"TransmitHashPolicy":    {"xmit_hash_policy", false},
+	"AdSelect":              {"ad_select", false},
+	"MinLinks":              {"min_links", false},
+	"ARPIntervalSec":        {"arp_interval", true},
+	"PrimaryReselectPolicy": {"primary_reselect", false},
+	"FailOverMACPolicy":     {"fail_over_mac", false},
+}
+
+// nmBridgeOptions maps the networkd [Bridge] options to NetworkManager.
+var nmBridgeOptions = map[string]string{
+	"STP":             "stp",
+	"ForwardDelaySec": "forward-delay",
+	"Priority":        "priority",
+}
+
+// NMConnections renders interfaces as NetworkManager keyfiles. Bond and
+// bridge members become slaves of their master, which is found, along with
+// the parent of VLANs, from the children of every interface.
+func NMConnections(interfaces []InterfaceGenerator) []NMConnection {
+	masters := make(map[string]networkInterface)
+	parents := make(map[string]string)
+	for _, iface := range interfaces {
+		ni, ok := iface.(networkInterface)
+		if !ok {
+			continue
+		}
+		for _, child := range ni.Children() {
+			switch child.(type) {
+			case *bondInterface, *bridgeInterface:
+				masters[iface.Name()] = child
+			case *vlanInterface:
+				parents[child.Name()] = iface.Name()
+			}
+		}
+	}
+
+	connections := make([]NMConnection, 0, len(interfaces))
+	for _, iface := range interfaces {
+		i := logicalOf(iface)
+		if i == nil {
+			continue
+		}
+
+		id := i.name
+		if id == "" || id == "*" {
+			id = iface.Filename()
+		}
+		connType := "ethernet"
+		switch iface.(type) {
+		case *bondInterface:
+			connType = "bond"
+		case *vlanInterface:
+			connType = "vlan"
+		case *bridgeInterface:
+			connType = "bridge"
+		}
+
+		config := "[connection]\n"
+		config += fmt.Sprintf("id=%s\n", id)
+		config += fmt.Sprintf("uuid=%s\n", nmUUID(id))
+		config += fmt.Sprintf("type=%s\n", connType)
+		if i.name != "" && i.name != "*" {
+			config += fmt.Sprintf("interface-name=%s\n", i.name)
+		}
+		master, isSlave := masters[i.name]
+		if isSlave {
+			config += fmt.Sprintf("master=%s\n", master.Name())
+			config += fmt.Sprintf("slave-type=%s\n", master.Type())
+		}
+		if i.name == "*" {
+			config += "\n[match]\ninterface-name=*\n"
+		}
+
+		var hwaddress net.HardwareAddr
+		var mtu int
+		switch c := i.config.(type) {
+		case configMethodStatic:
+			hwaddress, mtu = c.hwaddress, c.mtu
+		case configMethodDHCP:
+			hwaddress, mtu = c.hwaddress, c.mtu
+		}
+		ethernet := ""
+		if i.hwaddr != nil {
+			if connType == "ethernet" {
+				ethernet += fmt.Sprintf("mac-address=%s\n", i.hwaddr)
+			} else if hwaddress == nil {
+				hwaddress = i.hwaddr
+			}
+		}
+		if hwaddress != nil {
+			ethernet += fmt.Sprintf("cloned-mac-address=%s\n", hwaddress)
+		}
+		if mtu != 0 {
+			ethernet += fmt.Sprintf("mtu=%d\n", mtu)
+		}
+		if ethernet != "" {
+			config += "\n[ethernet]\n" + ethernet
+		}
+
+		switch t := iface.(type) {
+		case *bondInterface:
+			config += "\n[bond]\n"
+			for _, name := range sortedKeys(t.options) {
+				if option, ok := kernelBondOptions[name]; ok {
+					config += fmt.Sprintf("%s=%s\n", option.name, kernelBondValue(t.options[name], option.duration))
+				}
+			}
+		case *bridgeInterface:
+			options := ""
+			for _, name := range sortedKeys(t.options) {
+				if option, ok := nmBridgeOptions[name]; ok {
+					value := t.options[name]
+					if option == "stp" {
+						value = strconv.FormatBool(value == "yes")
+					}
+					options += fmt.Sprintf("%s=%s\n", option, value)
+				}
+			}
+			if options != "" {
+				config += "\n[bridge]\n" + options
+			}
+		case *vlanInterface:
+			parent := t.rawDevice
+			if p, ok := parents[i.name]; ok {
+				parent = p
+			}
+			config += fmt.Sprintf("\n[vlan]\nid=%d\nparent=%s\n", t.id, parent)
+		}
+
+		if !isSlave {
+			config += nmIPConfig(i.config)
+		}
+
+		connections = append(connections, NMConnection{
+			ID:       id,
+			Filename: fmt.Sprintf("%s.nmconnection", iface.Filename()),
+			Content:  config,
+		})
+	}
+	return connections
+}
+
+// nmIPConfig renders the [ipv4] and [ipv6] sections of a connection. Like
+// networkd, router advertisements are accepted unless they are disabled.
+func nmIPConfig(conf configMethod) string {
+	var static configMethodStatic
+	switch c := conf.(type) {
+	case configMethodStatic:
+		static = c
+	case configMethodDHCP:
+		static = configMethodStatic{
+			dhcp:        "yes",
+			domains:     c.domains,
+			dhcpOptions: c.dhcpOptions,
+		}
+	default:
+		return "\n[ipv4]\nmethod=disabled\n\n[ipv6]\nmethod=ignore\n"
+	}
+
+	dhcp4, dhcp6 := dhcpFamilies(static.dhcp)
+	config := ""
+	for _, family := range []struct {
+		section string
+		v4      bool
+		dhcp    bool
+	}{
+		{"ipv4", true, dhcp4},
+		{"ipv6", false, dhcp6},
+	} {
+		var addresses []string
+		for _, address := range static.addresses {
+			if (address.IP.To4() != nil) == family.v4 {
+				addresses = append(addresses, address.String())
+			}
+		}
+
+		var method string
+		switch {
+		case family.dhcp && family.v4:
+			method = "auto"
+		case family.dhcp && static.acceptRA == "no":
+			method = "dhcp"
+		case family.dhcp:
+			method = "auto"
+		case len(addresses) > 0:
+			method = "manual"
+		case family.v4:
+			method = "disabled"
+		case static.acceptRA == "no":
+			method = "ignore"
+		default:
+			method = "auto"
+		}
+
+		config += fmt.Sprintf("\n[%s]\nmethod=%s\n", family.section, method)
+		for n, address := range addresses {
+			config += fmt.Sprintf("address%d=%s\n", n+1, address)
+		}
+
+		var dns []string
+		for _, nameserver := range static.nameservers {
+			if (nameserver.To4() != nil) == family.v4 {
+				dns = append(dns, nameserver.String())
+			}
+		}
+		if len(dns) > 0 {
+			config += fmt.Sprintf("dns=%s;\n", strings.Join(dns, ";"))
+		}
+		if len(static.domains) > 0 && family.v4 {
+			config += fmt.Sprintf("dns-search=%s;\n", strings.Join(static.domains, ";"))
+		}
+
+		n := 0
+		for _, r := range static.routes {
+			if (r.destination.IP.To4() != nil) != family.v4 {
+				continue
+			}
+			if ones, _ := r.destination.Mask.Size(); ones == 0 && r.gateway != nil && r.metric == 0 && !r.onLink {
+				config += fmt.Sprintf("gateway=%s\n", r.gateway)
+				continue
+			}
+			n++
+			value := r.destination.String()
+			gateway := r.gateway
+			if gateway == nil && r.metric != 0 {
+				// The metric follows the gateway, which is
+				// given as unspecified.
+				gateway = net.IPv6unspecified
+				if family.v4 {
+					gateway = net.IPv4zero
+				}
+			}
+			if gateway != nil {
+				value += fmt.Sprintf(",%s", gateway)
+			}
+			if r.metric != 0 {
+				value += fmt.Sprintf(",%d", r.metric)
+			}
+			config += fmt.Sprintf("route%d=%s\n", n, value)
+			if r.onLink {
+				config += fmt.Sprintf("route%d_options=onlink=true\n", n)
+			}
+		}
+
+		if family.dhcp {
+			if static.dhcpOptions.useDNS == "false" {
+				config += "ignore-auto-dns=true\n"
+			}
+			if static.dhcpOptions.routeMetric != 0 {
+				config += fmt.Sprintf("route-metric=%d\n", static.dhcpOptions.routeMetric)
+			}
+		}
+	}
+	if static.dhcpOptions.useHostname == "false" && static.dhcp != "" {
+		config += "\n[hostname]\nfrom-dhcp=false\n"
+	}
+	return config
+}
+
+// kernelBondValue converts a networkd duration, in seconds unless a unit is
+// given, to milliseconds.
+func kernelBondValue(value string, duration bool) string {
+	if !duration {
+		return value
+	}
+	if _, err := strconv.ParseFloat(value, 64); err == nil {
+		value += "s"
+	}
+	d, err := time.ParseDuration(value)
+	if err != nil {
+		return value
+	}
+	return strconv.FormatInt(int64(d/time.Millisecond), 10)
+}
+
+// nmUUID derives a stable UUID from the ID of a connection, so that
+// rendering the same interfaces again updates the existing connections.
+func nmUUID(id string) string {
+	sum := sha1.Sum([]byte("coreos-cloudinit:" + id))
+	sum[6] = sum[6]&0x0f | 0x50
+	sum[8] = sum[8]&0x3f | 0x80
+	return fmt.Sprintf("%x-%x-%x-%x-%x", sum[0:4], sum[4:6], sum[6:8], sum[8:10], sum[10:16])
+}
+
+// logicalOf returns the logicalInterface embedded in iface.
+func logicalOf(iface InterfaceGenerator) *logicalInterface {
+	switch i := iface.(type) {
+	case *physicalInterface:
+		return &i.logicalInterface
+	case *allInterfaces:
+		return &i.logicalInterface
+	case *bondInterface:
+		return &i.logicalInterface
+	case *vlanInterface:
+		return &i.logicalInterface
+	case *bridgeInterface:
+		return &i.logicalInterface
+	}
+	return nil
+}
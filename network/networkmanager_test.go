@@ -0,0 +1,143 @@
+// Copyright 2015 CoreOS, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package network
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/coreos/coreos-cloudinit/config"
+	"github.com/coreos/coreos-cloudinit/datasource/proc_cmdline"
+)
+
+func TestNMConnections(t *testing.T) {
+	interfaces, err := ProcessNetworkConfig(config.Network{
+		Version: 2,
+		Ethernets: map[string]config.NetworkDevice{
+			"eno1": {},
+			"eno2": {},
+			"lan": {
+				Match:          config.NetworkMatch{MACAddress: "52:54:00:12:34:00"},
+				MTU:            9000,
+				DHCP4:          true,
+				DHCP4Overrides: config.NetworkDHCPOverrides{UseDNS: "false", RouteMetric: 100},
+			},
+		},
+		Bonds: map[string]config.NetworkDevice{
+			"bond0": {
+				Interfaces:  []string{"eno1", "eno2"},
+				Parameters:  map[string]string{"mode": "802.3ad", "mii-monitor-interval": "100"},
+				Addresses:   []string{"203.0.113.10/24", "2001:db8::10/64"},
+				Gateway4:    "203.0.113.1",
+				Nameservers: config.NetworkNameservers{Addresses: []string{"8.8.8.8", "2001:4860:4860::8888"}, Search: []string{"example.com"}},
+				Routes:      []config.NetworkRoute{{To: "198.51.100.0/24", Via: "203.0.113.254", Metric: 50}, {To: "192.0.2.0/24", Via: "10.0.0.1", OnLink: true}},
+			},
+		},
+		Bridges: map[string]config.NetworkDevice{
+			"br0": {Interfaces: []string{"vlan10"}, Parameters: map[string]string{"stp": "false", "forward-delay": "0"}, DHCP6: true},
+		},
+		VLANs: map[string]config.NetworkDevice{
+			"vlan10": {ID: 10, Link: "bond0"},
+		},
+	})
+	if err != nil {
+		t.Fatalf("bad error: want %v, got %v", nil, err)
+	}
+
+	expect := []NMConnection{
+		{
+			ID:       "bond0",
+			Filename: "02-bond0.nmconnection",
+			Content: "[connection]\nid=bond0\nuuid=c0bd75f9-e123-5920-bf0c-c3e6c0961f9a\ntype=bond\ninterface-name=bond0\n" +
+				"\n[bond]\nmiimon=100\nmode=802.3ad\n" +
+				"\n[ipv4]\nmethod=manual\naddress1=203.0.113.10/24\ndns=8.8.8.8;\ndns-search=example.com;\ngateway=203.0.113.1\n" +
+				"route1=198.51.100.0/24,203.0.113.254,50\nroute2=192.0.2.0/24,10.0.0.1\nroute2_options=onlink=true\n" +
+				"\n[ipv6]\nmethod=manual\naddress1=2001:db8::10/64\ndns=2001:4860:4860::8888;\n",
+		},
+		{
+			ID:       "br0",
+			Filename: "00-br0.nmconnection",
+			Content: "[connection]\nid=br0\nuuid=88c26da0-316d-5150-8997-b4393a146e8c\ntype=bridge\ninterface-name=br0\n" +
+				"\n[bridge]\nforward-delay=0\nstp=false\n" +
+				"\n[ipv4]\nmethod=disabled\n" +
+				"\n[ipv6]\nmethod=auto\n",
+		},
+		{
+			ID:       "eno1",
+			Filename: "03-eno1.nmconnection",
+			Content:  "[connection]\nid=eno1\nuuid=9b1b3594-a66f-5cb2-9b33-756b2b70bee6\ntype=ethernet\ninterface-name=eno1\nmaster=bond0\nslave-type=bond\n",
+		},
+		{
+			ID:       "eno2",
+			Filename: "03-eno2.nmconnection",
+			Content:  "[connection]\nid=eno2\nuuid=76724875-6785-56bf-aeb5-18cf28e3deb3\ntype=ethernet\ninterface-name=eno2\nmaster=bond0\nslave-type=bond\n",
+		},
+		{
+			ID:       "00-52:54:00:12:34:00",
+			Filename: "00-52:54:00:12:34:00.nmconnection",
+			Content: "[connection]\nid=00-52:54:00:12:34:00\nuuid=6fb40fab-49ed-510b-a992-e616efa41800\ntype=ethernet\n" +
+				"\n[ethernet]\nmac-address=52:54:00:12:34:00\nmtu=9000\n" +
+				"\n[ipv4]\nmethod=auto\nignore-auto-dns=true\nroute-metric=100\n" +
+				"\n[ipv6]\nmethod=auto\n",
+		},
+		{
+			ID:       "vlan10",
+			Filename: "01-vlan10.nmconnection",
+			Content: "[connection]\nid=vlan10\nuuid=1a71e3ca-b0a2-56d1-8082-db92e108318c\ntype=vlan\ninterface-name=vlan10\nmaster=br0\nslave-type=bridge\n" +
+				"\n[vlan]\nid=10\nparent=bond0\n",
+		},
+	}
+	if connections := NMConnections(interfaces); !reflect.DeepEqual(connections, expect) {
+		t.Fatalf("bad connections: want %#v, got %#v", expect, connections)
+	}
+}
+
+func TestNMConnectionsCmdline(t *testing.T) {
+	interfaces, err := ProcessCmdlineNetconf(proc_cmdline.NetworkConfig{IP: []string{"dhcp"}})
+	if err != nil {
+		t.Fatalf("bad error: want %v, got %v", nil, err)
+	}
+
+	expect := []NMConnection{{
+		ID:       "00-cmdline",
+		Filename: "00-cmdline.nmconnection",
+		Content: "[connection]\nid=00-cmdline\nuuid=" + nmUUID("00-cmdline") + "\ntype=ethernet\n" +
+			"\n[match]\ninterface-name=*\n" +
+			"\n[ipv4]\nmethod=auto\n" +
+			"\n[ipv6]\nmethod=auto\n",
+	}}
+	if connections := NMConnections(interfaces); !reflect.DeepEqual(connections, expect) {
+		t.Fatalf("bad connections: want %#v, got %#v", expect, connections)
+	}
+}
+
+func TestKernelBondValue(t *testing.T) {
+	for _, tt := range []struct {
+		value    string
+		duration bool
+		out      string
+	}{
+		{"802.3ad", false, "802.3ad"},
+		{"100", false, "100"},
+		{".2", true, "200"},
+		{"1s", true, "1000"},
+		{"100ms", true, "100"},
+		{"bad", true, "bad"},
+	} {
+		if out := kernelBondValue(tt.value, tt.duration); out != tt.out {
+			t.Errorf("bad value (%q, %t): want %q, got %q", tt.value, tt.duration, tt.out, out)
+		}
+	}
+}
@@ -15,6 +15,7 @@
 package network
 
 import (
+	"fmt"
 	"net"
 
 	"github.com/coreos/coreos-cloudinit/datasource/metadata/packet"
@@ -36,42 +37,24 @@ func ProcessPacketNetconf(netdata packet.NetworkData) ([]InterfaceGenerator, err
 	return generators, nil
 }
 
+// bondModes maps Linux bonding mode numbers to networkd bond modes.
+var bondModes = map[int]string{
+	0: "balance-rr",
+	1: "active-backup",
+	2: "balance-xor",
+	3: "broadcast",
+	4: "802.3ad",
+	5: "balance-tlb",
+	6: "balance-alb",
+}
+
 func parseNetwork(netdata packet.NetworkData, nameservers []net.IP) ([]InterfaceGenerator, error) {
 	var interfaces []InterfaceGenerator
-	var addresses []net.IPNet
-	var routes []route
-	for _, netblock := range netdata.Netblocks {
-		addresses = append(addresses, net.IPNet{
-			IP:   netblock.Address,
-			Mask: net.IPMask(netblock.Netmask),
-		})
-		if netblock.Public == false {
-			routes = append(routes, route{
-				destination: net.IPNet{
-					IP:   net.IPv4(10, 0, 0, 0),
-					Mask: net.IPv4Mask(255, 0, 0, 0),
-				},
-				gateway: netblock.Gateway,
-			})
-		} else {
-			if netblock.AddressFamily == 4 {
-				routes = append(routes, route{
-					destination: net.IPNet{
-						IP:   net.IPv4zero,
-						Mask: net.IPMask(net.IPv4zero),
-					},
-					gateway: netblock.Gateway,
-				})
-			} else {
-				routes = append(routes, route{
-					destination: net.IPNet{
-						IP:   net.IPv6zero,
-						Mask: net.IPMask(net.IPv6zero),
-					},
-					gateway: netblock.Gateway,
-				})
-			}
-		}
+
+	addresses, routes := parseNetblocks(netdata.Netblocks)
+	options, err := bondOptions(netdata.Bonding)
+	if err != nil {
+		return nil, err
 	}
 
 	bond := bondInterface{
@@ -83,45 +66,142 @@ func parseNetwork(netdata packet.NetworkData, nameservers []net.IP) ([]Interface
 				routes:      routes,
 			},
 		},
-		options: map[string]string{
-			"Mode":             "802.3ad",
-			"LACPTransmitRate": "fast",
-			"MIIMonitorSec":    ".2",
-			"UpDelaySec":       ".2",
-			"DownDelaySec":     ".2",
-		},
+		options: options,
 	}
 
-	for _, iface := range netdata.Interfaces {
-		if iface.Name != "chassis0" && iface.Name != "ipmi0" {
-			bond.slaves = append(bond.slaves, iface.Name)
-			if iface.Name == "enp1s0f0" {
-				bond.hwaddr, _ = net.ParseMAC(iface.Mac)
-			}
+	nics := bondedNics(netdata.Interfaces)
+	for _, iface := range nics {
+		if iface.Bond != "" {
+			bond.name = iface.Bond
+		}
+		bond.slaves = append(bond.slaves, iface.Name)
+		if iface.Name == "enp1s0f0" {
+			bond.hwaddr, _ = net.ParseMAC(iface.Mac)
+		}
+	}
+	if netdata.Bonding != nil && netdata.Bonding.Mac != "" {
+		if bond.hwaddr, err = net.ParseMAC(netdata.Bonding.Mac); err != nil {
+			return nil, err
 		}
 	}
 
-	for _, iface := range netdata.Interfaces {
-		if iface.Name != "chassis0" && iface.Name != "ipmi0" {
-			p := physicalInterface{
-				logicalInterface: logicalInterface{
-					name: iface.Name,
-					config: configMethodStatic{
-						nameservers: nameservers,
-					},
-					children: []networkInterface{&bond},
+	for _, vlan := range netdata.VLANs {
+		addresses, routes := parseNetblocks(vlan.Netblocks)
+		bond.children = append(bond.children, &vlanInterface{
+			logicalInterface: logicalInterface{
+				name: fmt.Sprintf("%s.%d", bond.name, vlan.ID),
+				config: configMethodStatic{
+					addresses: addresses,
+					routes:    routes,
 				},
-			}
+			},
+			id:        vlan.ID,
+			rawDevice: bond.name,
+		})
+	}
 
-			if iface.Name == "enp1s0f0" {
-				p.configDepth = 20
-			}
+	for _, iface := range nics {
+		p := physicalInterface{
+			logicalInterface: logicalInterface{
+				name: iface.Name,
+				config: configMethodStatic{
+					nameservers: nameservers,
+				},
+				children: []networkInterface{&bond},
+			},
+		}
 
-			interfaces = append(interfaces, &p)
+		if iface.Name == "enp1s0f0" {
+			p.configDepth = 20
 		}
+
+		interfaces = append(interfaces, &p)
 	}
 
 	interfaces = append(interfaces, &bond)
+	for _, vlan := range bond.children {
+		interfaces = append(interfaces, vlan)
+	}
 
 	return interfaces, nil
 }
+
+// parseNetblocks returns the addresses of netblocks along with their routes:
+// a default route for public netblocks and a route to 10.0.0.0/8 for private
+// ones.
+func parseNetblocks(netblocks []packet.Netblock) (addresses []net.IPNet, routes []route) {
+	for _, netblock := range netblocks {
+		addresses = append(addresses, net.IPNet{
+			IP:   netblock.Address,
+			Mask: net.IPMask(netblock.Netmask),
+		})
+		if netblock.Gateway == nil {
+			continue
+		}
+		if netblock.Public == false {
+			routes = append(routes, route{
+				destination: net.IPNet{
+					IP:   net.IPv4(10, 0, 0, 0),
+					Mask: net.IPv4Mask(255, 0, 0, 0),
+				},
+				gateway: netblock.Gateway,
+			})
+		} else if netblock.AddressFamily == 4 {
+			routes = append(routes, route{
+				destination: defaultDestination(net.IPv4zero),
+				gateway:     netblock.Gateway,
+			})
+		} else {
+			routes = append(routes, route{
+				destination: defaultDestination(net.IPv6zero),
+				gateway:     netblock.Gateway,
+			})
+		}
+	}
+	return
+}
+
+// bondOptions returns the networkd options of a bond in the given mode. LACP
+// (802.3ad) is assumed when the metadata does not describe the bonding or
+// gives mode 0, which Packet does not use and is what an empty "bonding"
+// object decodes to.
+func bondOptions(bonding *packet.Bonding) (map[string]string, error) {
+	mode := 4
+	if bonding != nil && bonding.Mode != 0 {
+		mode = bonding.Mode
+	}
+	name, ok := bondModes[mode]
+	if !ok {
+		return nil, fmt.Errorf("unsupported bonding mode %d", mode)
+	}
+
+	options := map[string]string{
+		"Mode":          name,
+		"MIIMonitorSec": ".2",
+		"UpDelaySec":    ".2",
+		"DownDelaySec":  ".2",
+	}
+	if name == "802.3ad" {
+		options["LACPTransmitRate"] = "fast"
+	}
+	return options, nil
+}
+
+// bondedNics returns the NICs forming the bond. When the metadata does not
+// name the bond of each NIC, every NIC but the chassis and IPMI ones is used.
+func bondedNics(nics []packet.Nic) (bonded []packet.Nic) {
+	for _, nic := range nics {
+		if nic.Bond != "" {
+			bonded = append(bonded, nic)
+		}
+	}
+	if len(bonded) > 0 {
+		return
+	}
+	for _, nic := range nics {
+		if nic.Name != "chassis0" && nic.Name != "ipmi0" {
+			bonded = append(bonded, nic)
+		}
+	}
+	return
+}
@@ -0,0 +1,117 @@
+// Copyright 2015 CoreOS, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package network
+
+import (
+	"net"
+	"testing"
+
+	"github.com/coreos/coreos-cloudinit/datasource/metadata/packet"
+)
+
+func TestProcessPacketNetconf(t *testing.T) {
+	interfaces, err := ProcessPacketNetconf(packet.NetworkData{
+		Bonding: &packet.Bonding{Mode: 1, Mac: "0c:c4:7a:e5:48:8a"},
+		Interfaces: []packet.Nic{
+			{Name: "enp1s0f0", Mac: "0c:c4:7a:e5:48:8a", Bond: "bond0"},
+			{Name: "enp1s0f1", Mac: "0c:c4:7a:e5:48:8b", Bond: "bond0"},
+			{Name: "ipmi0", Mac: "0c:c4:7a:e5:48:8c"},
+		},
+		Netblocks: []packet.Netblock{
+			{Address: net.ParseIP("147.75.64.11"), Netmask: net.ParseIP("255.255.255.254").To4(), Gateway: net.ParseIP("147.75.64.10"), AddressFamily: 4, Public: true},
+			{Address: net.ParseIP("10.99.182.129"), Netmask: net.ParseIP("255.255.255.254").To4(), Gateway: net.ParseIP("10.99.182.128"), AddressFamily: 4},
+		},
+		VLANs: []packet.VLAN{
+			{ID: 1000, Netblocks: []packet.Netblock{{Address: net.ParseIP("2604:1380::2"), Netmask: net.ParseIP("ffff:ffff:ffff:ffff::"), Gateway: net.ParseIP("2604:1380::1"), AddressFamily: 6, Public: true}}},
+		},
+		DNS: []net.IP{net.ParseIP("147.75.207.207")},
+	})
+	if err != nil {
+		t.Fatalf("bad error: want %v, got %v", nil, err)
+	}
+
+	expect := []struct {
+		filename string
+		netdev   string
+		network  string
+	}{
+		{
+			filename: "14-enp1s0f0",
+			network:  "[Match]\nName=enp1s0f0\n\n[Network]\nBond=bond0\nDNS=147.75.207.207\n",
+		},
+		{
+			filename: "00-enp1s0f1",
+			network:  "[Match]\nName=enp1s0f1\n\n[Network]\nBond=bond0\nDNS=147.75.207.207\n",
+		},
+		{
+			filename: "00-bond0",
+			netdev:   "[NetDev]\nKind=bond\nName=bond0\nMACAddress=0c:c4:7a:e5:48:8a\n\n[Bond]\nDownDelaySec=.2\nMIIMonitorSec=.2\nMode=active-backup\nUpDelaySec=.2\n",
+			network:  "[Match]\nName=bond0\nMACAddress=0c:c4:7a:e5:48:8a\n\n[Network]\nVLAN=bond0.1000\nDNS=147.75.207.207\n\n[Address]\nAddress=147.75.64.11/31\n\n[Address]\nAddress=10.99.182.129/31\n\n[Route]\nDestination=0.0.0.0/0\nGateway=147.75.64.10\n\n[Route]\nDestination=10.0.0.0/8\nGateway=10.99.182.128\n",
+		},
+		{
+			filename: "00-bond0.1000",
+			netdev:   "[NetDev]\nKind=vlan\nName=bond0.1000\n\n[VLAN]\nId=1000\n",
+			network:  "[Match]\nName=bond0.1000\n\n[Network]\n\n[Address]\nAddress=2604:1380::2/64\n\n[Route]\nDestination=::/0\nGateway=2604:1380::1\n",
+		},
+	}
+	if len(interfaces) != len(expect) {
+		t.Fatalf("bad number of interfaces: want %d, got %d", len(expect), len(interfaces))
+	}
+	for i, iface := range interfaces {
+		if filename := iface.Filename(); filename != expect[i].filename {
+			t.Errorf("bad filename (#%d): want %q, got %q", i, expect[i].filename, filename)
+		}
+		if netdev := iface.Netdev(); netdev != expect[i].netdev {
+			t.Errorf("bad netdev (#%d): want %q, got %q", i, expect[i].netdev, netdev)
+		}
+		if network := iface.Network(); network != expect[i].network {
+			t.Errorf("bad network (#%d): want %q, got %q", i, expect[i].network, network)
+		}
+	}
+
+	if _, err := ProcessPacketNetconf(packet.NetworkData{Bonding: &packet.Bonding{Mode: 7}}); err == nil {
+		t.Errorf("bad error: want unsupported bonding mode, got %v", err)
+	}
+}
+
+func TestProcessPacketNetconfDefaults(t *testing.T) {
+	interfaces, err := ProcessPacketNetconf(packet.NetworkData{
+		Bonding: &packet.Bonding{},
+		Interfaces: []packet.Nic{
+			{Name: "chassis0", Mac: "0c:c4:7a:e5:48:89"},
+			{Name: "enp1s0f0", Mac: "0c:c4:7a:e5:48:8a"},
+			{Name: "enp1s0f1", Mac: "0c:c4:7a:e5:48:8b"},
+		},
+	})
+	if err != nil {
+		t.Fatalf("bad error: want %v, got %v", nil, err)
+	}
+	if len(interfaces) != 3 {
+		t.Fatalf("bad number of interfaces: want %d, got %d", 3, len(interfaces))
+	}
+
+	bond := interfaces[2].(*bondInterface)
+	if len(bond.slaves) != 2 || bond.slaves[0] != "enp1s0f0" || bond.slaves[1] != "enp1s0f1" {
+		t.Errorf("bad slaves: want %v, got %v", []string{"enp1s0f0", "enp1s0f1"}, bond.slaves)
+	}
+	netdev := "[NetDev]\nKind=bond\nName=bond0\nMACAddress=0c:c4:7a:e5:48:8a\n\n[Bond]\nDownDelaySec=.2\nLACPTransmitRate=fast\nMIIMonitorSec=.2\nMode=802.3ad\nUpDelaySec=.2\n"
+	if n := bond.Netdev(); n != netdev {
+		t.Errorf("bad netdev: want %q, got %q", netdev, n)
+	}
+	network := "[Match]\nName=enp1s0f0\n\n[Network]\nBond=bond0\nDNS=8.8.8.8\nDNS=8.8.4.4\n"
+	if n := interfaces[0].Network(); n != network {
+		t.Errorf("bad network: want %q, got %q", network, n)
+	}
+}
@@ -0,0 +1,71 @@
+// Copyright 2015 CoreOS, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package network
+
+import (
+	"fmt"
+	"log"
+	"net"
+	"strconv"
+
+	"github.com/coreos/coreos-cloudinit/datasource/metadata/scaleway"
+)
+
+// ProcessScalewayNetconf configures the single public interface of a
+// Scaleway instance. IPv4 is configured by DHCP; the IPv6 address, if any,
+// is configured statically.
+func ProcessScalewayNetconf(config scaleway.Metadata) ([]InterfaceGenerator, error) {
+	log.Println("Processing Scaleway network config")
+
+	iface := logicalInterface{
+		config:   configMethodDHCP{},
+		children: []networkInterface{},
+	}
+	if config.MACAddress != "" {
+		hwaddr, err := net.ParseMAC(config.MACAddress)
+		if err != nil {
+			return nil, err
+		}
+		iface.hwaddr = hwaddr
+	} else {
+		iface.name = "eth0"
+	}
+
+	if config.IPv6 != nil && config.IPv6.Address != "" {
+		var ip, gateway net.IP
+		if ip = net.ParseIP(config.IPv6.Address); ip == nil {
+			return nil, fmt.Errorf("could not parse %q as IPv6 address", config.IPv6.Address)
+		}
+		prefix, err := strconv.Atoi(config.IPv6.Netmask)
+		if err != nil {
+			return nil, fmt.Errorf("could not parse %q as IPv6 prefix", config.IPv6.Netmask)
+		}
+		if gateway = net.ParseIP(config.IPv6.Gateway); gateway == nil {
+			return nil, fmt.Errorf("could not parse %q as IPv6 gateway", config.IPv6.Gateway)
+		}
+		iface.config = configMethodStatic{
+			addresses:   []net.IPNet{{IP: ip, Mask: net.CIDRMask(prefix, net.IPv6len*8)}},
+			nameservers: []net.IP{},
+			routes: []route{{
+				destination: defaultDestination(ip),
+				gateway:     gateway,
+			}},
+			dhcp: "ipv4",
+		}
+	}
+
+	log.Println("Processed Scaleway network config")
+	return []InterfaceGenerator{&physicalInterface{iface}}, nil
+}
@@ -0,0 +1,51 @@
+// Copyright 2015 CoreOS, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package network
+
+import (
+	"testing"
+
+	"github.com/coreos/coreos-cloudinit/datasource/metadata/scaleway"
+)
+
+func TestProcessScalewayNetconf(t *testing.T) {
+	for i, tt := range []struct {
+		config  scaleway.Metadata
+		network string
+	}{
+		{
+			config:  scaleway.Metadata{},
+			network: "[Match]\nName=eth0\n\n[Network]\nDHCP=ipv4\n",
+		},
+		{
+			config: scaleway.Metadata{
+				MACAddress: "de:00:00:01:62:27",
+				IPv6:       &scaleway.IPv6{Address: "2001:bc8:47c0:1c1c::1", Gateway: "2001:bc8:47c0:1c1c::", Netmask: "64"},
+			},
+			network: "[Match]\nMACAddress=de:00:00:01:62:27\n\n[Network]\nDHCP=ipv4\n\n[Address]\nAddress=2001:bc8:47c0:1c1c::1/64\n\n[Route]\nDestination=::/0\nGateway=2001:bc8:47c0:1c1c::\n",
+		},
+	} {
+		interfaces, err := ProcessScalewayNetconf(tt.config)
+		if err != nil {
+			t.Fatalf("bad error (#%d): want %v, got %v", i, nil, err)
+		}
+		if len(interfaces) != 1 {
+			t.Fatalf("bad number of interfaces (#%d): want 1, got %d", i, len(interfaces))
+		}
+		if network := interfaces[0].Network(); network != tt.network {
+			t.Errorf("bad network (#%d): want %q, got %q", i, tt.network, network)
+		}
+	}
+}
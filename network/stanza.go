@@ -41,11 +41,18 @@ const (
 	interfaceBond = interfaceKind(iota)
 	interfacePhysical
 	interfaceVLAN
+	interfaceBridge
 )
 
 type route struct {
 	destination net.IPNet
 	gateway     net.IP
+	// metric is the priority of the route, lower metrics being preferred.
+	// Zero leaves the networkd default.
+	metric int
+	// onLink considers the gateway directly reachable, even if it is not in
+	// the network of any address of the interface.
+	onLink bool
 }
 
 type configMethod interface{}
@@ -55,6 +62,15 @@ type configMethodStatic struct {
 	nameservers []net.IP
 	routes      []route
 	hwaddress   net.HardwareAddr
+	// dhcp enables DHCP alongside the static configuration, for "ipv4",
+	// "ipv6" or both ("yes").
+	dhcp string
+	// acceptRA explicitly enables ("yes") or disables ("no") IPv6 router
+	// advertisements.
+	acceptRA    string
+	domains     []string
+	mtu         int
+	dhcpOptions dhcpOptions
 }
 
 type configMethodLoopback struct{}
@@ -62,7 +78,18 @@ type configMethodLoopback struct{}
 type configMethodManual struct{}
 
 type configMethodDHCP struct {
-	hwaddress net.HardwareAddr
+	hwaddress   net.HardwareAddr
+	domains     []string
+	mtu         int
+	dhcpOptions dhcpOptions
+}
+
+// dhcpOptions are the networkd [DHCP] options. Empty values leave the
+// networkd defaults.
+type dhcpOptions struct {
+	useDNS      string
+	useHostname string
+	routeMetric int
 }
 
 func parseStanzas(lines []string) (stanzas []stanza, err error) {
@@ -173,6 +200,7 @@ func parseInterfaceStanza(attributes []string, options []string) (*stanzaInterfa
 	}
 
 	iface := attributes[0]
+	family := attributes[1]
 	confMethod := attributes[2]
 
 	optionMap := make(map[string][]string, 0)
@@ -204,13 +232,22 @@ func parseInterfaceStanza(attributes []string, options []string) (*stanzaInterfa
 	}
 
 	var conf configMethod
-	switch confMethod {
-	case "static":
+	switch {
+	case family == "inet6":
+		var err error
+		if conf, err = parseInet6ConfigMethod(iface, confMethod, optionMap); err != nil {
+			return nil, err
+		}
+	case confMethod == "static":
 		config := configMethodStatic{
 			addresses:   make([]net.IPNet, 1),
 			routes:      make([]route, 0),
 			nameservers: make([]net.IP, 0),
 		}
+		metric, err := parseLinkOptions(optionMap, iface, &config.mtu, &config.domains)
+		if err != nil {
+			return nil, err
+		}
 		if addresses, ok := optionMap["address"]; ok {
 			if len(addresses) == 1 {
 				config.addresses[0].IP = net.ParseIP(addresses[0])
@@ -232,6 +269,7 @@ func parseInterfaceStanza(attributes []string, options []string) (*stanzaInterfa
 						Mask: net.IPv4Mask(0, 0, 0, 0),
 					},
 					gateway: net.ParseIP(gateways[0]),
+					metric:  metric,
 				})
 			}
 		}
@@ -244,11 +282,15 @@ func parseInterfaceStanza(attributes []string, options []string) (*stanzaInterfa
 			config.nameservers = append(config.nameservers, net.ParseIP(nameserver))
 		}
 		for _, postup := range optionMap["post-up"] {
-			if strings.HasPrefix(postup, "route add") {
+			if strings.HasPrefix(postup, "route add") || strings.HasPrefix(postup, "ip route add") {
 				route := route{}
 				fields := strings.Fields(postup)
 				for i, field := range fields[:len(fields)-1] {
 					switch field {
+					case "add":
+						if _, dst, err := net.ParseCIDR(fields[i+1]); err == nil {
+							route.destination = *dst
+						}
 					case "-net":
 						if _, dst, err := net.ParseCIDR(fields[i+1]); err == nil {
 							route.destination = *dst
@@ -257,8 +299,12 @@ func parseInterfaceStanza(attributes []string, options []string) (*stanzaInterfa
 						}
 					case "netmask":
 						route.destination.Mask = net.IPMask(net.ParseIP(fields[i+1]).To4())
-					case "gw":
+					case "gw", "via":
 						route.gateway = net.ParseIP(fields[i+1])
+					case "metric":
+						if metric, err := strconv.Atoi(fields[i+1]); err == nil {
+							route.metric = metric
+						}
 					}
 				}
 				if route.destination.IP != nil && route.destination.Mask != nil && route.gateway != nil {
@@ -267,12 +313,17 @@ func parseInterfaceStanza(attributes []string, options []string) (*stanzaInterfa
 			}
 		}
 		conf = config
-	case "loopback":
+	case confMethod == "loopback":
 		conf = configMethodLoopback{}
-	case "manual":
+	case confMethod == "manual":
 		conf = configMethodManual{}
-	case "dhcp":
+	case confMethod == "dhcp":
 		config := configMethodDHCP{}
+		metric, err := parseLinkOptions(optionMap, iface, &config.mtu, &config.domains)
+		if err != nil {
+			return nil, err
+		}
+		config.dhcpOptions.routeMetric = metric
 		if hwaddress, err := parseHwaddress(optionMap, iface); err == nil {
 			config.hwaddress = hwaddress
 		} else {
@@ -295,9 +346,210 @@ func parseInterfaceStanza(attributes []string, options []string) (*stanzaInterfa
 		return parseBondStanza(iface, conf, attributes, optionMap)
 	}
 
+	if _, ok := optionMap["bridge_ports"]; ok {
+		return parseBridgeStanza(iface, conf, attributes, optionMap)
+	}
+
+	if _, ok := optionMap["bridge-ports"]; ok {
+		return parseBridgeStanza(iface, conf, attributes, optionMap)
+	}
+
 	return parsePhysicalStanza(iface, conf, attributes, optionMap)
 }
 
+// parseInet6ConfigMethod parses the config method of an inet6 stanza. It is
+// always expressed as a static config, so that it can be merged with the inet
+// stanza of the same interface.
+func parseInet6ConfigMethod(iface, method string, options map[string][]string) (configMethod, error) {
+	config := configMethodStatic{
+		addresses:   make([]net.IPNet, 0),
+		routes:      make([]route, 0),
+		nameservers: make([]net.IP, 0),
+	}
+	metric, err := parseLinkOptions(options, iface, &config.mtu, &config.domains)
+	if err != nil {
+		return nil, err
+	}
+
+	switch method {
+	case "static":
+		var address, netmask string
+		if addresses, ok := options["address"]; ok && len(addresses) == 1 {
+			address = addresses[0]
+		}
+		if netmasks, ok := options["netmask"]; ok && len(netmasks) == 1 {
+			netmask = netmasks[0]
+		}
+		ipnet, err := parseAddress(address, netmask)
+		if err != nil {
+			return nil, fmt.Errorf("malformed static network config for %q", iface)
+		}
+		config.addresses = append(config.addresses, *ipnet)
+
+		if gateways, ok := options["gateway"]; ok && len(gateways) == 1 {
+			gateway := net.ParseIP(gateways[0])
+			if gateway == nil {
+				return nil, fmt.Errorf("malformed gateway for %q", iface)
+			}
+			config.routes = append(config.routes, route{
+				destination: defaultDestination(gateway),
+				gateway:     gateway,
+				metric:      metric,
+			})
+		}
+		for _, nameserver := range options["dns-nameservers"] {
+			config.nameservers = append(config.nameservers, net.ParseIP(nameserver))
+		}
+	case "auto":
+		config.acceptRA = "yes"
+		if dhcp, ok := options["dhcp"]; ok && len(dhcp) == 1 && dhcp[0] == "1" {
+			config.dhcp = "ipv6"
+			config.dhcpOptions.routeMetric = metric
+		}
+	case "dhcp":
+		config.dhcp = "ipv6"
+		config.dhcpOptions.routeMetric = metric
+	case "manual":
+		return configMethodManual{}, nil
+	case "loopback":
+		return configMethodLoopback{}, nil
+	default:
+		return nil, fmt.Errorf("invalid config method %q", method)
+	}
+
+	if acceptRA, ok := options["accept_ra"]; ok && len(acceptRA) == 1 {
+		switch acceptRA[0] {
+		case "0":
+			config.acceptRA = "no"
+		case "1", "2":
+			config.acceptRA = "yes"
+		}
+	}
+
+	if config.hwaddress, err = parseHwaddress(options, iface); err != nil {
+		return nil, err
+	}
+
+	return config, nil
+}
+
+// parseLinkOptions parses the mtu and dns-search options into mtu and
+// domains, and returns the metric option, which applies to the default route
+// or the routes learned through DHCP.
+func parseLinkOptions(options map[string][]string, iface string, mtu *int, domains *[]string) (metric int, err error) {
+	if values, ok := options["mtu"]; ok {
+		if len(values) != 1 {
+			return 0, fmt.Errorf("malformed mtu option for %q", iface)
+		}
+		if *mtu, err = strconv.Atoi(values[0]); err != nil || *mtu <= 0 {
+			return 0, fmt.Errorf("malformed mtu option for %q", iface)
+		}
+	}
+	*domains = options["dns-search"]
+	if values, ok := options["metric"]; ok {
+		if len(values) != 1 {
+			return 0, fmt.Errorf("malformed metric option for %q", iface)
+		}
+		if metric, err = strconv.Atoi(values[0]); err != nil || metric < 0 {
+			return 0, fmt.Errorf("malformed metric option for %q", iface)
+		}
+	}
+	return metric, nil
+}
+
+// mergeStanzas merges the stanzas configuring the same interface, such as its
+// inet and inet6 stanzas, into the first of them.
+func mergeStanzas(stanzas []*stanzaInterface) []*stanzaInterface {
+	merged := make([]*stanzaInterface, 0, len(stanzas))
+	stanzaMap := make(map[string]*stanzaInterface)
+	for _, s := range stanzas {
+		m, ok := stanzaMap[s.name]
+		if !ok {
+			stanzaMap[s.name] = s
+			merged = append(merged, s)
+			continue
+		}
+
+		m.auto = m.auto || s.auto
+		if m.kind == interfacePhysical {
+			m.kind = s.kind
+		}
+		m.configMethod = mergeConfigMethods(m.configMethod, s.configMethod)
+		for k, v := range s.options {
+			if _, ok := m.options[k]; !ok {
+				m.options[k] = v
+			}
+		}
+	}
+	return merged
+}
+
+func mergeConfigMethods(a, b configMethod) configMethod {
+	if _, ok := a.(configMethodLoopback); ok {
+		return a
+	}
+	if _, ok := b.(configMethodLoopback); ok {
+		return b
+	}
+	if _, ok := a.(configMethodManual); ok {
+		return b
+	}
+	if _, ok := b.(configMethodManual); ok {
+		return a
+	}
+
+	x, y := toStaticConfig(a), toStaticConfig(b)
+	x.addresses = append(x.addresses, y.addresses...)
+	x.routes = append(x.routes, y.routes...)
+	x.nameservers = append(x.nameservers, y.nameservers...)
+	x.domains = append(x.domains, y.domains...)
+	if x.hwaddress == nil {
+		x.hwaddress = y.hwaddress
+	}
+	if x.mtu == 0 {
+		x.mtu = y.mtu
+	}
+	if x.dhcpOptions.routeMetric == 0 {
+		x.dhcpOptions.routeMetric = y.dhcpOptions.routeMetric
+	}
+	if y.acceptRA != "" {
+		x.acceptRA = y.acceptRA
+	}
+	x4, x6 := dhcpFamilies(x.dhcp)
+	y4, y6 := dhcpFamilies(y.dhcp)
+	x.dhcp = dhcpMode(x4 || y4, x6 || y6)
+	return x
+}
+
+// toStaticConfig returns a copy of the given DHCP or static config expressed
+// as a static config.
+func toStaticConfig(c configMethod) configMethodStatic {
+	config := configMethodStatic{
+		addresses:   make([]net.IPNet, 0),
+		routes:      make([]route, 0),
+		nameservers: make([]net.IP, 0),
+	}
+	switch c := c.(type) {
+	case configMethodDHCP:
+		config.dhcp = "ipv4"
+		config.hwaddress = c.hwaddress
+		config.domains = append(config.domains, c.domains...)
+		config.mtu = c.mtu
+		config.dhcpOptions = c.dhcpOptions
+	case configMethodStatic:
+		config.addresses = append(config.addresses, c.addresses...)
+		config.routes = append(config.routes, c.routes...)
+		config.nameservers = append(config.nameservers, c.nameservers...)
+		config.domains = append(config.domains, c.domains...)
+		config.hwaddress = c.hwaddress
+		config.dhcp = c.dhcp
+		config.acceptRA = c.acceptRA
+		config.mtu = c.mtu
+		config.dhcpOptions = c.dhcpOptions
+	}
+	return config
+}
+
 func parseHwaddress(options map[string][]string, iface string) (net.HardwareAddr, error) {
 	if hwaddress, ok := options["hwaddress"]; ok && len(hwaddress) == 2 {
 		switch hwaddress[0] {
@@ -315,6 +567,18 @@ func parseBondStanza(iface string, conf configMethod, attributes []string, optio
 	return &stanzaInterface{name: iface, kind: interfaceBond, configMethod: conf, options: options}, nil
 }
 
+func parseBridgeStanza(iface string, conf configMethod, attributes []string, options map[string][]string) (*stanzaInterface, error) {
+	// ifupdown2 spells the bridge options with dashes (e.g. "bridge-ports");
+	// store them under the bridge-utils names.
+	for k, v := range options {
+		if strings.HasPrefix(k, "bridge-") {
+			delete(options, k)
+			options["bridge_"+strings.TrimPrefix(k, "bridge-")] = v
+		}
+	}
+	return &stanzaInterface{name: iface, kind: interfaceBridge, configMethod: conf, options: options}, nil
+}
+
 func parsePhysicalStanza(iface string, conf configMethod, attributes []string, options map[string][]string) (*stanzaInterface, error) {
 	return &stanzaInterface{name: iface, kind: interfacePhysical, configMethod: conf, options: options}, nil
 }
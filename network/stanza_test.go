@@ -58,6 +58,10 @@ func TestBadParseInterfaceStanza(t *testing.T) {
 		{[]string{"eth", "inet", "static"}, []string{"address 192.168.1.100", "netmask invalid"}, "malformed static network config"},
 		{[]string{"eth", "inet", "static"}, []string{"address 192.168.1.100", "netmask 255.255.255.0", "hwaddress ether NotAnAddress"}, "malformed hwaddress option"},
 		{[]string{"eth", "inet", "dhcp"}, []string{"hwaddress ether NotAnAddress"}, "malformed hwaddress option"},
+		{[]string{"eth", "inet", "dhcp"}, []string{"mtu 0"}, "malformed mtu option"},
+		{[]string{"eth", "inet", "dhcp"}, []string{"mtu jumbo"}, "malformed mtu option"},
+		{[]string{"eth", "inet", "dhcp"}, []string{"metric -1"}, "malformed metric option"},
+		{[]string{"eth", "inet6", "static"}, []string{"address 2001:db8::5/64", "metric 1 2"}, "malformed metric option"},
 	} {
 		_, err := parseInterfaceStanza(tt.in, tt.opts)
 		if err == nil || !strings.HasPrefix(err.Error(), tt.e) {
@@ -338,6 +342,23 @@ func TestParseInterfaceStanzaStaticPostUp(t *testing.T) {
 				},
 			},
 		},
+		{
+			options: []string{
+				"address 192.168.1.100",
+				"netmask 255.255.255.0",
+				"post-up ip route add 10.0.0.0/8 via 192.168.1.254 dev eth metric 10",
+			},
+			expect: []route{
+				{
+					destination: net.IPNet{
+						IP:   net.IP{10, 0, 0, 0},
+						Mask: net.CIDRMask(8, 32),
+					},
+					gateway: net.IPv4(192, 168, 1, 254),
+					metric:  10,
+				},
+			},
+		},
 	} {
 		iface, err := parseInterfaceStanza([]string{"eth", "inet", "static"}, tt.options)
 		if err != nil {
@@ -383,6 +404,41 @@ func TestParseInterfaceStanzaDHCP(t *testing.T) {
 	}
 }
 
+func TestParseInterfaceStanzaLinkOptions(t *testing.T) {
+	options := []string{"address 192.168.1.100", "netmask 255.255.255.0", "gateway 192.168.1.1", "mtu 9000", "dns-search example.com example.net", "metric 100"}
+	iface, err := parseInterfaceStanza([]string{"eth", "inet", "static"}, options)
+	if err != nil {
+		t.Fatalf("bad error (%q): want %v, got %v", options, nil, err)
+	}
+	static, ok := iface.configMethod.(configMethodStatic)
+	if !ok {
+		t.Fatalf("bad config method (%q): got %#v", options, iface.configMethod)
+	}
+	if static.mtu != 9000 {
+		t.Fatalf("bad mtu (%q): want %d, got %d", options, 9000, static.mtu)
+	}
+	if domains := []string{"example.com", "example.net"}; !reflect.DeepEqual(static.domains, domains) {
+		t.Fatalf("bad domains (%q): want %q, got %q", options, domains, static.domains)
+	}
+	if len(static.routes) != 1 || static.routes[0].metric != 100 {
+		t.Fatalf("bad routes (%q): got %#v", options, static.routes)
+	}
+
+	options = []string{"mtu 1400", "dns-search example.com", "metric 200"}
+	iface, err = parseInterfaceStanza([]string{"eth", "inet", "dhcp"}, options)
+	if err != nil {
+		t.Fatalf("bad error (%q): want %v, got %v", options, nil, err)
+	}
+	expect := configMethodDHCP{
+		domains:     []string{"example.com"},
+		mtu:         1400,
+		dhcpOptions: dhcpOptions{routeMetric: 200},
+	}
+	if !reflect.DeepEqual(iface.configMethod, expect) {
+		t.Fatalf("bad config method (%q): want %#v, got %#v", options, expect, iface.configMethod)
+	}
+}
+
 func TestParseInterfaceStanzaPostUpOption(t *testing.T) {
 	options := []string{
 		"post-up",
@@ -497,6 +553,133 @@ func TestParseInterfaceStanzaBond(t *testing.T) {
 	}
 }
 
+func TestParseInterfaceStanzaInet6(t *testing.T) {
+	for i, tt := range []struct {
+		method  string
+		options []string
+		config  configMethod
+	}{
+		{
+			method:  "static",
+			options: []string{"address 2001:db8::2", "netmask 64", "gateway 2001:db8::1", "dns-nameservers 2001:db8::53"},
+			config: configMethodStatic{
+				addresses:   []net.IPNet{{IP: net.ParseIP("2001:db8::2"), Mask: net.CIDRMask(64, 128)}},
+				routes:      []route{{destination: net.IPNet{IP: net.IPv6zero, Mask: net.IPMask(net.IPv6zero)}, gateway: net.ParseIP("2001:db8::1")}},
+				nameservers: []net.IP{net.ParseIP("2001:db8::53")},
+			},
+		},
+		{
+			method:  "static",
+			options: []string{"address 2001:db8::2/48", "hwaddress ether 00:01:02:03:04:05"},
+			config: configMethodStatic{
+				addresses:   []net.IPNet{{IP: net.ParseIP("2001:db8::2"), Mask: net.CIDRMask(48, 128)}},
+				routes:      []route{},
+				nameservers: []net.IP{},
+				hwaddress:   net.HardwareAddr([]byte{0, 1, 2, 3, 4, 5}),
+			},
+		},
+		{
+			method: "auto",
+			config: configMethodStatic{addresses: []net.IPNet{}, routes: []route{}, nameservers: []net.IP{}, acceptRA: "yes"},
+		},
+		{
+			method:  "auto",
+			options: []string{"dhcp 1"},
+			config:  configMethodStatic{addresses: []net.IPNet{}, routes: []route{}, nameservers: []net.IP{}, acceptRA: "yes", dhcp: "ipv6"},
+		},
+		{
+			method:  "dhcp",
+			options: []string{"accept_ra 0"},
+			config:  configMethodStatic{addresses: []net.IPNet{}, routes: []route{}, nameservers: []net.IP{}, acceptRA: "no", dhcp: "ipv6"},
+		},
+		{
+			method: "manual",
+			config: configMethodManual{},
+		},
+	} {
+		iface, err := parseInterfaceStanza([]string{"eth0", "inet6", tt.method}, tt.options)
+		if err != nil {
+			t.Fatalf("bad error (#%d): want %v, got %v", i, nil, err)
+		}
+		if !reflect.DeepEqual(iface.configMethod, tt.config) {
+			t.Errorf("bad config (#%d): want %#v, got %#v", i, tt.config, iface.configMethod)
+		}
+	}
+
+	for _, options := range [][]string{
+		{"address 2001:db8::2"},
+		{"address 2001:db8::2/64", "gateway bad"},
+	} {
+		if _, err := parseInterfaceStanza([]string{"eth0", "inet6", "static"}, options); err == nil {
+			t.Errorf("bad error (%q): want non-nil, got %v", options, err)
+		}
+	}
+	if _, err := parseInterfaceStanza([]string{"eth0", "inet6", "v4tunnel"}, nil); err == nil {
+		t.Errorf("bad error: want non-nil, got %v", err)
+	}
+}
+
+func TestMergeStanzas(t *testing.T) {
+	hwaddr := net.HardwareAddr([]byte{0, 1, 2, 3, 4, 5})
+	stanzas := mergeStanzas([]*stanzaInterface{
+		{name: "eth0", kind: interfacePhysical, configMethod: configMethodDHCP{hwaddress: hwaddr}, options: map[string][]string{}},
+		{name: "eth1", kind: interfacePhysical, configMethod: configMethodManual{}, options: map[string][]string{}},
+		{name: "eth0", kind: interfacePhysical, auto: true, configMethod: configMethodStatic{dhcp: "ipv6", acceptRA: "yes"}, options: map[string][]string{"accept_ra": {"1"}}},
+		{name: "eth1", kind: interfaceBond, configMethod: configMethodDHCP{}, options: map[string][]string{"bond-slaves": {"eth2"}}},
+	})
+
+	expect := []*stanzaInterface{
+		{
+			name:         "eth0",
+			kind:         interfacePhysical,
+			auto:         true,
+			configMethod: configMethodStatic{addresses: []net.IPNet{}, routes: []route{}, nameservers: []net.IP{}, hwaddress: hwaddr, dhcp: "yes", acceptRA: "yes"},
+			options:      map[string][]string{"accept_ra": {"1"}},
+		},
+		{
+			name:         "eth1",
+			kind:         interfaceBond,
+			configMethod: configMethodDHCP{},
+			options:      map[string][]string{"bond-slaves": {"eth2"}},
+		},
+	}
+	if !reflect.DeepEqual(stanzas, expect) {
+		t.Errorf("bad stanzas: want %#v, got %#v", expect, stanzas)
+	}
+}
+
+func TestParseInterfaceStanzaBridge(t *testing.T) {
+	iface, err := parseInterfaceStanza([]string{"br0", "inet", "dhcp"}, []string{"bridge_ports eth0 eth1", "bridge_stp on"})
+	if err != nil {
+		t.FailNow()
+	}
+	if iface.kind != interfaceBridge {
+		t.FailNow()
+	}
+	if !reflect.DeepEqual(iface.options["bridge_ports"], []string{"eth0", "eth1"}) {
+		t.FailNow()
+	}
+}
+
+func TestParseInterfaceStanzaBridgeDashes(t *testing.T) {
+	iface, err := parseInterfaceStanza([]string{"br0", "inet", "dhcp"}, []string{"bridge-ports eth0 eth1", "bridge-stp on", "bridge-fd 0"})
+	if err != nil {
+		t.FailNow()
+	}
+	if iface.kind != interfaceBridge {
+		t.FailNow()
+	}
+	if !reflect.DeepEqual(iface.options["bridge_ports"], []string{"eth0", "eth1"}) {
+		t.FailNow()
+	}
+	if !reflect.DeepEqual(iface.options["bridge_stp"], []string{"on"}) {
+		t.FailNow()
+	}
+	if !reflect.DeepEqual(iface.options["bridge_fd"], []string{"0"}) {
+		t.FailNow()
+	}
+}
+
 func TestParseInterfaceStanzaVLANName(t *testing.T) {
 	iface, err := parseInterfaceStanza([]string{"eth0.1", "inet", "manual"}, nil)
 	if err != nil {
@@ -0,0 +1,201 @@
+// Copyright 2015 CoreOS, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package network
+
+import (
+	"fmt"
+	"net"
+	"strings"
+)
+
+// Conflict is a problem found in a network config by Validate. Fatal
+// conflicts leave the network broken, so the config is not applied.
+type Conflict struct {
+	Fatal   bool
+	Message string
+}
+
+func (c Conflict) String() string {
+	if c.Fatal {
+		return fmt.Sprintf("error: %s", c.Message)
+	}
+	return fmt.Sprintf("warning: %s", c.Message)
+}
+
+// HasFatal reports whether any of conflicts is fatal.
+func HasFatal(conflicts []Conflict) bool {
+	for _, c := range conflicts {
+		if c.Fatal {
+			return true
+		}
+	}
+	return false
+}
+
+// Validate checks interfaces for conflicts which the converters do not catch
+// as they build them: duplicate addresses, overlapping routes, VLANs on
+// missing parents, bond slaves which are also configured on their own and
+// interfaces which are their own ancestors.
+func Validate(interfaces []InterfaceGenerator) (conflicts []Conflict) {
+	fatal := func(format string, a ...interface{}) {
+		conflicts = append(conflicts, Conflict{Fatal: true, Message: fmt.Sprintf(format, a...)})
+	}
+	warning := func(format string, a ...interface{}) {
+		conflicts = append(conflicts, Conflict{Fatal: false, Message: fmt.Sprintf(format, a...)})
+	}
+
+	names := make(map[string]bool)
+	parents := make(map[string]string)
+	for _, iface := range interfaces {
+		names[iface.Name()] = true
+		if ni, ok := iface.(networkInterface); ok {
+			for _, child := range ni.Children() {
+				if _, ok := child.(*vlanInterface); ok {
+					parents[child.Name()] = iface.Name()
+				}
+			}
+		}
+	}
+
+	type address struct {
+		iface string
+		net.IPNet
+	}
+	type routeOn struct {
+		iface string
+		route
+	}
+	var addresses []address
+	var routes []routeOn
+	masters := make(map[string]string)
+	for _, iface := range interfaces {
+		i := logicalOf(iface)
+		if i == nil {
+			continue
+		}
+		if c, ok := i.config.(configMethodStatic); ok {
+			for _, a := range c.addresses {
+				addresses = append(addresses, address{i.name, a})
+			}
+			for _, r := range c.routes {
+				routes = append(routes, routeOn{i.name, r})
+			}
+		}
+
+		switch t := iface.(type) {
+		case *vlanInterface:
+			parent := t.rawDevice
+			if p, ok := parents[i.name]; ok {
+				parent = p
+			}
+			switch {
+			case parent == "":
+				fatal("VLAN %q has no parent interface", i.name)
+			case !names[parent]:
+				fatal("parent %q of VLAN %q is not configured", parent, i.name)
+			}
+		case *bondInterface:
+			for _, slave := range t.slaves {
+				if master, ok := masters[slave]; ok {
+					fatal("%q is a slave of both %q and %q", slave, master, i.name)
+					continue
+				}
+				masters[slave] = i.name
+			}
+		}
+	}
+
+	for _, iface := range interfaces {
+		i := logicalOf(iface)
+		if i == nil {
+			continue
+		}
+		master, ok := masters[i.name]
+		if !ok {
+			continue
+		}
+		switch c := i.config.(type) {
+		case configMethodStatic:
+			if isConfigured(c) {
+				fatal("%q is a slave of %q but also has addresses or DHCP of its own", i.name, master)
+			}
+		case configMethodDHCP:
+			fatal("%q is a slave of %q but also has addresses or DHCP of its own", i.name, master)
+		}
+	}
+
+	for n, a := range addresses {
+		for _, b := range addresses[:n] {
+			switch {
+			case a.IP.Equal(b.IP) && a.iface == b.iface:
+				fatal("address %s is configured twice on %q", a.IP, a.iface)
+			case a.IP.Equal(b.IP):
+				fatal("address %s is configured on both %q and %q", a.IP, b.iface, a.iface)
+			case a.iface != b.iface && (a.Contains(b.IP) || b.Contains(a.IP)):
+				warning("subnet of %s on %q overlaps subnet of %s on %q", a.String(), a.iface, b.String(), b.iface)
+			}
+		}
+	}
+
+	for n, a := range routes {
+		for _, b := range routes[:n] {
+			if a.destination.String() == b.destination.String() && a.metric == b.metric {
+				warning("route to %s on %q overlaps route on %q with the same metric", a.destination.String(), a.iface, b.iface)
+			}
+		}
+	}
+
+	for _, cycle := range cycles(interfaces) {
+		fatal("interfaces %s are their own ancestors", strings.Join(cycle, " -> "))
+	}
+	return
+}
+
+// cycles returns the cycles in the children of interfaces, each from its
+// first interface in name order back to that interface.
+func cycles(interfaces []InterfaceGenerator) (found [][]string) {
+	seen := make(map[string]bool)
+	var visit func(iface networkInterface, path []string)
+	visit = func(iface networkInterface, path []string) {
+		for n, name := range path {
+			if name != iface.Name() {
+				continue
+			}
+			cycle := append([]string{}, path[n:]...)
+			start := 0
+			for m, name := range cycle {
+				if name < cycle[start] {
+					start = m
+				}
+			}
+			cycle = append(cycle[start:], cycle[:start]...)
+			cycle = append(cycle, cycle[0])
+			if key := strings.Join(cycle, " "); !seen[key] {
+				seen[key] = true
+				found = append(found, cycle)
+			}
+			return
+		}
+		for _, child := range iface.Children() {
+			visit(child, append(path, iface.Name()))
+		}
+	}
+	for _, iface := range interfaces {
+		if ni, ok := iface.(networkInterface); ok {
+			visit(ni, nil)
+		}
+	}
+	return
+}
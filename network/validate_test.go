@@ -0,0 +1,125 @@
+// Copyright 2015 CoreOS, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package network
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/coreos/coreos-cloudinit/config"
+)
+
+func TestValidate(t *testing.T) {
+	for _, tt := range []struct {
+		netconf   string
+		conflicts []Conflict
+	}{
+		{
+			netconf: debianNetconf,
+		},
+		{
+			netconf: `auto eth0
+iface eth0 inet static
+    address 10.0.0.2
+    netmask 255.255.255.0
+    gateway 10.0.0.1
+
+auto eth1
+iface eth1 inet static
+    address 10.0.0.2
+    netmask 255.255.255.0
+
+auto eth2
+iface eth2 inet static
+    address 10.0.1.2
+    netmask 255.255.0.0
+    gateway 10.0.0.1
+`,
+			conflicts: []Conflict{
+				{Fatal: true, Message: `address 10.0.0.2 is configured on both "eth0" and "eth1"`},
+				{Fatal: false, Message: `subnet of 10.0.1.2/16 on "eth2" overlaps subnet of 10.0.0.2/24 on "eth0"`},
+				{Fatal: false, Message: `subnet of 10.0.1.2/16 on "eth2" overlaps subnet of 10.0.0.2/24 on "eth1"`},
+				{Fatal: false, Message: `route to 0.0.0.0/0 on "eth2" overlaps route on "eth0" with the same metric`},
+			},
+		},
+		{
+			netconf: `auto eth0
+iface eth0 inet manual
+
+auto bond0
+iface bond0 inet manual
+    bond-slaves eth0 bond1
+
+auto bond1
+iface bond1 inet dhcp
+    bond-slaves bond0
+`,
+			conflicts: []Conflict{
+				{Fatal: true, Message: `"bond1" is a slave of "bond0" but also has addresses or DHCP of its own`},
+				{Fatal: true, Message: `interfaces bond0 -> bond1 -> bond0 are their own ancestors`},
+			},
+		},
+	} {
+		interfaces, err := ProcessDebianNetconf([]byte(tt.netconf))
+		if err != nil {
+			t.Fatalf("bad error (%q): want %v, got %v", tt.netconf, nil, err)
+		}
+		if conflicts := Validate(interfaces); !reflect.DeepEqual(tt.conflicts, conflicts) {
+			t.Errorf("bad conflicts (%q): want %#v, got %#v", tt.netconf, tt.conflicts, conflicts)
+		}
+	}
+}
+
+func TestValidateNetworkConfig(t *testing.T) {
+	interfaces, err := ProcessNetworkConfig(config.Network{
+		Version: 2,
+		Ethernets: map[string]config.NetworkDevice{
+			"eno1": {DHCP4: true},
+			"eno2": {},
+		},
+		Bonds: map[string]config.NetworkDevice{
+			"bond0": {Interfaces: []string{"eno1", "eno2"}},
+			"bond1": {Interfaces: []string{"eno2"}},
+		},
+	})
+	if err != nil {
+		t.Fatalf("bad error: want %v, got %v", nil, err)
+	}
+
+	expect := []Conflict{
+		{Fatal: true, Message: `"eno2" is a slave of both "bond0" and "bond1"`},
+		{Fatal: true, Message: `"eno1" is a slave of "bond0" but also has addresses or DHCP of its own`},
+	}
+	if conflicts := Validate(interfaces); !reflect.DeepEqual(expect, conflicts) {
+		t.Fatalf("bad conflicts: want %#v, got %#v", expect, conflicts)
+	}
+}
+
+func TestValidateMissingParent(t *testing.T) {
+	interfaces := []InterfaceGenerator{
+		&vlanInterface{logicalInterface{name: "vlan10", config: configMethodManual{}}, 10, "eth0"},
+		&vlanInterface{logicalInterface{name: "vlan20", config: configMethodManual{}}, 20, ""},
+	}
+	expect := []Conflict{
+		{Fatal: true, Message: `parent "eth0" of VLAN "vlan10" is not configured`},
+		{Fatal: true, Message: `VLAN "vlan20" has no parent interface`},
+	}
+	if conflicts := Validate(interfaces); !reflect.DeepEqual(expect, conflicts) {
+		t.Fatalf("bad conflicts: want %#v, got %#v", expect, conflicts)
+	}
+	if !HasFatal(expect) {
+		t.Fatalf("bad fatal: want true, got false")
+	}
+}
@@ -0,0 +1,107 @@
+// Copyright 2015 CoreOS, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package network
+
+import (
+	"fmt"
+	"log"
+	"net"
+	"strconv"
+
+	"github.com/coreos/coreos-cloudinit/datasource/metadata/vultr"
+)
+
+// vultrNameserver is the resolver Vultr provides to every instance.
+var vultrNameserver = net.ParseIP("108.61.10.10")
+
+func ProcessVultrNetconf(config vultr.Metadata) ([]InterfaceGenerator, error) {
+	log.Println("Processing Vultr network config")
+
+	generators := make([]InterfaceGenerator, 0, len(config.Interfaces))
+	for _, iface := range config.Interfaces {
+		generator, err := parseVultrInterface(iface)
+		if err != nil {
+			return nil, err
+		}
+		generators = append(generators, &physicalInterface{*generator})
+	}
+	log.Printf("Parsed %d network interfaces\n", len(generators))
+
+	log.Println("Processed Vultr network config")
+	return generators, nil
+}
+
+func parseVultrInterface(iface vultr.Interface) (*logicalInterface, error) {
+	public := iface.NetworkType == "public"
+	config := configMethodStatic{
+		addresses:   []net.IPNet{},
+		nameservers: []net.IP{},
+		routes:      []route{},
+	}
+	if public {
+		config.nameservers = append(config.nameservers, vultrNameserver)
+	}
+
+	if iface.IPv4 != nil {
+		var ip, mask net.IP
+		if ip = net.ParseIP(iface.IPv4.Address); ip == nil {
+			return nil, fmt.Errorf("could not parse %q as IPv4 address", iface.IPv4.Address)
+		}
+		if mask = net.ParseIP(iface.IPv4.Netmask); mask == nil {
+			return nil, fmt.Errorf("could not parse %q as IPv4 mask", iface.IPv4.Netmask)
+		}
+		config.addresses = append(config.addresses, net.IPNet{
+			IP:   ip,
+			Mask: net.IPMask(mask.To4()),
+		})
+
+		if public && iface.IPv4.Gateway != "" {
+			gateway := net.ParseIP(iface.IPv4.Gateway)
+			if gateway == nil {
+				return nil, fmt.Errorf("could not parse %q as IPv4 gateway", iface.IPv4.Gateway)
+			}
+			config.routes = append(config.routes, route{
+				destination: defaultDestination(ip),
+				gateway:     gateway,
+			})
+		}
+	}
+	if iface.IPv6 != nil {
+		var ip net.IP
+		if ip = net.ParseIP(iface.IPv6.Address); ip == nil {
+			return nil, fmt.Errorf("could not parse %q as IPv6 address", iface.IPv6.Address)
+		}
+		prefix, err := strconv.Atoi(iface.IPv6.Prefix)
+		if err != nil {
+			return nil, fmt.Errorf("could not parse %q as IPv6 prefix", iface.IPv6.Prefix)
+		}
+		// The IPv6 default route is learned from router advertisements.
+		config.addresses = append(config.addresses, net.IPNet{
+			IP:   ip,
+			Mask: net.CIDRMask(prefix, net.IPv6len*8),
+		})
+	}
+
+	hwaddr, err := net.ParseMAC(iface.MAC)
+	if err != nil {
+		return nil, err
+	}
+
+	return &logicalInterface{
+		hwaddr:   hwaddr,
+		config:   config,
+		children: []networkInterface{},
+	}, nil
+}
@@ -0,0 +1,59 @@
+// Copyright 2015 CoreOS, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package network
+
+import (
+	"testing"
+
+	"github.com/coreos/coreos-cloudinit/datasource/metadata/vultr"
+)
+
+func TestProcessVultrNetconf(t *testing.T) {
+	interfaces, err := ProcessVultrNetconf(vultr.Metadata{
+		Interfaces: []vultr.Interface{
+			{
+				IPv4:        &vultr.IPv4{Address: "45.76.7.171", Gateway: "45.76.6.1", Netmask: "255.255.254.0"},
+				IPv6:        &vultr.IPv6{Address: "2001:19f0:5:28a7::", Network: "2001:19f0:5:28a7::", Prefix: "64"},
+				MAC:         "56:00:03:1b:4e:ca",
+				NetworkType: "public",
+			},
+			{
+				IPv4:        &vultr.IPv4{Address: "10.1.112.3", Netmask: "255.255.240.0"},
+				MAC:         "5a:00:03:1b:4e:ca",
+				NetworkType: "private",
+			},
+		},
+	})
+	if err != nil {
+		t.Fatalf("bad error: want %v, got %v", nil, err)
+	}
+
+	networks := []string{
+		"[Match]\nMACAddress=56:00:03:1b:4e:ca\n\n[Network]\nDNS=108.61.10.10\n\n[Address]\nAddress=45.76.7.171/23\n\n[Address]\nAddress=2001:19f0:5:28a7::/64\n\n[Route]\nDestination=0.0.0.0/0\nGateway=45.76.6.1\n",
+		"[Match]\nMACAddress=5a:00:03:1b:4e:ca\n\n[Network]\n\n[Address]\nAddress=10.1.112.3/20\n",
+	}
+	if len(interfaces) != len(networks) {
+		t.Fatalf("bad number of interfaces: want %d, got %d", len(networks), len(interfaces))
+	}
+	for i, iface := range interfaces {
+		if network := iface.Network(); network != networks[i] {
+			t.Errorf("bad network (#%d): want %q, got %q", i, networks[i], network)
+		}
+	}
+
+	if _, err := ProcessVultrNetconf(vultr.Metadata{Interfaces: []vultr.Interface{{MAC: "bad"}}}); err == nil {
+		t.Errorf("bad error: want invalid MAC address, got %v", err)
+	}
+}
@@ -19,6 +19,7 @@ import (
 	"fmt"
 	"io/ioutil"
 	"log"
+	"net"
 	"net/http"
 	neturl "net/url"
 	"strings"
@@ -87,6 +88,14 @@ func NewHttpClient() *HttpClient {
 	return hc
 }
 
+// NewHttpClientWithDial returns a client making its connections with dial,
+// e.g. to connect from a particular local address or port.
+func NewHttpClientWithDial(dial func(network, addr string) (net.Conn, error)) *HttpClient {
+	hc := NewHttpClient()
+	hc.client.Transport = &http.Transport{Dial: dial}
+	return hc
+}
+
 func ExpBackoff(interval, max time.Duration) time.Duration {
 	interval = interval * 2
 	if interval > max {
@@ -139,7 +148,17 @@ func (h *HttpClient) GetRetry(rawurl string) ([]byte, error) {
 }
 
 func (h *HttpClient) Get(dataURL string) ([]byte, error) {
-	if resp, err := h.client.Get(dataURL); err == nil {
+	return readResponse(h.client.Get(dataURL))
+}
+
+// Do sends an arbitrary request, e.g. one with custom headers, and returns
+// the body of the response. Errors are classified like those of Get.
+func (h *HttpClient) Do(req *http.Request) ([]byte, error) {
+	return readResponse(h.client.Do(req))
+}
+
+func readResponse(resp *http.Response, err error) ([]byte, error) {
+	if err == nil {
 		defer resp.Body.Close()
 		switch resp.StatusCode / 100 {
 		case HTTP_2xx:
@@ -17,9 +17,11 @@ package pkg
 import (
 	"fmt"
 	"io"
+	"io/ioutil"
 	"math"
 	"net/http"
 	"net/http/httptest"
+	"strings"
 	"testing"
 	"time"
 )
@@ -131,6 +133,37 @@ coreos:
 	}
 }
 
+// Test that requests are sent with their headers and body
+func TestDo(t *testing.T) {
+	client := NewHttpClient()
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Metadata") != "true" {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		body, _ := ioutil.ReadAll(r.Body)
+		fmt.Fprintf(w, "%s %s", r.Method, body)
+	}))
+	defer ts.Close()
+
+	req, _ := http.NewRequest("POST", ts.URL, strings.NewReader("ready"))
+	req.Header.Set("Metadata", "true")
+	data, err := client.Do(req)
+	if err != nil {
+		t.Errorf("Incorrect result\ngot:  %v\nwant: %v", err, nil)
+	}
+	if string(data) != "POST ready" {
+		t.Errorf("Incorrect result\ngot:  %s\nwant: %s", data, "POST ready")
+	}
+
+	req, _ = http.NewRequest("GET", ts.URL, nil)
+	if _, err = client.Do(req); err == nil {
+		t.Errorf("Incorrect result\ngot:  %v\nwant: %v", err, "ErrNotFound")
+	} else if _, ok := err.(ErrNotFound); !ok {
+		t.Errorf("Incorrect result\ngot:  %v\nwant: %v", err, "ErrNotFound")
+	}
+}
+
 // Test attempt to fetching using malformed URL
 func TestGetMalformedURL(t *testing.T) {
 	client := NewHttpClient()
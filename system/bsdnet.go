@@ -0,0 +1,82 @@
+// Copyright 2015 CoreOS, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package system
+
+import (
+	"log"
+	"os/exec"
+
+	"github.com/coreos/coreos-cloudinit/config"
+	"github.com/coreos/coreos-cloudinit/network"
+)
+
+// Runner runs a command to completion. The BSD network restarts run their
+// commands through it, so that they can be checked on any system.
+type Runner func(name string, args ...string) error
+
+// execRunner runs commands on the system.
+func execRunner(name string, args ...string) error {
+	return exec.Command(name, args...).Run()
+}
+
+// restartFreeBSDNetwork writes the network config of the FreeBSD system at
+// root and restarts its interfaces and routes.
+func restartFreeBSDNetwork(root string, interfaces []network.InterfaceGenerator, run Runner) error {
+	if err := writeNetconfFiles(root, []network.NetconfFile{network.RenderFreeBSDNetconf(interfaces)}, "0644"); err != nil {
+		return err
+	}
+	log.Printf("Restarting network interfaces\n")
+	if err := run("service", "netif", "restart"); err != nil {
+		return err
+	}
+	log.Printf("Restarting routing\n")
+	return run("service", "routing", "restart")
+}
+
+// restartOpenBSDNetwork writes the network config of the OpenBSD system at
+// root and reruns netstart for all interfaces, as netstart only sets the
+// default gateway of mygate then.
+func restartOpenBSDNetwork(root string, interfaces []network.InterfaceGenerator, run Runner) error {
+	if err := writeNetconfFiles(root, network.RenderOpenBSDNetconf(interfaces), "0640"); err != nil {
+		return err
+	}
+	log.Printf("Running netstart\n")
+	return run("sh", "/etc/netstart")
+}
+
+// restartNetBSDNetwork writes the network config of the NetBSD system at root
+// and restarts its network.
+func restartNetBSDNetwork(root string, interfaces []network.InterfaceGenerator, run Runner) error {
+	if err := writeNetconfFiles(root, network.RenderNetBSDNetconf(interfaces), "0644"); err != nil {
+		return err
+	}
+	log.Printf("Restarting network\n")
+	return run("/etc/rc.d/network", "restart")
+}
+
+func writeNetconfFiles(root string, files []network.NetconfFile, perm string) error {
+	for _, file := range files {
+		fullPath, err := WriteFile(&File{config.File{
+			Path:               file.Path,
+			Content:            file.Content,
+			RawFilePermissions: perm,
+		}}, root)
+		if err != nil {
+			return err
+		}
+		log.Printf("Wrote network config %s to filesystem", fullPath)
+	}
+	return nil
+}
@@ -0,0 +1,121 @@
+// Copyright 2015 CoreOS, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package system
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"path"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/coreos/coreos-cloudinit/network"
+)
+
+const bsdNetconf = `auto eth0
+iface eth0 inet static
+    address 192.168.1.10
+    netmask 255.255.255.0
+    gateway 192.168.1.1
+`
+
+// recordingRunner records the commands it is given, failing those named in
+// fail.
+type recordingRunner struct {
+	commands []string
+	fail     string
+}
+
+func (r *recordingRunner) run(name string, args ...string) error {
+	command := strings.Join(append([]string{name}, args...), " ")
+	r.commands = append(r.commands, command)
+	if command == r.fail {
+		return errors.New("command failed")
+	}
+	return nil
+}
+
+func TestRestartBSDNetwork(t *testing.T) {
+	for _, tt := range []struct {
+		restart  func(string, []network.InterfaceGenerator, Runner) error
+		files    map[string]os.FileMode
+		commands []string
+	}{
+		{
+			restart:  restartFreeBSDNetwork,
+			files:    map[string]os.FileMode{"etc/rc.conf.d/network": 0644},
+			commands: []string{"service netif restart", "service routing restart"},
+		},
+		{
+			restart:  restartOpenBSDNetwork,
+			files:    map[string]os.FileMode{"etc/hostname.eth0": 0640, "etc/mygate": 0640},
+			commands: []string{"sh /etc/netstart"},
+		},
+		{
+			restart:  restartNetBSDNetwork,
+			files:    map[string]os.FileMode{"etc/ifconfig.eth0": 0644, "etc/rc.conf.d/network": 0644},
+			commands: []string{"/etc/rc.d/network restart"},
+		},
+	} {
+		dir, err := ioutil.TempDir(os.TempDir(), "coreos-cloudinit-")
+		if err != nil {
+			t.Fatalf("Unable to create tempdir: %v", err)
+		}
+		defer os.RemoveAll(dir)
+
+		interfaces, err := network.ProcessDebianNetconf([]byte(bsdNetconf))
+		if err != nil {
+			t.Fatalf("bad error: want %v, got %v", nil, err)
+		}
+		runner := &recordingRunner{}
+		if err := tt.restart(dir, interfaces, runner.run); err != nil {
+			t.Fatalf("bad error: want %v, got %v", nil, err)
+		}
+		if !reflect.DeepEqual(tt.commands, runner.commands) {
+			t.Fatalf("bad commands: want %q, got %q", tt.commands, runner.commands)
+		}
+		for file, mode := range tt.files {
+			fi, err := os.Stat(path.Join(dir, file))
+			if err != nil {
+				t.Fatalf("Unable to stat file: %v", err)
+			}
+			if fi.Mode() != mode {
+				t.Fatalf("bad mode (%s): want %v, got %v", file, mode, fi.Mode())
+			}
+		}
+	}
+}
+
+func TestRestartFreeBSDNetworkFailure(t *testing.T) {
+	dir, err := ioutil.TempDir(os.TempDir(), "coreos-cloudinit-")
+	if err != nil {
+		t.Fatalf("Unable to create tempdir: %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	interfaces, err := network.ProcessDebianNetconf([]byte(bsdNetconf))
+	if err != nil {
+		t.Fatalf("bad error: want %v, got %v", nil, err)
+	}
+	runner := &recordingRunner{fail: "service netif restart"}
+	if err := restartFreeBSDNetwork(dir, interfaces, runner.run); err == nil {
+		t.Fatalf("bad error: want non-nil, got nil")
+	}
+	if expect := []string{"service netif restart"}; !reflect.DeepEqual(expect, runner.commands) {
+		t.Fatalf("bad commands: want %q, got %q", expect, runner.commands)
+	}
+}
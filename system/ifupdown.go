@@ -0,0 +1,60 @@
+// Copyright 2015 CoreOS, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package system
+
+import (
+	"log"
+	"os"
+	"os/exec"
+	"path"
+
+	"github.com/coreos/coreos-cloudinit/network"
+)
+
+// IfupdownConfigFile is where the network config is written for ifupdown,
+// which reads it when /etc/network/interfaces sources interfaces.d.
+const IfupdownConfigFile = "/etc/network/interfaces.d/50-cloudinit.cfg"
+
+// IfupdownEnabled reports whether ifupdown manages the network of the system
+// at root.
+func IfupdownEnabled(root string) bool {
+	_, err := os.Lstat(path.Join(root, "etc/systemd/system/multi-user.target.wants/networking.service"))
+	return err == nil
+}
+
+// RestartIfupdown takes the given interfaces down and brings them back up
+// with their new config.
+func RestartIfupdown(interfaces []network.InterfaceGenerator) error {
+	var names []string
+	for _, iface := range interfaces {
+		if name := iface.Name(); name != "" && name != "*" {
+			names = append(names, name)
+		}
+	}
+
+	for i := len(names) - 1; i >= 0; i-- {
+		log.Printf("Taking down interface %q\n", names[i])
+		if err := exec.Command("ifdown", "--force", names[i]).Run(); err != nil {
+			log.Printf("Error while downing interface %q (%s). Continuing...\n", names[i], err)
+		}
+	}
+	for _, name := range names {
+		log.Printf("Bringing up interface %q\n", name)
+		if err := exec.Command("ifup", name).Run(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -25,7 +25,7 @@ import (
 	"github.com/vishvananda/netlink"
 )
 
-func RestartNetwork(interfaces []network.InterfaceGenerator) (err error) {
+func RestartNetwork(root string, interfaces []network.InterfaceGenerator) (err error) {
 	defer func() {
 		if e := restartNetworkd(); e != nil {
 			err = e
@@ -53,8 +53,22 @@ func downNetworkInterfaces(interfaces []network.InterfaceGenerator) error {
 		return err
 	}
 
+	var names []string
 	for _, iface := range interfaces {
-		if systemInterface, ok := sysInterfaceMap[iface.Name()]; ok {
+		names = append(names, iface.Name())
+	}
+	// Interfaces which are to be renamed by .link units are taken down too,
+	// as udev cannot rename them while they are up.
+	renames := network.Renames(interfaces)
+	for _, systemInterface := range sysInterfaceMap {
+		if name, ok := renames[systemInterface.HardwareAddr.String()]; ok && name != systemInterface.Name {
+			log.Printf("Interface %q is to be renamed to %q\n", systemInterface.Name, name)
+			names = append(names, systemInterface.Name)
+		}
+	}
+
+	for _, name := range names {
+		if systemInterface, ok := sysInterfaceMap[name]; ok {
 			log.Printf("Taking down interface %q\n", systemInterface.Name)
 			link, err := netlink.LinkByName(systemInterface.Name)
 			if err != nil {
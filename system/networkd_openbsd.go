@@ -16,6 +16,8 @@ package system
 
 import "github.com/coreos/coreos-cloudinit/network"
 
-func RestartNetwork(interfaces []network.InterfaceGenerator) (err error) {
-	return nil
+// RestartNetwork writes the OpenBSD network config of the given interfaces under
+// root and restarts networking.
+func RestartNetwork(root string, interfaces []network.InterfaceGenerator) (err error) {
+	return restartOpenBSDNetwork(root, interfaces, execRunner)
 }
@@ -16,6 +16,6 @@ package system
 
 import "github.com/coreos/coreos-cloudinit/network"
 
-func RestartNetwork(interfaces []network.InterfaceGenerator) (err error) {
+func RestartNetwork(root string, interfaces []network.InterfaceGenerator) (err error) {
 	return nil
 }
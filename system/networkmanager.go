@@ -0,0 +1,52 @@
+// Copyright 2015 CoreOS, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package system
+
+import (
+	"log"
+	"os"
+	"os/exec"
+	"path"
+
+	"github.com/coreos/coreos-cloudinit/network"
+)
+
+// NetworkManagerConnectionsDir is where NetworkManager reads the keyfiles of
+// its connections from.
+const NetworkManagerConnectionsDir = "/etc/NetworkManager/system-connections"
+
+// NetworkManagerEnabled reports whether NetworkManager, rather than
+// systemd-networkd, manages the network of the system at root.
+func NetworkManagerEnabled(root string) bool {
+	_, err := os.Lstat(path.Join(root, "etc/systemd/system/multi-user.target.wants/NetworkManager.service"))
+	return err == nil
+}
+
+// RestartNetworkManager has NetworkManager reload the keyfiles of its
+// connections and brings up the given ones.
+func RestartNetworkManager(connections []network.NMConnection) error {
+	log.Printf("Reloading NetworkManager connections\n")
+	if err := exec.Command("nmcli", "connection", "reload").Run(); err != nil {
+		return err
+	}
+
+	for _, conn := range connections {
+		log.Printf("Activating NetworkManager connection %q\n", conn.ID)
+		if err := exec.Command("nmcli", "connection", "up", "id", conn.ID).Run(); err != nil {
+			log.Printf("Error while activating connection %q (%s). Continuing...\n", conn.ID, err)
+		}
+	}
+	return nil
+}
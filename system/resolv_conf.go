@@ -0,0 +1,107 @@
+// Copyright 2015 CoreOS, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package system
+
+import (
+	"fmt"
+	"log"
+	"net"
+	"os"
+	"path"
+	"strings"
+
+	"github.com/coreos/coreos-cloudinit/config"
+)
+
+const (
+	// ResolvConfFile is where the DNS config is written unless
+	// systemd-resolved manages it.
+	ResolvConfFile = "/etc/resolv.conf"
+
+	// ResolvedDropInFile is where the DNS config is written for
+	// systemd-resolved.
+	ResolvedDropInFile = "/etc/systemd/resolved.conf.d/50-cloudinit.conf"
+)
+
+type ResolvConf struct {
+	config.ResolvConf
+
+	// Resolved is whether systemd-resolved manages the DNS config.
+	Resolved bool
+}
+
+// ResolvedEnabled reports whether systemd-resolved manages the DNS config of
+// the system at root, either because it is enabled or because resolv.conf
+// links to one of its files.
+func ResolvedEnabled(root string) bool {
+	if _, err := os.Lstat(path.Join(root, "etc/systemd/system/multi-user.target.wants/systemd-resolved.service")); err == nil {
+		return true
+	}
+	target, err := os.Readlink(path.Join(root, ResolvConfFile))
+	return err == nil && strings.Contains(target, "/systemd/resolve/")
+}
+
+func (rc ResolvConf) File() (*File, error) {
+	if len(rc.Nameservers) == 0 && len(rc.SearchDomains) == 0 && len(rc.Options) == 0 {
+		return nil, nil
+	}
+	for _, nameserver := range rc.Nameservers {
+		if net.ParseIP(nameserver) == nil {
+			return nil, fmt.Errorf("Invalid nameserver %q in resolv_conf", nameserver)
+		}
+	}
+
+	if rc.Resolved {
+		content := "[Resolve]\n"
+		if len(rc.Nameservers) > 0 {
+			content += fmt.Sprintf("DNS=%s\n", strings.Join(rc.Nameservers, " "))
+		}
+		if len(rc.SearchDomains) > 0 {
+			content += fmt.Sprintf("Domains=%s\n", strings.Join(rc.SearchDomains, " "))
+		}
+		if len(rc.Options) > 0 {
+			log.Printf("Ignoring resolv_conf options, which systemd-resolved does not support")
+		}
+		return &File{config.File{
+			Path:               ResolvedDropInFile,
+			RawFilePermissions: "0644",
+			Content:            content,
+		}}, nil
+	}
+
+	content := "# Generated by coreos-cloudinit\n"
+	for _, nameserver := range rc.Nameservers {
+		content += fmt.Sprintf("nameserver %s\n", nameserver)
+	}
+	if len(rc.SearchDomains) > 0 {
+		content += fmt.Sprintf("search %s\n", strings.Join(rc.SearchDomains, " "))
+	}
+	if len(rc.Options) > 0 {
+		content += fmt.Sprintf("options %s\n", strings.Join(rc.Options, " "))
+	}
+	return &File{config.File{
+		Path:               ResolvConfFile,
+		RawFilePermissions: "0644",
+		Content:            content,
+	}}, nil
+}
+
+// RestartResolved restarts systemd-resolved, so that it reads its drop-ins.
+func RestartResolved() error {
+	log.Printf("Restarting systemd-resolved.service\n")
+	resolved := Unit{config.Unit{Name: "systemd-resolved.service"}}
+	_, err := NewUnitManager("").RunUnitCommand(resolved, "restart")
+	return err
+}
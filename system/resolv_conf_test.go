@@ -0,0 +1,96 @@
+// Copyright 2015 CoreOS, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package system
+
+import (
+	"io/ioutil"
+	"os"
+	"path"
+	"reflect"
+	"testing"
+
+	"github.com/coreos/coreos-cloudinit/config"
+)
+
+func TestResolvConfFile(t *testing.T) {
+	for _, tt := range []struct {
+		rc   ResolvConf
+		file *File
+	}{
+		{
+			rc: ResolvConf{},
+		},
+		{
+			rc: ResolvConf{ResolvConf: config.ResolvConf{
+				Nameservers:   []string{"8.8.8.8", "2001:4860:4860::8888"},
+				SearchDomains: []string{"example.com", "example.org"},
+				Options:       []string{"rotate", "timeout:1"},
+			}},
+			file: &File{config.File{
+				Path:               "/etc/resolv.conf",
+				RawFilePermissions: "0644",
+				Content:            "# Generated by coreos-cloudinit\nnameserver 8.8.8.8\nnameserver 2001:4860:4860::8888\nsearch example.com example.org\noptions rotate timeout:1\n",
+			}},
+		},
+		{
+			rc: ResolvConf{ResolvConf: config.ResolvConf{
+				Nameservers:   []string{"8.8.8.8", "8.8.4.4"},
+				SearchDomains: []string{"example.com"},
+				Options:       []string{"rotate"},
+			}, Resolved: true},
+			file: &File{config.File{
+				Path:               "/etc/systemd/resolved.conf.d/50-cloudinit.conf",
+				RawFilePermissions: "0644",
+				Content:            "[Resolve]\nDNS=8.8.8.8 8.8.4.4\nDomains=example.com\n",
+			}},
+		},
+	} {
+		file, err := tt.rc.File()
+		if err != nil {
+			t.Fatalf("bad error: want %v, got %v", nil, err)
+		}
+		if !reflect.DeepEqual(tt.file, file) {
+			t.Fatalf("bad file: want %#v, got %#v", tt.file, file)
+		}
+	}
+}
+
+func TestResolvConfFileInvalidNameserver(t *testing.T) {
+	rc := ResolvConf{ResolvConf: config.ResolvConf{Nameservers: []string{"example.com"}}}
+	if _, err := rc.File(); err == nil {
+		t.Fatalf("bad error: want non-nil, got nil")
+	}
+}
+
+func TestResolvedEnabled(t *testing.T) {
+	dir, err := ioutil.TempDir(os.TempDir(), "coreos-cloudinit-")
+	if err != nil {
+		t.Fatalf("Unable to create tempdir: %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	if ResolvedEnabled(dir) {
+		t.Fatalf("bad resolved: want false, got true")
+	}
+	if err := os.MkdirAll(path.Join(dir, "etc"), 0755); err != nil {
+		t.Fatalf("Unable to create etc: %v", err)
+	}
+	if err := os.Symlink("../run/systemd/resolve/stub-resolv.conf", path.Join(dir, "etc/resolv.conf")); err != nil {
+		t.Fatalf("Unable to link resolv.conf: %v", err)
+	}
+	if !ResolvedEnabled(dir) {
+		t.Fatalf("bad resolved: want true, got false")
+	}
+}
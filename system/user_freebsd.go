@@ -127,3 +127,14 @@ func SetUserPassword(user, hash string) error {
 
 	return nil
 }
+
+// SetUserPlainPassword sets the password of user from its clear text.
+func SetUserPlainPassword(user, password string) error {
+	cmd := exec.Command("pw", "usermod", user, "-h", "0")
+	cmd.Stdin = strings.NewReader(password)
+	if output, err := cmd.CombinedOutput(); err != nil {
+		log.Printf("Command 'pw usermod %s -h 0' failed: %v\n%s", user, err, output)
+		return err
+	}
+	return nil
+}
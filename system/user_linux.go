@@ -140,3 +140,14 @@ func SetUserPassword(user, hash string) error {
 
 	return nil
 }
+
+// SetUserPlainPassword sets the password of user from its clear text.
+func SetUserPlainPassword(user, password string) error {
+	cmd := exec.Command("chpasswd")
+	cmd.Stdin = strings.NewReader(fmt.Sprintf("%s:%s", user, password))
+	if output, err := cmd.CombinedOutput(); err != nil {
+		log.Printf("Command 'chpasswd' failed: %v\n%s", err, output)
+		return err
+	}
+	return nil
+}
@@ -140,3 +140,8 @@ func SetUserPassword(user, hash string) error {
 
 	return nil
 }
+
+// SetUserPlainPassword sets the password of user from its clear text.
+func SetUserPlainPassword(user, password string) error {
+	return SetUserPassword(user, password)
+}
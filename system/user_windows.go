@@ -27,3 +27,7 @@ func LockUnlockUser(u *config.User) error {
 func SetUserPassword(user, hash string) error {
 	return nil
 }
+
+func SetUserPlainPassword(user, password string) error {
+	return nil
+}